@@ -0,0 +1,248 @@
+// Package client talks to a factoid server over its HTTP API.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Option interface {
+	Apply(c *Client)
+}
+
+type optionFunc func(c *Client)
+
+func (opt optionFunc) Apply(c *Client) {
+	opt(c)
+}
+
+// WithAuthorization sets the secret sent in the Authorization header,
+// which the server may require for requests that change facts.
+func WithAuthorization(secret string) optionFunc {
+	return func(c *Client) { c.auth = secret }
+}
+
+// WithHTTPClient sets the client used to make requests.
+func WithHTTPClient(client *http.Client) optionFunc {
+	return func(c *Client) { c.http = client }
+}
+
+// WithRetries sets how many times a failed request is retried and how
+// long to wait before the first retry. The wait doubles with every
+// retry.
+//
+// Only requests that are safe to repeat are retried: reads, deletes,
+// pins and creates, which carry an Idempotency-Key so the server
+// doesn't create the same facts twice.
+func WithRetries(n int, backoff time.Duration) optionFunc {
+	return func(c *Client) {
+		c.retries = n
+		c.backoff = backoff
+	}
+}
+
+// Client is a factoid API client. It's safe for concurrent use.
+type Client struct {
+	baseURL *url.URL
+	auth    string
+	http    *http.Client
+	retries int
+	backoff time.Duration
+}
+
+// New returns a client for the server at baseURL, for example
+// "https://factoid.example.com".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("client: base URL must be an http or https URL")
+	}
+
+	c := &Client{
+		baseURL: u,
+		http:    http.DefaultClient,
+		retries: 2,
+		backoff: 100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt.Apply(c)
+	}
+	return c, nil
+}
+
+// maxBackoff caps the wait between retries.
+const maxBackoff = 5 * time.Second
+
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+
+	// body is sent as is if set, otherwise in is sent as JSON.
+	body io.Reader
+	in   any
+}
+
+// do sends req, retrying if that's safe, and returns the response if the
+// server was happy with it. Otherwise the error is an *Error.
+func (c *Client) do(ctx context.Context, req request) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += req.path
+	u.RawQuery = req.query.Encode()
+
+	body := req.body
+	if body == nil && req.in != nil {
+		blob, err := json.Marshal(req.in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(blob)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, req.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range req.header {
+		r.Header[k] = v
+	}
+	if req.in != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if r.Header.Get("Accept") == "" {
+		r.Header.Set("Accept", "application/json")
+	}
+	if c.auth != "" {
+		r.Header.Set("Authorization", c.auth)
+	}
+
+	retryable := c.retryable(r)
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if r.GetBody != nil {
+				r.Body, err = r.GetBody()
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		rsp, err := c.http.Do(r)
+
+		if retryable && attempt < c.retries && shouldRetry(rsp, err) {
+			wait := c.backoffFor(attempt, rsp)
+			if rsp != nil {
+				io.Copy(io.Discard, rsp.Body)
+				rsp.Body.Close()
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rsp.StatusCode >= http.StatusBadRequest {
+			defer rsp.Body.Close()
+			return nil, errorFromResponse(rsp)
+		}
+
+		return rsp, nil
+	}
+}
+
+// doJSON sends req and decodes the JSON response into out, if it isn't
+// nil.
+func (c *Client) doJSON(ctx context.Context, req request, out any) error {
+	rsp, err := c.do(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if out == nil {
+		io.Copy(io.Discard, rsp.Body)
+		return nil
+	}
+
+	return json.NewDecoder(rsp.Body).Decode(out)
+}
+
+func (c *Client) retryable(r *http.Request) bool {
+	if r.Body != nil && r.GetBody == nil {
+		// The body can only be read once.
+		return false
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	case http.MethodPost:
+		return r.Header.Get("Idempotency-Key") != ""
+	}
+	return false
+}
+
+func shouldRetry(rsp *http.Response, err error) bool {
+	if err != nil {
+		// The context's errors aren't going away by trying again.
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+
+	switch rsp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoffFor returns how long to wait before retrying after the given
+// attempt, preferring the server's Retry-After if it sent one.
+func (c *Client) backoffFor(attempt int, rsp *http.Response) time.Duration {
+	if rsp != nil {
+		if secs, err := strconv.Atoi(rsp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+
+	wait := c.backoff << attempt
+	if wait <= 0 || wait > maxBackoff {
+		wait = maxBackoff
+	}
+
+	// Spread out retries from clients that failed at the same moment.
+	return wait/2 + time.Duration(mathrand.Int63n(int64(wait/2)+1))
+}
+
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,335 @@
+package client_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/connorkuehl/factoid/client"
+	sqliterepo "github.com/connorkuehl/factoid/internal/repo/sqlite"
+	"github.com/connorkuehl/factoid/internal/service"
+)
+
+func newTestServer(t *testing.T, opts ...service.Option) (*httptest.Server, *sqliterepo.Repo, func()) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliterepo.Schema()); err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+
+	repo := sqliterepo.NewRepo(db)
+	opts = append([]service.Option{service.WithIdempotencyStore(repo, time.Hour)}, opts...)
+	ts := httptest.NewServer(service.New(repo, opts...).Routes())
+
+	return ts, repo, func() {
+		ts.Close()
+		db.Close()
+	}
+}
+
+func newTestClient(t *testing.T, ts *httptest.Server, opts ...client.Option) *client.Client {
+	opts = append([]client.Option{
+		client.WithHTTPClient(ts.Client()),
+		client.WithRetries(3, time.Millisecond),
+	}, opts...)
+
+	c, err := client.New(ts.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func float(f float64) *float64 { return &f }
+
+func str(s string) *string { return &s }
+
+func TestFacts(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, service.WithAuthorizer("secret"))
+	defer cleanup()
+
+	c := newTestClient(t, ts, client.WithAuthorization("secret"))
+	ctx := context.TODO()
+
+	created, err := c.CreateFact(ctx, client.NewFact{
+		Content:   "a fact",
+		Citations: []client.Citation{{URL: "https://example.com", Title: "Example"}},
+		Weight:    float(2),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created.ID != 1 || created.Source != "https://example.com" || created.Weight != 2 || created.Version != 1 {
+		t.Errorf("unexpected fact %+v", created)
+	}
+
+	got, err := c.Fact(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(created, got) {
+		t.Errorf("want fact %+v, got %+v", created, got)
+	}
+
+	updated, err := c.UpdateFact(ctx, created.ID, client.FactUpdate{
+		Content: str("an edited fact"),
+		Version: created.Version,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Content != "an edited fact" || updated.Version != 2 {
+		t.Errorf("unexpected fact %+v", updated)
+	}
+
+	_, err = c.UpdateFact(ctx, created.ID, client.FactUpdate{Weight: float(3), Version: created.Version})
+	if !errors.Is(err, client.ErrPreconditionFailed) {
+		t.Errorf("want %v, got %v", client.ErrPreconditionFailed, err)
+	}
+
+	random, err := c.RandomFact(ctx, client.RandomOptions{Seed: "seed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if random.ID != created.ID {
+		t.Errorf("want fact %d, got fact %d", created.ID, random.ID)
+	}
+
+	pinned, err := c.PinFact(ctx, "2023-02-26", created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	today, err := c.Today(ctx, client.TodayOptions{Date: "2023-02-26"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if today.Date != "2023-02-26" || today.Fact.ID != pinned.Fact.ID {
+		t.Errorf("want pinned fact %d, got %+v", pinned.Fact.ID, today)
+	}
+
+	if err := c.UnpinFact(ctx, "2023-02-26"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.DeleteFact(ctx, created.ID, updated.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.Fact(ctx, created.ID)
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("want %v, got %v", client.ErrNotFound, err)
+	}
+
+	var e *client.Error
+	if !errors.As(err, &e) || e.StatusCode != http.StatusNotFound || e.Message != "not found" {
+		t.Errorf("want a 404 error, got %#v", err)
+	}
+
+	_, err = c.RandomFact(ctx, client.RandomOptions{})
+	if !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("want %v, got %v", client.ErrNotFound, err)
+	}
+}
+
+func TestForbidden(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, service.WithAuthorizer("secret"))
+	defer cleanup()
+
+	c := newTestClient(t, ts, client.WithAuthorization("wrong"))
+
+	_, err := c.CreateFact(context.TODO(), client.NewFact{Content: "a fact"})
+	if !errors.Is(err, client.ErrForbidden) {
+		t.Errorf("want %v, got %v", client.ErrForbidden, err)
+	}
+}
+
+func TestIterator(t *testing.T) {
+	ts, _, cleanup := newTestServer(t)
+	defer cleanup()
+
+	c := newTestClient(t, ts)
+	ctx := context.TODO()
+
+	var facts []client.NewFact
+	var want []string
+	for i := 0; i < 7; i++ {
+		content := fmt.Sprintf("fact %d", i)
+		facts = append(facts, client.NewFact{Content: content})
+		want = append(want, content)
+	}
+
+	result, err := c.CreateFacts(ctx, facts, client.BatchModeAtomic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created != len(facts) {
+		t.Fatalf("want %d facts created, got %d", len(facts), result.Created)
+	}
+
+	for _, pageSize := range []int{1, 3, 7, 10} {
+		t.Run(fmt.Sprint(pageSize), func(t *testing.T) {
+			var got []string
+			it := c.Facts(ctx, client.ListOptions{PageSize: pageSize})
+			for it.Next() {
+				got = append(got, it.Fact().Content)
+			}
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(want, got) {
+				t.Errorf("want facts %q, got facts %q", want, got)
+			}
+		})
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		it := c.Facts(ctx, client.ListOptions{SourceStatus: "broken"})
+		if it.Next() {
+			t.Errorf("want no facts, got %+v", it.Fact())
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestBatchRejected(t *testing.T) {
+	ts, _, cleanup := newTestServer(t)
+	defer cleanup()
+
+	c := newTestClient(t, ts)
+
+	result, err := c.CreateFacts(context.TODO(), []client.NewFact{{Content: "a fact"}, {}}, "")
+	if !errors.Is(err, client.ErrBadRequest) {
+		t.Fatalf("want %v, got %v", client.ErrBadRequest, err)
+	}
+
+	if len(result.Results) != 2 || result.Results[1].Status != http.StatusBadRequest {
+		t.Errorf("want the second fact reported as invalid, got %+v", result)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	src, _, cleanup := newTestServer(t)
+	defer cleanup()
+
+	dst, dstRepo, cleanup := newTestServer(t)
+	defer cleanup()
+
+	ctx := context.TODO()
+	from := newTestClient(t, src)
+	to := newTestClient(t, dst)
+
+	if _, err := from.CreateFacts(ctx, []client.NewFact{{Content: "one"}, {Content: "two"}}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var dump bytes.Buffer
+	n := 0
+	err := from.Export(ctx, client.ExportOptions{}, func(rec client.FactRecord) error {
+		n++
+		fmt.Fprintf(&dump, `{"id": %d, "content": %q, "weight": %v}`+"\n", rec.ID, rec.Content, rec.Weight)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Fatalf("want 2 facts exported, got %d", n)
+	}
+
+	result, err := to.Import(ctx, bytes.NewReader(dump.Bytes()), client.ImportOptions{Preserve: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Imported != 2 {
+		t.Fatalf("want 2 facts imported, got %+v", result)
+	}
+
+	f, err := dstRepo.Fact(ctx, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Content != "two" {
+		t.Errorf("want content %q, got %q", "two", f.Content)
+	}
+
+	stop := errors.New("stop")
+	err = from.Export(ctx, client.ExportOptions{}, func(client.FactRecord) error { return stop })
+	if !errors.Is(err, stop) {
+		t.Errorf("want %v, got %v", stop, err)
+	}
+}
+
+// flaky fails the first n requests it sees after passing them on, like a
+// proxy timing out on a slow backend.
+func flaky(n int32, next http.Handler) (http.Handler, *int32) {
+	var seen int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&seen, 1) <= n {
+			next.ServeHTTP(httptest.NewRecorder(), r)
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}), &seen
+}
+
+func TestRetries(t *testing.T) {
+	ts, repo, cleanup := newTestServer(t)
+	defer cleanup()
+
+	handler, seen := flaky(2, ts.Config.Handler)
+	ts.Config.Handler = handler
+
+	c := newTestClient(t, ts)
+	ctx := context.TODO()
+
+	f, err := c.CreateFact(ctx, client.NewFact{Content: "a fact"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(seen); got != 3 {
+		t.Errorf("want 3 attempts, got %d", got)
+	}
+
+	facts, err := repo.Facts(ctx, service.FactsQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(facts) != 1 || facts[0].ID != f.ID {
+		t.Errorf("want only fact %d to be created, got %+v", f.ID, facts)
+	}
+
+	// Updates aren't retried.
+	atomic.StoreInt32(seen, 0)
+	_, err = c.UpdateFact(ctx, f.ID, client.FactUpdate{Weight: float(2)})
+	if !errors.Is(err, client.ErrServer) {
+		t.Errorf("want %v, got %v", client.ErrServer, err)
+	}
+
+	// Nor is anything once the retries run out.
+	atomic.StoreInt32(seen, -2)
+	_, err = c.Fact(ctx, f.ID)
+	if !errors.Is(err, client.ErrServer) {
+		t.Errorf("want %v, got %v", client.ErrServer, err)
+	}
+}
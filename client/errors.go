@@ -0,0 +1,77 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrBadRequest         = errors.New("bad request")
+	ErrForbidden          = errors.New("forbidden")
+	ErrNotFound           = errors.New("not found")
+	ErrNotAcceptable      = errors.New("not acceptable")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrUnprocessable      = errors.New("unprocessable")
+	ErrServer             = errors.New("server error")
+)
+
+// Error is returned when the server rejects a request. It unwraps to one
+// of the sentinel errors in this package, so callers can check for, say,
+// a missing fact with errors.Is(err, ErrNotFound).
+type Error struct {
+	StatusCode int
+
+	// Message is the server's explanation.
+	Message string
+
+	body []byte
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("factoid: %s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+func (e *Error) Unwrap() error {
+	switch {
+	case e.StatusCode == http.StatusBadRequest:
+		return ErrBadRequest
+	case e.StatusCode == http.StatusUnauthorized, e.StatusCode == http.StatusForbidden:
+		return ErrForbidden
+	case e.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case e.StatusCode == http.StatusNotAcceptable:
+		return ErrNotAcceptable
+	case e.StatusCode == http.StatusConflict:
+		return ErrConflict
+	case e.StatusCode == http.StatusPreconditionFailed:
+		return ErrPreconditionFailed
+	case e.StatusCode == http.StatusUnprocessableEntity:
+		return ErrUnprocessable
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ErrServer
+	}
+	return nil
+}
+
+func errorFromResponse(rsp *http.Response) *Error {
+	blob, _ := io.ReadAll(io.LimitReader(rsp.Body, 1<<20))
+	e := &Error{StatusCode: rsp.StatusCode, body: blob}
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(blob, &body); err == nil && body.Error != "" {
+		e.Message = body.Error
+	} else if msg := strings.TrimSpace(string(blob)); msg != "" {
+		e.Message = msg
+	} else {
+		e.Message = strings.ToLower(http.StatusText(rsp.StatusCode))
+	}
+
+	return e
+}
@@ -0,0 +1,459 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Fact struct {
+	ID        int64      `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Content   string     `json:"content"`
+	Source    string     `json:"source"`
+	Citations []Citation `json:"citations"`
+	Weight    float64    `json:"weight"`
+	Version   int64      `json:"version"`
+}
+
+type Citation struct {
+	URL        string `json:"url"`
+	Title      string `json:"title,omitempty"`
+	Author     string `json:"author,omitempty"`
+	Publisher  string `json:"publisher,omitempty"`
+	Accessed   string `json:"accessed,omitempty"`
+	ArchiveURL string `json:"archive_url,omitempty"`
+}
+
+// NewFact is a fact to be created.
+type NewFact struct {
+	Content   string     `json:"content"`
+	Source    string     `json:"source,omitempty"`
+	Citations []Citation `json:"citations,omitempty"`
+
+	// Weight defaults to 1 if nil.
+	Weight *float64 `json:"weight,omitempty"`
+}
+
+// FactUpdate holds the changes to make to a fact. Nil fields are left
+// as they are.
+type FactUpdate struct {
+	Content   *string     `json:"content,omitempty"`
+	Source    *string     `json:"source,omitempty"`
+	Citations *[]Citation `json:"citations,omitempty"`
+	Weight    *float64    `json:"weight,omitempty"`
+
+	// Version, if not zero, is the version the fact must be at for the
+	// update to happen. Otherwise the update fails with
+	// ErrPreconditionFailed.
+	Version int64 `json:"-"`
+}
+
+// ListOptions narrows down which facts are listed.
+type ListOptions struct {
+	// SourceStatus, if set, is one of "ok", "broken" or "unchecked".
+	SourceStatus string
+
+	// PageSize is how many facts are fetched per request. It defaults to
+	// 100.
+	PageSize int
+}
+
+// Page is one page of a listing.
+type Page struct {
+	Facts []Fact `json:"facts"`
+
+	// Next is the cursor for the following page, empty on the last one.
+	Next string `json:"next"`
+}
+
+const (
+	StrategyUniform     = "uniform"
+	StrategyWeighted    = "weighted"
+	StrategyFavorRecent = "favor-recent"
+	StrategyFavorUnseen = "favor-unseen"
+)
+
+// RandomOptions narrows down how random facts are chosen.
+type RandomOptions struct {
+	// Seed makes the choice repeatable.
+	Seed string
+
+	// Exclude lists the IDs of facts that must not be chosen.
+	Exclude []int64
+
+	// Count is how many distinct facts to choose, 1 if zero.
+	Count int
+
+	// Session is "new" to start a session, or a token returned by an
+	// earlier call. Facts aren't repeated within a session until all of
+	// them have been seen.
+	Session string
+
+	// Strategy is one of the Strategy constants.
+	Strategy string
+}
+
+type RandomResult struct {
+	Facts []Fact
+
+	// Session is the token to pass as RandomOptions.Session to continue
+	// the session.
+	Session string
+}
+
+// TodayOptions chooses which day's fact to get.
+type TodayOptions struct {
+	// Date is formatted as YYYY-MM-DD and defaults to today.
+	Date string
+
+	// TZ is the IANA time zone deciding when today is, UTC by default.
+	TZ string
+}
+
+type DailyFact struct {
+	Date string `json:"date"`
+	Fact Fact   `json:"fact"`
+}
+
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best-effort"
+)
+
+type BatchResult struct {
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Results []BatchItemResult `json:"results"`
+}
+
+type BatchItemResult struct {
+	Index  int    `json:"index"`
+	Status int    `json:"status"`
+	Fact   *Fact  `json:"fact,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// FactRecord is a fact as it appears in an export.
+type FactRecord struct {
+	Fact
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	ServedCount  int64      `json:"served_count,omitempty"`
+	LastServedAt *time.Time `json:"last_served_at,omitempty"`
+}
+
+type ExportOptions struct {
+	// IncludeDeleted exports soft-deleted facts too.
+	IncludeDeleted bool
+
+	// Metadata exports how often each fact has been served.
+	Metadata bool
+}
+
+type ImportOptions struct {
+	// Preserve keeps the IDs, timestamps, versions and statistics of the
+	// imported facts.
+	Preserve bool
+}
+
+type ImportResult struct {
+	Imported int              `json:"imported"`
+	Skipped  int              `json:"skipped"`
+	Rejected []ImportRejected `json:"rejected"`
+}
+
+type ImportRejected struct {
+	Line  int    `json:"line"`
+	ID    int64  `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// Fact gets the fact identified by id.
+func (c *Client) Fact(ctx context.Context, id int64) (Fact, error) {
+	var body struct {
+		Fact Fact `json:"fact"`
+	}
+	err := c.doJSON(ctx, request{
+		method: http.MethodGet,
+		path:   "/v1/fact/" + strconv.FormatInt(id, 10),
+	}, &body)
+	return body.Fact, err
+}
+
+// ListFacts gets one page of facts, starting after the cursor, which is
+// empty for the first page.
+func (c *Client) ListFacts(ctx context.Context, opts ListOptions, cursor string) (Page, error) {
+	query := url.Values{}
+	if opts.SourceStatus != "" {
+		query.Set("source_status", opts.SourceStatus)
+	}
+	if opts.PageSize > 0 {
+		query.Set("limit", strconv.Itoa(opts.PageSize))
+	}
+	if cursor != "" {
+		query.Set("after", cursor)
+	}
+
+	var page Page
+	err := c.doJSON(ctx, request{
+		method: http.MethodGet,
+		path:   "/v1/facts",
+		query:  query,
+	}, &page)
+	return page, err
+}
+
+// Facts iterates over every fact, fetching them a page at a time.
+func (c *Client) Facts(ctx context.Context, opts ListOptions) *FactIterator {
+	if opts.PageSize <= 0 {
+		opts.PageSize = 100
+	}
+	return &FactIterator{ctx: ctx, c: c, opts: opts}
+}
+
+// RandomFact gets a random fact.
+func (c *Client) RandomFact(ctx context.Context, opts RandomOptions) (Fact, error) {
+	opts.Count = 0
+	result, err := c.Random(ctx, opts)
+	if err != nil {
+		return Fact{}, err
+	}
+	return result.Facts[0], nil
+}
+
+// Random gets one or more random facts.
+func (c *Client) Random(ctx context.Context, opts RandomOptions) (RandomResult, error) {
+	query := url.Values{}
+	if opts.Seed != "" {
+		query.Set("seed", opts.Seed)
+	}
+	if len(opts.Exclude) > 0 {
+		ids := make([]string, len(opts.Exclude))
+		for i, id := range opts.Exclude {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+		query.Set("exclude", strings.Join(ids, ","))
+	}
+	if opts.Count > 0 {
+		query.Set("count", strconv.Itoa(opts.Count))
+	}
+	if opts.Session != "" {
+		query.Set("session", opts.Session)
+	}
+	if opts.Strategy != "" {
+		query.Set("strategy", opts.Strategy)
+	}
+
+	var body struct {
+		Fact    *Fact  `json:"fact"`
+		Facts   []Fact `json:"facts"`
+		Session string `json:"session"`
+	}
+	err := c.doJSON(ctx, request{
+		method: http.MethodGet,
+		path:   "/v1/fact/rand",
+		query:  query,
+	}, &body)
+	if err != nil {
+		return RandomResult{}, err
+	}
+
+	result := RandomResult{Facts: body.Facts, Session: body.Session}
+	if body.Fact != nil {
+		result.Facts = []Fact{*body.Fact}
+	}
+	return result, nil
+}
+
+// Today gets the fact of the day.
+func (c *Client) Today(ctx context.Context, opts TodayOptions) (DailyFact, error) {
+	query := url.Values{}
+	if opts.Date != "" {
+		query.Set("date", opts.Date)
+	}
+	if opts.TZ != "" {
+		query.Set("tz", opts.TZ)
+	}
+
+	var daily DailyFact
+	err := c.doJSON(ctx, request{
+		method: http.MethodGet,
+		path:   "/v1/fact/today",
+		query:  query,
+	}, &daily)
+	return daily, err
+}
+
+// CreateFact creates a fact. Retries are safe because each call sends
+// its own Idempotency-Key.
+func (c *Client) CreateFact(ctx context.Context, f NewFact) (Fact, error) {
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return Fact{}, err
+	}
+
+	var body struct {
+		Fact Fact `json:"fact"`
+	}
+	err = c.doJSON(ctx, request{
+		method: http.MethodPost,
+		path:   "/v1/facts",
+		header: http.Header{"Idempotency-Key": {key}},
+		in:     f,
+	}, &body)
+	return body.Fact, err
+}
+
+// CreateFacts creates many facts in one request. mode is one of the
+// BatchMode constants, BatchModeAtomic if empty.
+//
+// If an atomic batch is rejected because some facts are invalid, the
+// error unwraps to ErrBadRequest and the result says which facts were
+// invalid.
+func (c *Client) CreateFacts(ctx context.Context, facts []NewFact, mode string) (BatchResult, error) {
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return BatchResult{}, err
+	}
+
+	query := url.Values{}
+	if mode != "" {
+		query.Set("mode", mode)
+	}
+
+	var result BatchResult
+	err = c.doJSON(ctx, request{
+		method: http.MethodPost,
+		path:   "/v1/facts:batch",
+		query:  query,
+		header: http.Header{"Idempotency-Key": {key}},
+		in:     facts,
+	}, &result)
+
+	var e *Error
+	if errors.As(err, &e) && e.StatusCode == http.StatusBadRequest {
+		json.Unmarshal(e.body, &result)
+	}
+
+	return result, err
+}
+
+// UpdateFact changes the fields of fact id that are set in u.
+func (c *Client) UpdateFact(ctx context.Context, id int64, u FactUpdate) (Fact, error) {
+	var body struct {
+		Fact Fact `json:"fact"`
+	}
+	err := c.doJSON(ctx, request{
+		method: http.MethodPatch,
+		path:   "/v1/fact/" + strconv.FormatInt(id, 10),
+		header: ifMatch(id, u.Version),
+		in:     u,
+	}, &body)
+	return body.Fact, err
+}
+
+// DeleteFact deletes fact id. If version isn't zero, the fact is only
+// deleted if it's still at that version, otherwise the error unwraps to
+// ErrPreconditionFailed.
+func (c *Client) DeleteFact(ctx context.Context, id, version int64) error {
+	return c.doJSON(ctx, request{
+		method: http.MethodDelete,
+		path:   "/v1/fact/" + strconv.FormatInt(id, 10),
+		header: ifMatch(id, version),
+	}, nil)
+}
+
+// PinFact makes fact id the fact of the day for date, formatted as
+// YYYY-MM-DD.
+func (c *Client) PinFact(ctx context.Context, date string, id int64) (DailyFact, error) {
+	var daily DailyFact
+	err := c.doJSON(ctx, request{
+		method: http.MethodPut,
+		path:   "/v1/today/" + url.PathEscape(date),
+		in:     map[string]int64{"id": id},
+	}, &daily)
+	return daily, err
+}
+
+// UnpinFact lets the server choose the fact of the day for date again.
+func (c *Client) UnpinFact(ctx context.Context, date string) error {
+	return c.doJSON(ctx, request{
+		method: http.MethodDelete,
+		path:   "/v1/today/" + url.PathEscape(date),
+	}, nil)
+}
+
+// Export calls fn with every fact on the server as it's downloaded. If
+// fn returns an error, the export stops and the error is returned.
+func (c *Client) Export(ctx context.Context, opts ExportOptions, fn func(FactRecord) error) error {
+	query := url.Values{}
+	if opts.IncludeDeleted {
+		query.Set("deleted", "true")
+	}
+	if opts.Metadata {
+		query.Set("metadata", "true")
+	}
+
+	rsp, err := c.do(ctx, request{
+		method: http.MethodGet,
+		path:   "/v1/export",
+		query:  query,
+		header: http.Header{"Accept": {"application/x-ndjson"}},
+	})
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(rsp.Body))
+	for {
+		var rec FactRecord
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("client: reading export: %w", err)
+		}
+
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// Import sends facts in the format written by Export to the server.
+// Requests whose body can't be rewound, unlike a *bytes.Reader, aren't
+// retried.
+func (c *Client) Import(ctx context.Context, r io.Reader, opts ImportOptions) (ImportResult, error) {
+	query := url.Values{}
+	if opts.Preserve {
+		query.Set("preserve", "true")
+	}
+
+	var result ImportResult
+	err := c.doJSON(ctx, request{
+		method: http.MethodPost,
+		path:   "/v1/import",
+		query:  query,
+		header: http.Header{"Content-Type": {"application/x-ndjson"}},
+		body:   r,
+	}, &result)
+	return result, err
+}
+
+func ifMatch(id, version int64) http.Header {
+	if version == 0 {
+		return nil
+	}
+	return http.Header{"If-Match": {fmt.Sprintf(`"%d-%d"`, id, version)}}
+}
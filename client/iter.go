@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+)
+
+// FactIterator walks through a listing of facts, fetching pages as it
+// goes.
+//
+//	it := c.Facts(ctx, client.ListOptions{})
+//	for it.Next() {
+//		fmt.Println(it.Fact().Content)
+//	}
+//	if err := it.Err(); err != nil {
+//		// ...
+//	}
+type FactIterator struct {
+	ctx  context.Context
+	c    *Client
+	opts ListOptions
+
+	page    []Fact
+	i       int
+	cursor  string
+	fetched bool
+	err     error
+}
+
+// Next advances to the next fact, reporting whether there is one.
+func (it *FactIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.i++
+	for it.i >= len(it.page) {
+		if it.fetched && it.cursor == "" {
+			return false
+		}
+
+		page, err := it.c.ListFacts(it.ctx, it.opts, it.cursor)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		it.page, it.i = page.Facts, 0
+		it.cursor = page.Next
+		it.fetched = true
+	}
+
+	return true
+}
+
+// Fact returns the current fact.
+func (it *FactIterator) Fact() Fact {
+	return it.page[it.i]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *FactIterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+var (
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|</(?:div|p|li)>`)
+	htmlTag   = regexp.MustCompile(`<[a-zA-Z/!][^>]*>`)
+	ankiSound = regexp.MustCompile(`\[sound:[^\]]*\]`)
+	ankiCloze = regexp.MustCompile(`\{\{c\d+::(.*?)(?:::[^}]*)?\}\}`)
+)
+
+// readAnki reads a fact from every note of an Anki deck, either a .apkg
+// package or notes exported as plain text. The fact is the note's first
+// field unless -content-col picks another; notes have no source unless
+// -source-col picks a field for it.
+func readAnki(r io.Reader, opts importOptions) ([]importRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Notes have no header.
+	opts.header = "no"
+
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return readAnkiPackage(data, opts)
+	}
+	return readAnkiText(data, opts)
+}
+
+// readAnkiText reads notes exported as plain text. Anki describes the
+// file in header lines such as "#separator:tab" and "#deck column:3";
+// without them, fields are separated by tabs and may contain HTML.
+func readAnkiText(data []byte, opts importOptions) ([]importRow, error) {
+	var (
+		comma   = '\t'
+		isHTML  = true
+		skip    = make(map[int]bool)
+		headers int
+	)
+
+	br := bufio.NewReader(bytes.NewReader(data))
+	for {
+		b, err := br.Peek(1)
+		if err != nil || b[0] != '#' {
+			break
+		}
+
+		line, _ := br.ReadString('\n')
+		headers++
+
+		key, value, _ := strings.Cut(strings.TrimSpace(line[1:]), ":")
+		switch key {
+		case "separator":
+			sep, ok := ankiSeparators[strings.ToLower(value)]
+			if !ok {
+				if len(value) != 1 {
+					return nil, fmt.Errorf("line %d: unknown separator %q", headers, value)
+				}
+				sep = rune(value[0])
+			}
+			comma = sep
+		case "html":
+			isHTML = value == "true"
+		case "guid column", "notetype column", "deck column", "tags column":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("line %d: invalid column %q", headers, value)
+			}
+			skip[n-1] = true
+		}
+	}
+
+	cr := csv.NewReader(br)
+	cr.Comma = comma
+	cr.LazyQuotes = true
+	cr.FieldsPerRecord = -1
+
+	return readRecords(func() ([]string, int, error) {
+		record, err := cr.Read()
+		if err != nil {
+			return nil, 0, err
+		}
+		line, _ := cr.FieldPos(0)
+
+		fields := make([]string, 0, len(record))
+		for i, field := range record {
+			if !skip[i] {
+				fields = append(fields, ankiText(field, isHTML))
+			}
+		}
+		return fields, headers + line, nil
+	}, opts, 0)
+}
+
+var ankiSeparators = map[string]rune{
+	"tab":       '\t',
+	"comma":     ',',
+	"semicolon": ';',
+	"space":     ' ',
+	"pipe":      '|',
+	"colon":     ':',
+}
+
+// readAnkiPackage reads the notes in a .apkg file, a zip holding the
+// deck's SQLite database.
+func readAnkiPackage(data []byte, opts importOptions) ([]importRow, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*zip.File)
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	collection := files["collection.anki21"]
+	if collection == nil {
+		if files["collection.anki21b"] != nil {
+			return nil, errors.New("this package needs a newer Anki, export the deck again with \"Support older Anki versions\" checked")
+		}
+		collection = files["collection.anki2"]
+	}
+	if collection == nil {
+		return nil, errors.New("no collection in the Anki package")
+	}
+
+	// SQLite can only open files.
+	path, err := extract(collection)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	notes, err := db.Query("SELECT flds FROM notes ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("reading Anki notes: %w", err)
+	}
+	defer notes.Close()
+
+	var records [][]string
+	for notes.Next() {
+		var flds string
+		if err := notes.Scan(&flds); err != nil {
+			return nil, err
+		}
+
+		fields := strings.Split(flds, "\x1f")
+		for i := range fields {
+			fields[i] = ankiText(fields[i], true)
+		}
+		records = append(records, fields)
+	}
+	if err := notes.Err(); err != nil {
+		return nil, err
+	}
+
+	return readRecords(func() ([]string, int, error) {
+		if len(records) == 0 {
+			return nil, 0, io.EOF
+		}
+		record := records[0]
+		records = records[1:]
+		return record, 0, nil
+	}, opts, 0)
+}
+
+// extract copies f to a temporary file and returns its path.
+func extract(f *zip.File) (string, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp("", "factoidctl-*.anki2")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+// ankiText turns a field of a note into plain text, dropping sounds,
+// revealing clozes and, if the field is HTML, its markup.
+func ankiText(field string, isHTML bool) string {
+	field = ankiSound.ReplaceAllString(field, "")
+	field = ankiCloze.ReplaceAllString(field, "$1")
+	if isHTML {
+		field = htmlBreak.ReplaceAllString(field, " ")
+		field = htmlTag.ReplaceAllString(field, "")
+		field = html.UnescapeString(field)
+	}
+	return strings.Join(strings.Fields(field), " ")
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/connorkuehl/factoid/client"
+)
+
+func randCommand(ctx context.Context, e *env, args []string) error {
+	flags := e.flags("rand", "")
+	var (
+		count    = flags.Int("n", 1, "how many distinct facts to print")
+		seed     = flags.String("seed", "", "make the choice repeatable")
+		strategy = flags.String("strategy", "", "uniform, weighted, favor-recent or favor-unseen")
+	)
+	if err := e.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return usageError("rand takes no arguments")
+	}
+
+	result, err := e.client.Random(ctx, client.RandomOptions{
+		Seed:     *seed,
+		Count:    *count,
+		Strategy: *strategy,
+	})
+	if err != nil {
+		return err
+	}
+
+	if *count > 1 {
+		return e.printFacts(result.Facts)
+	}
+	if len(result.Facts) == 0 {
+		return errors.New("no facts")
+	}
+	return e.printFact(result.Facts[0])
+}
+
+func getCommand(ctx context.Context, e *env, args []string) error {
+	flags := e.flags("get", "<id>")
+	if err := e.parse(flags, args); err != nil {
+		return err
+	}
+
+	id, err := factID(flags)
+	if err != nil {
+		return err
+	}
+
+	f, err := e.client.Fact(ctx, id)
+	if err != nil {
+		return err
+	}
+	return e.printFact(f)
+}
+
+func listCommand(ctx context.Context, e *env, args []string) error {
+	flags := e.flags("list", "")
+	var (
+		limit        = flags.Int("limit", 0, "print at most this many facts, all of them if 0")
+		sourceStatus = flags.String("source-status", "", "only list facts whose sources are ok, broken or unchecked")
+	)
+	if err := e.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return usageError("list takes no arguments")
+	}
+
+	opts := client.ListOptions{SourceStatus: *sourceStatus}
+	if *limit > 0 && *limit < 100 {
+		opts.PageSize = *limit
+	}
+
+	var facts []client.Fact
+	it := e.client.Facts(ctx, opts)
+	for (*limit <= 0 || len(facts) < *limit) && it.Next() {
+		facts = append(facts, it.Fact())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	return e.printFacts(facts)
+}
+
+func addCommand(ctx context.Context, e *env, args []string) error {
+	flags := e.flags("add", "")
+	var (
+		content = flags.String("content", "", "the fact")
+		source  = flags.String("source", "", "URL backing up the fact")
+		weight  = flags.Float64("weight", 1, "how likely the fact is to be chosen at random, relative to others")
+	)
+	if err := e.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return usageError("add takes no arguments, did you mean -content?")
+	}
+	if *content == "" {
+		return usageError("-content is required")
+	}
+
+	nf := client.NewFact{Content: *content, Source: *source}
+	if isSet(flags, "weight") {
+		nf.Weight = weight
+	}
+
+	f, err := e.client.CreateFact(ctx, nf)
+	if err != nil {
+		return err
+	}
+	return e.printFact(f)
+}
+
+func rmCommand(ctx context.Context, e *env, args []string) error {
+	flags := e.flags("rm", "<id>")
+	version := flags.Int64("version", 0, "only delete the fact if it's still at this version")
+	if err := e.parse(flags, args); err != nil {
+		return err
+	}
+
+	id, err := factID(flags)
+	if err != nil {
+		return err
+	}
+
+	return e.client.DeleteFact(ctx, id, *version)
+}
+
+func importCommand(ctx context.Context, e *env, args []string) error {
+	flags := e.flags("import", "<file>")
+	var (
+		format     = flags.String("format", "", "csv, json, ndjson, yaml, markdown or anki, guessed from the file name if not set")
+		bestEffort = flags.Bool("best-effort", false, "create the valid facts even if some are invalid")
+		preserve   = flags.Bool("preserve", false, "restore an NDJSON export, keeping the IDs, timestamps and versions of its facts")
+
+		opts importOptions
+	)
+	flags.StringVar(&opts.contentCol, "content-col", "", "name or number of the content column (CSV and Anki only)")
+	flags.StringVar(&opts.sourceCol, "source-col", "", "name or number of the source column (CSV and Anki only)")
+	flags.StringVar(&opts.header, "header", "auto", "whether the first row is a header: auto, yes or no (CSV only)")
+	flags.IntVar(&opts.batchSize, "batch-size", 1000, "facts per request, each created all or nothing unless -best-effort")
+	flags.IntVar(&opts.workers, "workers", 4, "requests to send at once")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "check the facts without creating anything")
+	flags.StringVar(&opts.checkpoint, "checkpoint", "", "file recording the facts imported so far, to resume an interrupted import")
+	if err := e.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return usageError("import takes exactly one file, or - for stdin")
+	}
+	if opts.batchSize < 1 || opts.batchSize > 10000 {
+		return usageError("-batch-size must be between 1 and 10000")
+	}
+
+	path := flags.Arg(0)
+	if *format == "" {
+		*format = guessFormat(path)
+	}
+
+	read, ok := factReaders[*format]
+	if !ok {
+		return usageError(fmt.Sprintf("unknown import format %q", *format))
+	}
+	if *preserve && *format != "ndjson" {
+		return usageError("-preserve only applies to NDJSON exports")
+	}
+
+	var r io.Reader = e.stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	if *preserve {
+		return e.restore(ctx, r)
+	}
+
+	opts.mode = client.BatchModeAtomic
+	if *bestEffort {
+		opts.mode = client.BatchModeBestEffort
+	}
+	return e.importFacts(ctx, r, read, opts)
+}
+
+// restore imports facts written by the export command as they were.
+func (e *env) restore(ctx context.Context, r io.Reader) error {
+	result, err := e.client.Import(ctx, r, client.ImportOptions{Preserve: true})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range result.Rejected {
+		fmt.Fprintf(e.stderr, "line %d: %s\n", r.Line, r.Error)
+	}
+
+	if e.output == outputJSON {
+		return e.printJSON(result)
+	}
+	_, err = fmt.Fprintf(e.stdout, "imported %d facts, skipped %d, rejected %d\n",
+		result.Imported, result.Skipped, len(result.Rejected))
+	return err
+}
+
+func exportCommand(ctx context.Context, e *env, args []string) error {
+	flags := e.flags("export", "")
+	var (
+		file     = flags.String("f", "-", "file to write to, - for stdout")
+		deleted  = flags.Bool("deleted", false, "include deleted facts")
+		metadata = flags.Bool("metadata", false, "include how often each fact has been served")
+	)
+	if err := e.parse(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return usageError("export takes no arguments, did you mean -f?")
+	}
+
+	w := e.stdout
+	var out *os.File
+	if *file != "-" {
+		var err error
+		out, err = os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		w = out
+	}
+
+	enc := json.NewEncoder(w)
+	err := e.client.Export(ctx, client.ExportOptions{
+		IncludeDeleted: *deleted,
+		Metadata:       *metadata,
+	}, func(rec client.FactRecord) error {
+		return enc.Encode(rec)
+	})
+	if err != nil {
+		return err
+	}
+
+	if out != nil {
+		return out.Close()
+	}
+	return nil
+}
+
+// factID parses the only positional argument as a fact ID.
+func factID(flags *flag.FlagSet) (int64, error) {
+	if flags.NArg() != 1 {
+		return 0, usageError(flags.Name() + " takes exactly one fact ID")
+	}
+
+	id, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, usageError(fmt.Sprintf("invalid fact ID %q", flags.Arg(0)))
+	}
+	return id, nil
+}
+
+func isSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
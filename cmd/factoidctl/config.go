@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config holds the settings that may come from the config file, the
+// environment or the command line, in increasing order of precedence.
+type config struct {
+	Server        string `yaml:"server"`
+	Authorization string `yaml:"authorization"`
+	Output        string `yaml:"output"`
+}
+
+// defaultConfigPath returns $FACTOIDCTL_CONFIG if set, otherwise
+// factoidctl/config.yaml in the user's config directory.
+func defaultConfigPath() string {
+	if path := os.Getenv("FACTOIDCTL_CONFIG"); path != "" {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "factoidctl", "config.yaml")
+}
+
+// loadConfig reads the config file at path. A missing file is only an
+// error if the user asked for it explicitly.
+func loadConfig(path string, required bool) (config, error) {
+	var c config
+	if path == "" {
+		return c, nil
+	}
+
+	blob, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !required {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(blob))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && err != io.EOF {
+		return c, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// applyEnv overrides c with the FACTOIDCTL_* environment variables that
+// are set.
+func (c *config) applyEnv() {
+	if v := os.Getenv("FACTOIDCTL_SERVER"); v != "" {
+		c.Server = v
+	}
+	if v := os.Getenv("FACTOIDCTL_AUTHORIZATION"); v != "" {
+		c.Authorization = v
+	}
+	if v := os.Getenv("FACTOIDCTL_OUTPUT"); v != "" {
+		c.Output = v
+	}
+}
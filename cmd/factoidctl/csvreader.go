@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/connorkuehl/factoid/client"
+)
+
+// readCSV reads a fact from every row of comma-separated values.
+func readCSV(r io.Reader, opts importOptions) ([]importRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	return readRecords(func() ([]string, int, error) {
+		record, err := cr.Read()
+		if err != nil {
+			return nil, 0, err
+		}
+		line, _ := cr.FieldPos(0)
+		return record, line, nil
+	}, opts, 2)
+}
+
+// readRecords reads a fact from every record returned by next, until it
+// returns io.EOF. The content and source are taken from the columns
+// chosen by opts, or the first column and the one at sourcePosition by
+// default. A sourcePosition of 0 means no source.
+func readRecords(next func() (record []string, line int, err error), opts importOptions, sourcePosition int) ([]importRow, error) {
+	first, firstLine, err := next()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var header []string
+	switch opts.header {
+	case "yes":
+		header = first
+	case "auto", "":
+		if isHeader(first, opts.contentCol) {
+			header = first
+		}
+	case "no":
+	default:
+		return nil, usageError(fmt.Sprintf("unknown -header %q, want auto, yes or no", opts.header))
+	}
+
+	contentCol, err := column(header, opts.contentCol, "content", 1)
+	if err != nil {
+		return nil, err
+	}
+	if contentCol < 0 {
+		return nil, usageError("the header has no content column, choose one with -content-col")
+	}
+	sourceCol, err := column(header, opts.sourceCol, "source", sourcePosition)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []importRow
+	if header == nil {
+		rows = append(rows, recordRow(first, firstLine, contentCol, sourceCol))
+	}
+
+	for {
+		record, line, err := next()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		rows = append(rows, recordRow(record, line, contentCol, sourceCol))
+	}
+}
+
+func recordRow(record []string, line, contentCol, sourceCol int) importRow {
+	row := importRow{line: line}
+	if contentCol >= len(record) {
+		row.err = errors.New("missing content column")
+		return row
+	}
+
+	row.fact = client.NewFact{Content: strings.TrimSpace(record[contentCol])}
+	if sourceCol >= 0 && sourceCol < len(record) {
+		row.fact.Source = strings.TrimSpace(record[sourceCol])
+	}
+	return row
+}
+
+// isHeader reports whether record looks like a header, by naming the
+// content column.
+func isHeader(record []string, contentCol string) bool {
+	name := "content"
+	if contentCol != "" {
+		if _, err := strconv.Atoi(contentCol); err == nil {
+			return false
+		}
+		name = contentCol
+	}
+
+	for _, cell := range record {
+		if strings.EqualFold(strings.TrimSpace(cell), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// column returns the index of the column chosen by spec, or by name or
+// position if spec is empty. It returns -1 if a column chosen by default
+// doesn't exist.
+func column(header []string, spec, name string, position int) (int, error) {
+	if spec == "" {
+		if header == nil {
+			return position - 1, nil
+		}
+		spec = name
+		for i, cell := range header {
+			if strings.EqualFold(strings.TrimSpace(cell), spec) {
+				return i, nil
+			}
+		}
+		return -1, nil
+	}
+
+	if i, err := strconv.Atoi(spec); err == nil {
+		if i < 1 {
+			return 0, usageError(fmt.Sprintf("invalid column %d, columns count from 1", i))
+		}
+		return i - 1, nil
+	}
+
+	if header == nil {
+		return 0, usageError(fmt.Sprintf("column %q can only be found by name if the file has a header", spec))
+	}
+	for i, cell := range header {
+		if strings.EqualFold(strings.TrimSpace(cell), spec) {
+			return i, nil
+		}
+	}
+	return 0, usageError(fmt.Sprintf("no column named %q", spec))
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/connorkuehl/factoid/client"
+)
+
+// importOptions describes how facts are read and uploaded.
+type importOptions struct {
+	// contentCol and sourceCol name a column by its header or by its
+	// position, counting from 1. They default to the "content" and
+	// "source" columns if there's a header, otherwise the first and
+	// second columns. They only apply to formats made of columns.
+	contentCol string
+	sourceCol  string
+
+	// header is "auto", "yes" or "no". Auto treats the first row as a
+	// header if it names the content column.
+	header string
+
+	mode      string
+	batchSize int
+	workers   int
+	dryRun    bool
+
+	// checkpoint, if set, is a file recording which rows have been
+	// imported, so an interrupted import can pick up where it left off.
+	checkpoint string
+}
+
+// importRow is a fact read from a file.
+type importRow struct {
+	// n is the row's number, counting from 1.
+	n int
+
+	// line is where the row starts in the file, or 0 for formats that
+	// aren't made of lines.
+	line int
+
+	fact client.NewFact
+
+	// err explains why the row can't be imported.
+	err error
+}
+
+// where locates r for the user.
+func (r importRow) where() string {
+	if r.line > 0 {
+		return fmt.Sprintf("line %d", r.line)
+	}
+	return fmt.Sprintf("row %d", r.n)
+}
+
+type importSummary struct {
+	Created int `json:"created"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// rowsError reports rows that couldn't be imported, each of which has
+// been explained on stderr already.
+type rowsError int
+
+func (e rowsError) Error() string {
+	if e == 1 {
+		return "1 row failed"
+	}
+	return fmt.Sprintf("%d rows failed", int(e))
+}
+
+// importFacts creates the facts read from r. Every row is checked before
+// anything is sent, and unless the import is best-effort, one invalid row
+// stops the import.
+func (e *env) importFacts(ctx context.Context, r io.Reader, read factReader, opts importOptions) error {
+	done, err := readCheckpoint(opts.checkpoint)
+	if err != nil {
+		return err
+	}
+
+	all, err := read(r, opts)
+	if err != nil {
+		return err
+	}
+	if len(all) == 0 {
+		return errors.New("nothing to import")
+	}
+
+	var (
+		rows    []importRow
+		summary importSummary
+	)
+	for i, row := range all {
+		row.n = i + 1
+
+		if isDone(done, row.n) {
+			summary.Skipped++
+			continue
+		}
+
+		if row.err == nil {
+			row.err = validate(row.fact)
+		}
+		if row.err != nil {
+			fmt.Fprintf(e.stderr, "%s: %v\n", row.where(), row.err)
+			summary.Failed++
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	if opts.dryRun || (summary.Failed > 0 && opts.mode != client.BatchModeBestEffort) {
+		// Count what would have been created, or what wasn't.
+		if opts.dryRun {
+			summary.Created = len(rows)
+		} else {
+			summary.Skipped += len(rows)
+		}
+		if err := e.printSummary(summary, opts.dryRun); err != nil {
+			return err
+		}
+		if summary.Failed > 0 {
+			return rowsError(summary.Failed)
+		}
+		return nil
+	}
+
+	created, failed, err := e.createRows(ctx, rows, opts)
+	summary.Created += created
+	summary.Failed += failed
+
+	if err := e.printSummary(summary, false); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if summary.Failed > 0 {
+		return rowsError(summary.Failed)
+	}
+	return nil
+}
+
+// validate catches the mistakes the server would reject a fact for, so
+// they're reported before anything is sent.
+func validate(f client.NewFact) error {
+	if strings.TrimSpace(f.Content) == "" {
+		return errors.New("content is blank")
+	}
+	if f.Weight != nil && *f.Weight < 0 {
+		return errors.New("weight must not be negative")
+	}
+	for _, c := range f.Citations {
+		if c.URL == "" {
+			return errors.New("citation without a URL")
+		}
+	}
+	return nil
+}
+
+// createRows sends rows to the server in batches, a few at a time. It
+// stops sending batches after one fails outright, and returns that
+// error.
+func (e *env) createRows(ctx context.Context, rows []importRow, opts importOptions) (created, failed int, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var cp *os.File
+	if opts.checkpoint != "" {
+		cp, err = os.OpenFile(opts.checkpoint, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return 0, 0, err
+		}
+		defer cp.Close()
+	}
+
+	batches := make(chan []importRow)
+	go func() {
+		defer close(batches)
+		for len(rows) > 0 {
+			size := opts.batchSize
+			if size > len(rows) {
+				size = len(rows)
+			}
+			select {
+			case batches <- rows[:size]:
+			case <-ctx.Done():
+				return
+			}
+			rows = rows[size:]
+		}
+	}()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+
+	// finish records the outcome of a batch. Batches that were created
+	// are written to the checkpoint, even if some of their rows failed.
+	finish := func(batch []importRow, result client.BatchResult, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		for _, r := range result.Results {
+			if r.Error != "" && r.Status != http.StatusFailedDependency && r.Index < len(batch) {
+				fmt.Fprintf(e.stderr, "%s: %s\n", batch[r.Index].where(), r.Error)
+			}
+		}
+
+		if err != nil {
+			failed += len(batch)
+			if ctx.Err() != nil {
+				// Cancelled because another batch failed.
+				return
+			}
+			if len(result.Results) == 0 {
+				first, last := batch[0], batch[len(batch)-1]
+				if first.line > 0 {
+					fmt.Fprintf(e.stderr, "lines %d-%d: %v\n", first.line, last.line, err)
+				} else {
+					fmt.Fprintf(e.stderr, "rows %d-%d: %v\n", first.n, last.n, err)
+				}
+			}
+			if firstErr == nil {
+				firstErr = err
+				cancel()
+			}
+			return
+		}
+
+		created += result.Created
+		failed += result.Failed
+
+		if cp != nil {
+			fmt.Fprintf(cp, "%d-%d\n", batch[0].n, batch[len(batch)-1].n)
+		}
+	}
+
+	workers := opts.workers
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				facts := make([]client.NewFact, len(batch))
+				for i, r := range batch {
+					facts[i] = r.fact
+				}
+
+				result, err := e.client.CreateFacts(ctx, facts, opts.mode)
+				finish(batch, result, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Batches that were never sent failed too.
+	failed += len(rows)
+
+	return created, failed, firstErr
+}
+
+func (e *env) printSummary(summary importSummary, dryRun bool) error {
+	if e.output == outputJSON {
+		return e.printJSON(summary)
+	}
+
+	format := "created %d, skipped %d, failed %d\n"
+	if dryRun {
+		format = "would create %d, skip %d, fail %d\n"
+	}
+	_, err := fmt.Fprintf(e.stdout, format, summary.Created, summary.Skipped, summary.Failed)
+	return err
+}
+
+// rowRange is an inclusive range of row numbers.
+type rowRange struct {
+	first, last int
+}
+
+// readCheckpoint reads the ranges of rows a previous import finished. A
+// missing checkpoint means nothing has been done yet.
+func readCheckpoint(path string) ([]rowRange, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var done []rowRange
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			continue
+		}
+
+		var r rowRange
+		if _, err := fmt.Sscanf(text, "%d-%d", &r.first, &r.last); err != nil || r.first > r.last {
+			return nil, fmt.Errorf("%s:%d: invalid checkpoint %q", path, line, text)
+		}
+		done = append(done, r)
+	}
+	return done, s.Err()
+}
+
+func isDone(done []rowRange, n int) bool {
+	for _, r := range done {
+		if n >= r.first && n <= r.last {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestImportCSV(t *testing.T) {
+	tests := []struct {
+		name       string
+		csv        string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+		wantFacts  []string
+	}{
+		{
+			name:       "header",
+			csv:        "Content,Source\nA,https://example.com/a\nB,\n",
+			wantCode:   exitOK,
+			wantStdout: "created 2, skipped 0, failed 0\n",
+			wantFacts:  []string{"A", "B"},
+		},
+		{
+			name:       "column names",
+			csv:        "source,text\nhttps://example.com/a,A\n",
+			args:       []string{"-content-col", "text"},
+			wantCode:   exitOK,
+			wantStdout: "created 1, skipped 0, failed 0\n",
+			wantFacts:  []string{"A"},
+		},
+		{
+			name:       "column numbers",
+			csv:        "https://example.com/a,A\n",
+			args:       []string{"-content-col", "2", "-source-col", "1"},
+			wantCode:   exitOK,
+			wantStdout: "created 1, skipped 0, failed 0\n",
+			wantFacts:  []string{"A"},
+		},
+		{
+			name:       "no header",
+			csv:        "content\n",
+			args:       []string{"-header", "no"},
+			wantCode:   exitOK,
+			wantStdout: "created 1, skipped 0, failed 0\n",
+			wantFacts:  []string{"content"},
+		},
+		{
+			name:       "unknown column",
+			csv:        "content,source\nA,\n",
+			args:       []string{"-source-col", "url"},
+			wantCode:   exitUsage,
+			wantStderr: `no column named "url"`,
+		},
+		{
+			name:       "named column without header",
+			csv:        "A\n",
+			args:       []string{"-header", "no", "-source-col", "url"},
+			wantCode:   exitUsage,
+			wantStderr: "only be found by name if the file has a header",
+		},
+		{
+			name:       "invalid rows",
+			csv:        "content,source\nA\n\n\"\",https://example.com\n",
+			wantCode:   exitBadRequest,
+			wantStdout: "created 0, skipped 1, failed 1\n",
+			wantStderr: "line 4: content is blank\n",
+		},
+		{
+			name:       "missing column",
+			csv:        "source,content\nhttps://example.com\n",
+			wantCode:   exitBadRequest,
+			wantStderr: "line 2: missing content column\n",
+		},
+		{
+			name:       "best effort",
+			csv:        "A\n \nB\n",
+			args:       []string{"-best-effort"},
+			wantCode:   exitBadRequest,
+			wantStdout: "created 2, skipped 0, failed 1\n",
+			wantStderr: "line 2: content is blank\n",
+			wantFacts:  []string{"A", "B"},
+		},
+		{
+			name:       "dry run",
+			csv:        "A\nB\n",
+			args:       []string{"-dry-run"},
+			wantCode:   exitOK,
+			wantStdout: "would create 2, skip 0, fail 0\n",
+		},
+		{
+			name:       "batches",
+			csv:        "A\nB\nC\nD\nE\n",
+			args:       []string{"-batch-size", "2", "-workers", "3"},
+			wantCode:   exitOK,
+			wantStdout: "created 5, skipped 0, failed 0\n",
+			wantFacts:  []string{"A", "B", "C", "D", "E"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, cleanup := newTestServer(t)
+			defer cleanup()
+
+			global := writeConfig(t, ts.URL)
+
+			args := append([]string{"import"}, tt.args...)
+			got := factoidctl(t, global, tt.csv, append(args, "-")...)
+
+			if got.code != tt.wantCode {
+				t.Errorf("want exit code %d, got %d (stderr %q)", tt.wantCode, got.code, got.stderr)
+			}
+
+			if !strings.Contains(got.stdout, tt.wantStdout) {
+				t.Errorf("want stdout to contain %q, got %q", tt.wantStdout, got.stdout)
+			}
+
+			if !strings.Contains(got.stderr, tt.wantStderr) {
+				t.Errorf("want stderr to contain %q, got %q", tt.wantStderr, got.stderr)
+			}
+
+			// Batches are sent concurrently, so the facts may be created
+			// out of order.
+			facts := listContent(t, global)
+			sort.Strings(facts)
+			if strings.Join(facts, "\n") != strings.Join(tt.wantFacts, "\n") {
+				t.Errorf("want facts %q, got %q", tt.wantFacts, facts)
+			}
+		})
+	}
+}
+
+func TestImportCSVCheckpoint(t *testing.T) {
+	ts, cleanup := newTestServer(t)
+	defer cleanup()
+
+	// Fail the second batch.
+	var batches int32
+	next := ts.Config.Handler
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && atomic.AddInt32(&batches, 1) == 2 {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+
+	global := writeConfig(t, ts.URL)
+	checkpoint := filepath.Join(t.TempDir(), "checkpoint")
+	args := []string{"import", "-batch-size", "1", "-workers", "1", "-checkpoint", checkpoint, "-"}
+
+	got := factoidctl(t, global, "A\nB\nC\n", args...)
+	if got.code != exitServer {
+		t.Errorf("want exit code %d, got %d (stderr %q)", exitServer, got.code, got.stderr)
+	}
+	if want := "created 1, skipped 0, failed 2\n"; got.stdout != want {
+		t.Errorf("want stdout %q, got %q", want, got.stdout)
+	}
+	if want := "lines 2-2: "; !strings.Contains(got.stderr, want) {
+		t.Errorf("want stderr to contain %q, got %q", want, got.stderr)
+	}
+
+	blob, err := os.ReadFile(checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "1-1\n"; string(blob) != want {
+		t.Errorf("want checkpoint %q, got %q", want, blob)
+	}
+
+	got = factoidctl(t, global, "A\nB\nC\n", args...)
+	if got.code != exitOK {
+		t.Errorf("want exit code %d, got %d (stderr %q)", exitOK, got.code, got.stderr)
+	}
+	if want := "created 2, skipped 1, failed 0\n"; got.stdout != want {
+		t.Errorf("want stdout %q, got %q", want, got.stdout)
+	}
+
+	facts := listContent(t, global)
+	sort.Strings(facts)
+	if want := []string{"A", "B", "C"}; strings.Join(facts, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want facts %q, got %q", want, facts)
+	}
+}
+
+// listContent returns the content of every fact on the server, in the
+// order it's listed.
+func listContent(t *testing.T, global []string) []string {
+	t.Helper()
+
+	got := factoidctl(t, global, "", "list", "-o", "plain")
+	if got.code != exitOK {
+		t.Fatalf("want exit code %d, got %d (stderr %q)", exitOK, got.code, got.stderr)
+	}
+	return strings.Fields(got.stdout)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// readJSON reads a JSON array of facts, or an object holding one under
+// "facts" like the API's listings.
+func readJSON(r io.Reader, _ importOptions) ([]importRow, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, jsonError(data, dec, err)
+	}
+
+	switch tok {
+	case json.Delim('['):
+		return readJSONArray(data, dec)
+	case json.Delim('{'):
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, jsonError(data, dec, err)
+			}
+
+			if key != "facts" {
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					return nil, jsonError(data, dec, err)
+				}
+				continue
+			}
+
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, jsonError(data, dec, err)
+			}
+			if tok != json.Delim('[') {
+				return nil, errors.New(`"facts" isn't an array`)
+			}
+			return readJSONArray(data, dec)
+		}
+		return nil, errors.New(`no "facts" array in the object`)
+	}
+	return nil, errors.New("want an array of facts")
+}
+
+func readJSONArray(data []byte, dec *json.Decoder) ([]importRow, error) {
+	var rows []importRow
+	for dec.More() {
+		row := importRow{line: lineAt(data, dec.InputOffset())}
+
+		var f importFact
+		err := dec.Decode(&f)
+
+		var syntax *json.SyntaxError
+		if errors.As(err, &syntax) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("line %d: %w", row.line, err)
+		}
+
+		row.fact, row.err = f.newFact(), jsonFieldError(err)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// readNDJSON reads a fact from every line. Exports are NDJSON too, so
+// they can be imported as new facts.
+func readNDJSON(r io.Reader, _ importOptions) ([]importRow, error) {
+	var rows []importRow
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+
+		var f importFact
+		err := json.Unmarshal(s.Bytes(), &f)
+		rows = append(rows, importRow{line: line, fact: f.newFact(), err: jsonFieldError(err)})
+	}
+
+	return rows, s.Err()
+}
+
+// jsonFieldError rephrases err to leave out Go's types.
+func jsonFieldError(err error) error {
+	var typ *json.UnmarshalTypeError
+	if errors.As(err, &typ) {
+		if typ.Field == "" {
+			return fmt.Errorf("a fact can't be a %s", typ.Value)
+		}
+		return fmt.Errorf("%s can't be a %s", typ.Field, typ.Value)
+	}
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) {
+		return errors.New("invalid JSON")
+	}
+	return err
+}
+
+// jsonError locates an error that stops the whole file from being read.
+func jsonError(data []byte, dec *json.Decoder, err error) error {
+	return fmt.Errorf("line %d: %w", lineAt(data, dec.InputOffset()), err)
+}
+
+// lineAt returns the line of the first value at or after offset in data.
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	rest := data[offset:]
+	skipped := len(rest) - len(bytes.TrimLeft(rest, " \t\r\n,:"))
+	return 1 + bytes.Count(data[:int(offset)+skipped], []byte("\n"))
+}
@@ -0,0 +1,246 @@
+// Command factoidctl manages the facts on a factoid server.
+//
+// Usage:
+//
+//	factoidctl [flags] <command> [command flags] [args]
+//
+// The server URL and authorization secret are read from a YAML config
+// file, which can be overridden by FACTOIDCTL_* environment variables and
+// then by flags. Run factoidctl -h for the list of commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/connorkuehl/factoid/client"
+)
+
+// Exit codes. Errors returned by the server map onto these so scripts can
+// tell, say, a missing fact apart from a server that's down.
+const (
+	exitOK         = 0
+	exitError      = 1
+	exitUsage      = 2
+	exitBadRequest = 3
+	exitForbidden  = 4
+	exitNotFound   = 5
+	exitConflict   = 6
+	exitServer     = 7
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputPlain = "plain"
+)
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, e *env, args []string) error
+}
+
+var commands = []command{
+	{name: "rand", summary: "print a random fact", run: randCommand},
+	{name: "get", args: "<id>", summary: "print a fact", run: getCommand},
+	{name: "list", summary: "print all facts", run: listCommand},
+	{name: "add", args: "-content <text> [-source <url>]", summary: "create a fact", run: addCommand},
+	{name: "rm", args: "<id>", summary: "delete a fact", run: rmCommand},
+	{name: "import", args: "<file>", summary: "create facts from a CSV file or restore an export", run: importCommand},
+	{name: "export", summary: "write all facts as NDJSON", run: exportCommand},
+}
+
+// errUsage reports bad flags, which the flag package has already
+// explained to the user.
+var errUsage = errors.New("usage")
+
+// usageError is a mistake in the command line that hasn't been reported
+// yet.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+// env is what commands run with.
+type env struct {
+	client *client.Client
+	output string
+
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	cancel()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("factoidctl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { usage(flags) }
+
+	var (
+		configPath = flags.String("config", defaultConfigPath(), "path to the config file")
+		server     = flags.String("server", "http://localhost:8080", "URL of the factoid server")
+		auth       = flags.String("authorization", "", "secret for write-operations")
+		output     = flags.String("o", outputTable, "output format: table, json or plain")
+	)
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	set := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	required := set["config"] || os.Getenv("FACTOIDCTL_CONFIG") != ""
+	cfg, err := loadConfig(*configPath, required)
+	if err != nil {
+		fmt.Fprintln(stderr, "factoidctl:", err)
+		return exitError
+	}
+	cfg.applyEnv()
+
+	if set["server"] || cfg.Server == "" {
+		cfg.Server = *server
+	}
+	if set["authorization"] || cfg.Authorization == "" {
+		cfg.Authorization = *auth
+	}
+	if set["o"] || cfg.Output == "" {
+		cfg.Output = *output
+	}
+
+	if flags.NArg() == 0 {
+		usage(flags)
+		return exitUsage
+	}
+
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == flags.Arg(0) {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "factoidctl: unknown command %q\n", flags.Arg(0))
+		return exitUsage
+	}
+
+	c, err := client.New(cfg.Server, client.WithAuthorization(cfg.Authorization))
+	if err != nil {
+		fmt.Fprintln(stderr, "factoidctl:", err)
+		return exitUsage
+	}
+
+	e := &env{
+		client: c,
+		output: cfg.Output,
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+	}
+
+	err = cmd.run(ctx, e, flags.Args()[1:])
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errUsage):
+		return exitUsage
+	}
+
+	fmt.Fprintf(stderr, "factoidctl %s: %v\n", cmd.name, err)
+	return exitCode(err)
+}
+
+func exitCode(err error) int {
+	var usage usageError
+	if errors.As(err, &usage) {
+		return exitUsage
+	}
+
+	var rows rowsError
+	if errors.As(err, &rows) {
+		return exitBadRequest
+	}
+
+	var e *client.Error
+	if !errors.As(err, &e) {
+		return exitError
+	}
+
+	switch {
+	case errors.Is(err, client.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, client.ErrForbidden):
+		return exitForbidden
+	case errors.Is(err, client.ErrConflict), errors.Is(err, client.ErrPreconditionFailed):
+		return exitConflict
+	case errors.Is(err, client.ErrServer):
+		return exitServer
+	}
+	return exitBadRequest
+}
+
+func usage(flags *flag.FlagSet) {
+	w := flags.Output()
+	fmt.Fprintln(w, "usage: factoidctl [flags] <command> [command flags] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "flags:")
+	flags.PrintDefaults()
+}
+
+// flags returns the flag set for the named command. The output format
+// can be chosen after the command name too.
+func (e *env) flags(name, args string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	flags.StringVar(&e.output, "o", e.output, "output format: table, json or plain")
+	flags.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: factoidctl %s [flags] %s\n", name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parse parses a command's flags, leaving its positional arguments in
+// flags.Args().
+func (e *env) parse(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+
+	switch e.output {
+	case outputTable, outputJSON, outputPlain:
+	default:
+		return usageError(fmt.Sprintf("unknown output format %q", e.output))
+	}
+
+	return nil
+}
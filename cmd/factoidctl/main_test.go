@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	sqliterepo "github.com/connorkuehl/factoid/internal/repo/sqlite"
+	"github.com/connorkuehl/factoid/internal/service"
+)
+
+func newTestServer(t *testing.T) (*httptest.Server, func()) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliterepo.Schema()); err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+
+	repo := sqliterepo.NewRepo(db)
+	ts := httptest.NewServer(service.New(repo, service.WithAuthorizer("secret")).Routes())
+
+	return ts, func() {
+		ts.Close()
+		db.Close()
+	}
+}
+
+// writeConfig writes a config file for the server at url and returns the
+// global flags that point factoidctl at it.
+func writeConfig(t *testing.T, url string) []string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := "server: " + url + "\nauthorization: secret\n"
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return []string{"-config", path}
+}
+
+type result struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+func factoidctl(t *testing.T, global []string, stdin string, args ...string) result {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	code := run(context.TODO(), append(global, args...), strings.NewReader(stdin), &stdout, &stderr)
+	return result{code: code, stdout: stdout.String(), stderr: stderr.String()}
+}
+
+func TestCommands(t *testing.T) {
+	ts, cleanup := newTestServer(t)
+	defer cleanup()
+
+	global := writeConfig(t, ts.URL)
+
+	tests := []struct {
+		name       string
+		global     []string
+		stdin      string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:     "no command",
+			wantCode: exitUsage,
+		},
+		{
+			name:       "unknown command",
+			args:       []string{"frobnicate"},
+			wantCode:   exitUsage,
+			wantStderr: `unknown command "frobnicate"`,
+		},
+		{
+			name:     "rand without facts",
+			args:     []string{"rand"},
+			wantCode: exitNotFound,
+		},
+		{
+			name:       "add",
+			args:       []string{"add", "-content", "Honey never spoils.", "-source", "https://example.com/honey", "-o", "plain"},
+			wantCode:   exitOK,
+			wantStdout: "Honey never spoils.\n",
+		},
+		{
+			name:       "add without content",
+			args:       []string{"add", "-source", "https://example.com"},
+			wantCode:   exitUsage,
+			wantStderr: "-content is required",
+		},
+		{
+			name:       "add without authorization",
+			global:     []string{"-authorization", "wrong"},
+			args:       []string{"add", "-content", "a fact"},
+			wantCode:   exitForbidden,
+			wantStderr: "forbidden",
+		},
+		{
+			name:       "import csv",
+			stdin:      "Octopuses have three hearts.,https://example.com/octopus\nBananas are berries.\n",
+			args:       []string{"import", "-o", "plain", "-"},
+			wantCode:   exitOK,
+			wantStdout: "created 2, skipped 0, failed 0\n",
+		},
+		{
+			name:       "import invalid csv",
+			stdin:      "a fact\n\"\"\n",
+			args:       []string{"import", "-"},
+			wantCode:   exitBadRequest,
+			wantStderr: "line 2: content is blank",
+		},
+		{
+			name:       "get",
+			args:       []string{"get", "-o", "plain", "2"},
+			wantCode:   exitOK,
+			wantStdout: "Octopuses have three hearts.\n",
+		},
+		{
+			name:       "get table",
+			args:       []string{"get", "1"},
+			wantCode:   exitOK,
+			wantStdout: "Source:   https://example.com/honey\n",
+		},
+		{
+			name:     "get missing",
+			args:     []string{"get", "42"},
+			wantCode: exitNotFound,
+		},
+		{
+			name:       "get invalid",
+			args:       []string{"get", "one"},
+			wantCode:   exitUsage,
+			wantStderr: `invalid fact ID "one"`,
+		},
+		{
+			name:       "list",
+			args:       []string{"list", "-o", "plain"},
+			wantCode:   exitOK,
+			wantStdout: "Honey never spoils.\nOctopuses have three hearts.\nBananas are berries.\n",
+		},
+		{
+			name:       "list limit",
+			global:     []string{"-o", "plain"},
+			args:       []string{"list", "-limit", "1"},
+			wantCode:   exitOK,
+			wantStdout: "Honey never spoils.\n",
+		},
+		{
+			name:       "list table",
+			args:       []string{"list", "-limit", "1"},
+			wantCode:   exitOK,
+			wantStdout: "ID  WEIGHT  SOURCE                     CONTENT\n1   1       https://example.com/honey  Honey never spoils.\n",
+		},
+		{
+			name:       "unknown output",
+			args:       []string{"list", "-o", "xml"},
+			wantCode:   exitUsage,
+			wantStderr: `unknown output format "xml"`,
+		},
+		{
+			name:     "rm stale version",
+			args:     []string{"rm", "-version", "2", "3"},
+			wantCode: exitConflict,
+		},
+		{
+			name:     "rm",
+			args:     []string{"rm", "3"},
+			wantCode: exitOK,
+		},
+		{
+			name:     "rm again",
+			args:     []string{"rm", "3"},
+			wantCode: exitNotFound,
+		},
+		{
+			name:     "server down",
+			global:   []string{"-server", "http://127.0.0.1:1"},
+			args:     []string{"get", "1"},
+			wantCode: exitError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := factoidctl(t, append(global, tt.global...), tt.stdin, tt.args...)
+
+			if got.code != tt.wantCode {
+				t.Errorf("want exit code %d, got %d (stderr %q)", tt.wantCode, got.code, got.stderr)
+			}
+
+			if !strings.Contains(got.stdout, tt.wantStdout) {
+				t.Errorf("want stdout to contain %q, got %q", tt.wantStdout, got.stdout)
+			}
+
+			if !strings.Contains(got.stderr, tt.wantStderr) {
+				t.Errorf("want stderr to contain %q, got %q", tt.wantStderr, got.stderr)
+			}
+		})
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	src, cleanup := newTestServer(t)
+	defer cleanup()
+
+	dst, cleanup := newTestServer(t)
+	defer cleanup()
+
+	from := writeConfig(t, src.URL)
+	to := writeConfig(t, dst.URL)
+
+	if got := factoidctl(t, from, "one\ntwo\n", "import", "-"); got.code != exitOK {
+		t.Fatalf("want exit code %d, got %d (stderr %q)", exitOK, got.code, got.stderr)
+	}
+
+	export := factoidctl(t, from, "", "export")
+	if export.code != exitOK {
+		t.Fatalf("want exit code %d, got %d (stderr %q)", exitOK, export.code, export.stderr)
+	}
+
+	got := factoidctl(t, to, export.stdout, "import", "-format", "ndjson", "-preserve", "-o", "json", "-")
+	if got.code != exitOK {
+		t.Fatalf("want exit code %d, got %d (stderr %q)", exitOK, got.code, got.stderr)
+	}
+
+	var imported struct {
+		Imported int `json:"imported"`
+	}
+	if err := json.Unmarshal([]byte(got.stdout), &imported); err != nil {
+		t.Fatal(err)
+	}
+	if imported.Imported != 2 {
+		t.Errorf("want 2 facts imported, got %d", imported.Imported)
+	}
+
+	got = factoidctl(t, to, "", "list", "-o", "plain")
+	if got.stdout != "one\ntwo\n" {
+		t.Errorf("want facts %q, got %q", "one\ntwo\n", got.stdout)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	ts, cleanup := newTestServer(t)
+	defer cleanup()
+
+	t.Run("missing default", func(t *testing.T) {
+		t.Setenv("FACTOIDCTL_SERVER", ts.URL)
+		t.Setenv("FACTOIDCTL_AUTHORIZATION", "secret")
+
+		got := factoidctl(t, []string{"-config", ""}, "", "add", "-content", "a fact", "-o", "plain")
+		if got.code != exitOK {
+			t.Errorf("want exit code %d, got %d (stderr %q)", exitOK, got.code, got.stderr)
+		}
+	})
+
+	t.Run("missing explicit", func(t *testing.T) {
+		got := factoidctl(t, []string{"-config", filepath.Join(t.TempDir(), "nope.yaml")}, "", "list")
+		if got.code != exitError {
+			t.Errorf("want exit code %d, got %d", exitError, got.code)
+		}
+	})
+
+	t.Run("flags override file", func(t *testing.T) {
+		global := append(writeConfig(t, "http://127.0.0.1:1"), "-server", ts.URL, "-o", "plain")
+
+		got := factoidctl(t, global, "", "get", "1")
+		if got.code != exitOK || got.stdout != "a fact\n" {
+			t.Errorf("want fact 1, got exit code %d, stdout %q, stderr %q", got.code, got.stdout, got.stderr)
+		}
+	})
+
+	t.Run("unknown setting", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte("sever: "+ts.URL+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		got := factoidctl(t, []string{"-config", path}, "", "list")
+		if got.code != exitError || !strings.Contains(got.stderr, "sever") {
+			t.Errorf("want the typo reported, got exit code %d, stderr %q", got.code, got.stderr)
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/connorkuehl/factoid/client"
+)
+
+var (
+	mdBullet = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+(.*)$`)
+	mdTask   = regexp.MustCompile(`^\[[ xX]\]\s+`)
+	mdFence  = regexp.MustCompile("^\\s*(```|~~~)")
+	mdLink   = regexp.MustCompile(`^\[([^\]]*)\]\(<?([^)\s>]+)>?\)$`)
+	mdURL    = regexp.MustCompile(`^<?(https?://[^\s>]+)>?$`)
+)
+
+// readMarkdown reads a fact from every item of the bullet and numbered
+// lists in a Markdown document. An item's text may end with an em dash
+// and its source:
+//
+//	Honey never spoils. — [Smithsonian](https://example.com/honey)
+//
+// A source that isn't a link or URL must follow a full sentence, so
+// dashes within the fact are left alone. Everything else in the
+// document is ignored.
+func readMarkdown(r io.Reader, _ importOptions) ([]importRow, error) {
+	var (
+		rows    []importRow
+		item    []string
+		start   int
+		inFence bool
+	)
+
+	flush := func() {
+		if item != nil {
+			rows = append(rows, importRow{line: start, fact: markdownFact(strings.Join(item, " "))})
+		}
+		item = nil
+	}
+
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+
+		if mdFence.MatchString(text) {
+			flush()
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+
+		trimmed := strings.TrimSpace(text)
+		switch {
+		case trimmed == "", strings.HasPrefix(trimmed, "#"):
+			flush()
+		case mdBullet.MatchString(text):
+			flush()
+			item = []string{mdTask.ReplaceAllString(mdBullet.FindStringSubmatch(text)[1], "")}
+			start = line
+		case item != nil:
+			// A continuation of the item's text.
+			item = append(item, trimmed)
+		}
+	}
+	flush()
+
+	return rows, s.Err()
+}
+
+// markdownFact splits an item's text into the fact and its source.
+func markdownFact(text string) client.NewFact {
+	text = strings.TrimSpace(text)
+
+	i := strings.LastIndex(text, "—")
+	if i <= 0 {
+		return client.NewFact{Content: text}
+	}
+
+	content := strings.TrimSpace(text[:i])
+	source := strings.TrimSpace(text[i+len("—"):])
+	if content == "" || source == "" {
+		return client.NewFact{Content: text}
+	}
+
+	f := client.NewFact{Content: content}
+	switch {
+	case mdLink.MatchString(source):
+		m := mdLink.FindStringSubmatch(source)
+		f.Citations = []client.Citation{{URL: m[2], Title: strings.TrimSpace(m[1])}}
+	case mdURL.MatchString(source):
+		f.Source = mdURL.FindStringSubmatch(source)[1]
+	case endsSentence(content):
+		f.Source = source
+	default:
+		return client.NewFact{Content: text}
+	}
+	return f
+}
+
+func endsSentence(s string) bool {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return strings.ContainsRune(`.!?"”)`, r)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/connorkuehl/factoid/client"
+)
+
+// printFact prints a single fact. Tables list every field on its own
+// line.
+func (e *env) printFact(f client.Fact) error {
+	switch e.output {
+	case outputJSON:
+		return e.printJSON(f)
+	case outputPlain:
+		_, err := fmt.Fprintln(e.stdout, f.Content)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID:\t%d\n", f.ID)
+	fmt.Fprintf(tw, "Content:\t%s\n", oneLine(f.Content))
+	fmt.Fprintf(tw, "Source:\t%s\n", f.Source)
+	for i, c := range f.Citations {
+		label := ""
+		if i == 0 {
+			label = "Citations:"
+		}
+		if c.Title != "" {
+			fmt.Fprintf(tw, "%s\t%s (%s)\n", label, c.URL, oneLine(c.Title))
+		} else {
+			fmt.Fprintf(tw, "%s\t%s\n", label, c.URL)
+		}
+	}
+	fmt.Fprintf(tw, "Weight:\t%g\n", f.Weight)
+	fmt.Fprintf(tw, "Version:\t%d\n", f.Version)
+	fmt.Fprintf(tw, "Created:\t%s\n", f.CreatedAt.Local().Format(time.RFC3339))
+	fmt.Fprintf(tw, "Updated:\t%s\n", f.UpdatedAt.Local().Format(time.RFC3339))
+	return tw.Flush()
+}
+
+// printFacts prints a list of facts, one per line unless the output is
+// JSON.
+func (e *env) printFacts(facts []client.Fact) error {
+	switch e.output {
+	case outputJSON:
+		if facts == nil {
+			facts = []client.Fact{}
+		}
+		return e.printJSON(facts)
+	case outputPlain:
+		for _, f := range facts {
+			if _, err := fmt.Fprintln(e.stdout, f.Content); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tWEIGHT\tSOURCE\tCONTENT")
+	for _, f := range facts {
+		fmt.Fprintf(tw, "%d\t%g\t%s\t%s\n", f.ID, f.Weight, f.Source, oneLine(f.Content))
+	}
+	return tw.Flush()
+}
+
+func (e *env) printJSON(v any) error {
+	enc := json.NewEncoder(e.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// oneLine collapses the whitespace in s so it fits in a table cell.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
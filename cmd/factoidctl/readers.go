@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/connorkuehl/factoid/client"
+)
+
+// A factReader parses the facts to import out of r. Facts it can't make
+// sense of are returned with their error, so they're reported along with
+// the rest. An error stops the import altogether.
+type factReader func(r io.Reader, opts importOptions) ([]importRow, error)
+
+var factReaders = map[string]factReader{
+	"csv":      readCSV,
+	"json":     readJSON,
+	"ndjson":   readNDJSON,
+	"yaml":     readYAML,
+	"markdown": readMarkdown,
+	"anki":     readAnki,
+}
+
+var formatsByExt = map[string]string{
+	".csv":      "csv",
+	".json":     "json",
+	".ndjson":   "ndjson",
+	".jsonl":    "ndjson",
+	".yaml":     "yaml",
+	".yml":      "yaml",
+	".md":       "markdown",
+	".markdown": "markdown",
+	".apkg":     "anki",
+	".txt":      "anki",
+}
+
+// guessFormat picks the format of the file at path by its extension,
+// falling back to CSV.
+func guessFormat(path string) string {
+	if format, ok := formatsByExt[strings.ToLower(filepath.Ext(path))]; ok {
+		return format
+	}
+	return "csv"
+}
+
+// importFact is a fact as written in JSON and YAML files: either just
+// its content, or an object with the fields the API takes. Other fields,
+// such as those in an export, are ignored.
+type importFact struct {
+	Content   string           `json:"content" yaml:"content"`
+	Source    string           `json:"source" yaml:"source"`
+	Citations []importCitation `json:"citations" yaml:"citations"`
+	Weight    *float64         `json:"weight" yaml:"weight"`
+}
+
+type importCitation struct {
+	URL        string `json:"url" yaml:"url"`
+	Title      string `json:"title" yaml:"title"`
+	Author     string `json:"author" yaml:"author"`
+	Publisher  string `json:"publisher" yaml:"publisher"`
+	Accessed   string `json:"accessed" yaml:"accessed"`
+	ArchiveURL string `json:"archive_url" yaml:"archive_url"`
+}
+
+func (f *importFact) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &f.Content)
+	}
+
+	type plain importFact
+	return json.Unmarshal(b, (*plain)(f))
+}
+
+func (f *importFact) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		return n.Decode(&f.Content)
+	}
+
+	type plain importFact
+	return n.Decode((*plain)(f))
+}
+
+func (f importFact) newFact() client.NewFact {
+	nf := client.NewFact{
+		Content: strings.TrimSpace(f.Content),
+		Source:  strings.TrimSpace(f.Source),
+		Weight:  f.Weight,
+	}
+	for _, c := range f.Citations {
+		nf.Citations = append(nf.Citations, client.Citation(c))
+	}
+	return nf
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/connorkuehl/factoid/client"
+)
+
+type wantRow struct {
+	line int
+	fact client.NewFact
+	err  string
+}
+
+func weight(w float64) *float64 { return &w }
+
+func TestReaders(t *testing.T) {
+	tests := []struct {
+		file string
+		opts importOptions
+		want []wantRow
+	}{
+		{
+			file: "facts.json",
+			want: []wantRow{
+				{line: 2, fact: client.NewFact{Content: "Honey never spoils."}},
+				{line: 3, fact: client.NewFact{
+					Content:   "Octopuses have three hearts.",
+					Citations: []client.Citation{{URL: "https://example.com/octopus", Title: "Octopus anatomy"}},
+					Weight:    weight(2),
+				}},
+				{line: 10, fact: client.NewFact{Content: "Bananas are berries.", Source: "Botany 101"}},
+				{line: 11, fact: client.NewFact{Source: "https://example.com/blank"}},
+				{line: 12, fact: client.NewFact{Content: "Heavy facts weigh less.", Weight: weight(0)}, err: "weight can't be a string"},
+				{line: 13, fact: client.NewFact{Content: "Light facts weigh more.", Weight: weight(-1)}},
+			},
+		},
+		{
+			file: "listing.json",
+			want: []wantRow{
+				{line: 3, fact: client.NewFact{
+					Content:   "Honey never spoils.",
+					Source:    "https://example.com/honey",
+					Citations: []client.Citation{{URL: "https://example.com/honey"}},
+					Weight:    weight(1),
+				}},
+			},
+		},
+		{
+			file: "facts.ndjson",
+			want: []wantRow{
+				{line: 1, fact: client.NewFact{Content: "Honey never spoils.", Source: "https://example.com/honey", Weight: weight(1)}},
+				{line: 3, fact: client.NewFact{Content: "Octopuses have three hearts."}},
+				{line: 4, err: "invalid JSON"},
+				{line: 5, err: "content can't be a number"},
+			},
+		},
+		{
+			file: "facts.yaml",
+			want: []wantRow{
+				{line: 3, fact: client.NewFact{Content: "Honey never spoils."}},
+				{line: 4, fact: client.NewFact{
+					Content: "Octopuses have three hearts.",
+					Citations: []client.Citation{{
+						URL:        "https://example.com/octopus",
+						Title:      "Octopus anatomy",
+						ArchiveURL: "https://archive.example.com/octopus",
+					}},
+					Weight: weight(2),
+				}},
+				{line: 10, fact: client.NewFact{Content: "Bananas are berries.", Source: "Botany 101"}},
+				{line: 12, fact: client.NewFact{Content: "Heavy facts weigh less.", Weight: weight(0)}, err: "cannot unmarshal !!str `heavy` into float64"},
+				{line: 14, fact: client.NewFact{Source: "https://example.com/blank"}},
+			},
+		},
+		{
+			file: "facts.md",
+			want: []wantRow{
+				{line: 5, fact: client.NewFact{
+					Content:   "Honey never spoils.",
+					Citations: []client.Citation{{URL: "https://example.com/honey", Title: "Smithsonian"}},
+				}},
+				{line: 6, fact: client.NewFact{Content: "Octopuses have three hearts — and blue blood.", Source: "https://example.com/octopus"}},
+				{line: 8, fact: client.NewFact{Content: "Bananas are berries.", Source: "Botany 101"}},
+				{line: 9, fact: client.NewFact{Content: "Wombat poop is cube-shaped.", Source: "https://example.com/wombat"}},
+				{line: 13, fact: client.NewFact{Content: "A day on Venus is longer than its year."}},
+				{line: 14, fact: client.NewFact{Content: "The em dash — like this one — goes unnoticed."}},
+			},
+		},
+		{
+			file: "deck.txt",
+			want: []wantRow{
+				{line: 7, fact: client.NewFact{Content: "Honey never spoils. Even after 3000 years."}},
+				{line: 8, fact: client.NewFact{Content: "Octopuses have three hearts."}},
+				{line: 10, fact: client.NewFact{}},
+				{line: 11, fact: client.NewFact{Content: "Bananas are berries."}},
+			},
+		},
+		{
+			file: "deck.txt",
+			opts: importOptions{contentCol: "2", sourceCol: "1"},
+			want: []wantRow{
+				{line: 7, fact: client.NewFact{Content: "Smithsonian", Source: "Honey never spoils. Even after 3000 years."}},
+				{line: 8, fact: client.NewFact{Content: "https://example.com/octopus", Source: "Octopuses have three hearts."}},
+				{line: 10, fact: client.NewFact{}},
+				{line: 11, fact: client.NewFact{Source: "Bananas are berries."}},
+			},
+		},
+		{
+			file: "deck.apkg",
+			want: []wantRow{
+				{fact: client.NewFact{Content: "Honey never spoils. Not even after 3000 years."}},
+				{fact: client.NewFact{Content: "Octopuses have three hearts."}},
+				{fact: client.NewFact{Content: "Bananas are berries & strawberries aren't."}},
+				{fact: client.NewFact{}},
+			},
+		},
+		{
+			file: "deck.apkg",
+			opts: importOptions{sourceCol: "2"},
+			want: []wantRow{
+				{fact: client.NewFact{Content: "Honey never spoils. Not even after 3000 years.", Source: "Smithsonian"}},
+				{fact: client.NewFact{Content: "Octopuses have three hearts.", Source: "https://example.com/octopus"}},
+				{fact: client.NewFact{Content: "Bananas are berries & strawberries aren't."}},
+				{fact: client.NewFact{Source: "blank"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		name := tt.file
+		if tt.opts != (importOptions{}) {
+			name += "/" + tt.opts.contentCol + tt.opts.sourceCol
+		}
+
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			rows, err := factReaders[guessFormat(tt.file)](f, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]wantRow, len(rows))
+			for i, row := range rows {
+				got[i] = wantRow{line: row.line, fact: row.fact}
+				if row.err != nil {
+					got[i].err = row.err.Error()
+				}
+			}
+
+			if !reflect.DeepEqual(tt.want, got) {
+				t.Errorf("want rows\n%+v\ngot\n%+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	tests := []struct {
+		format  string
+		input   string
+		wantErr string
+	}{
+		{format: "json", input: "[\n  \"a fact\",\n  {\"content\": }\n]", wantErr: "line 3: "},
+		{format: "json", input: `"a fact"`, wantErr: "want an array of facts"},
+		{format: "json", input: `{"next": "2"}`, wantErr: `no "facts" array`},
+		{format: "yaml", input: "content: a fact\n", wantErr: `no "facts" list`},
+		{format: "yaml", input: "a fact\n", wantErr: "line 1: want a list of facts"},
+		{format: "anki", input: "#separator:tabs\na fact\n", wantErr: `line 1: unknown separator "tabs"`},
+		{format: "anki", input: "PK\x03\x04", wantErr: "zip: not a valid zip file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format+"/"+tt.wantErr, func(t *testing.T) {
+			_, err := factReaders[tt.format](strings.NewReader(tt.input), importOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("want error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestImportFormats(t *testing.T) {
+	tests := []struct {
+		file       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr []string
+	}{
+		{
+			file:       "facts.json",
+			wantCode:   exitBadRequest,
+			wantStdout: "created 0, skipped 3, failed 3\n",
+			wantStderr: []string{
+				"line 11: content is blank\n",
+				"line 12: weight can't be a string\n",
+				"line 13: weight must not be negative\n",
+			},
+		},
+		{
+			file:       "facts.md",
+			wantCode:   exitOK,
+			wantStdout: "created 6, skipped 0, failed 0\n",
+		},
+		{
+			file:       "deck.apkg",
+			args:       []string{"-best-effort", "-source-col", "2"},
+			wantCode:   exitBadRequest,
+			wantStdout: "created 3, skipped 0, failed 1\n",
+			wantStderr: []string{"row 4: content is blank\n"},
+		},
+		{
+			file:       "deck.txt",
+			args:       []string{"-dry-run"},
+			wantCode:   exitBadRequest,
+			wantStdout: "would create 3, skip 0, fail 1\n",
+			wantStderr: []string{"line 10: content is blank\n"},
+		},
+		{
+			file:     "facts.yaml",
+			args:     []string{"-preserve"},
+			wantCode: exitUsage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			ts, cleanup := newTestServer(t)
+			defer cleanup()
+
+			global := writeConfig(t, ts.URL)
+
+			args := append(append([]string{"import"}, tt.args...), filepath.Join("testdata", tt.file))
+			got := factoidctl(t, global, "", args...)
+
+			if got.code != tt.wantCode {
+				t.Errorf("want exit code %d, got %d (stderr %q)", tt.wantCode, got.code, got.stderr)
+			}
+
+			if got.stdout != tt.wantStdout {
+				t.Errorf("want stdout %q, got %q", tt.wantStdout, got.stdout)
+			}
+
+			for _, want := range tt.wantStderr {
+				if !strings.Contains(got.stderr, want) {
+					t.Errorf("want stderr to contain %q, got %q", want, got.stderr)
+				}
+			}
+		})
+	}
+}
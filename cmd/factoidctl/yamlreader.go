@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readYAML reads a YAML list of facts, or a mapping holding one under
+// "facts".
+func readYAML(r io.Reader, _ importOptions) ([]importRow, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if err == io.EOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	list := doc.Content[0]
+	if list.Kind == yaml.MappingNode {
+		list = nil
+		for i := 0; i+1 < len(doc.Content[0].Content); i += 2 {
+			if doc.Content[0].Content[i].Value == "facts" {
+				list = doc.Content[0].Content[i+1]
+			}
+		}
+		if list == nil {
+			return nil, errors.New(`no "facts" list in the mapping`)
+		}
+	}
+	if list.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: want a list of facts", list.Line)
+	}
+
+	rows := make([]importRow, 0, len(list.Content))
+	for _, item := range list.Content {
+		var f importFact
+		err := item.Decode(&f)
+		rows = append(rows, importRow{line: item.Line, fact: f.newFact(), err: yamlFieldError(err)})
+	}
+	return rows, nil
+}
+
+// yamlFieldError strips the line number yaml.v3 adds, since the row
+// carries it already.
+func yamlFieldError(err error) error {
+	var typ *yaml.TypeError
+	if !errors.As(err, &typ) {
+		return err
+	}
+
+	msgs := make([]string, len(typ.Errors))
+	for i, msg := range typ.Errors {
+		if _, rest, ok := strings.Cut(msg, ": "); ok && strings.HasPrefix(msg, "line ") {
+			msg = rest
+		}
+		msgs[i] = msg
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
@@ -0,0 +1,229 @@
+// Package bot answers requests for facts in chat rooms, such as IRC
+// channels.
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "golang.org/x/exp/slog"
+
+	"github.com/connorkuehl/factoid/internal/service"
+)
+
+// searchLimit is the most facts shown for a search, which keeps the bot
+// from flooding a channel.
+const searchLimit = 3
+
+// Message is something said to the bot, or in a channel it's in.
+type Message struct {
+	// Channel is where the message was said, and where a reply goes. For
+	// private messages, it's the sender.
+	Channel string
+
+	// Sender is who said it.
+	Sender string
+
+	Text string
+}
+
+// Transport connects the bot to a chat network.
+type Transport interface {
+	// Receive blocks until a message arrives, ctx is cancelled or the
+	// connection is lost.
+	Receive(ctx context.Context) (Message, error)
+
+	// Send says text in channel. Text may span several lines.
+	Send(ctx context.Context, channel, text string) error
+}
+
+type Option interface {
+	Apply(b *Bot)
+}
+
+type optionFunc func(b *Bot)
+
+func (opt optionFunc) Apply(b *Bot) {
+	opt(b)
+}
+
+// WithPrefix sets what commands start with. It's "!" by default, so the
+// bot answers "!fact".
+func WithPrefix(prefix string) optionFunc {
+	return func(b *Bot) { b.prefix = prefix }
+}
+
+// WithCooldown sets how long the bot stays quiet in a channel after
+// answering a command there. Commands sent in the meantime are ignored.
+func WithCooldown(d time.Duration) optionFunc {
+	return func(b *Bot) { b.cooldown = d }
+}
+
+type Bot struct {
+	facts    service.FactRepo
+	prefix   string
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	lastSaid map[string]time.Time
+}
+
+func New(facts service.FactRepo, opts ...Option) *Bot {
+	b := &Bot{
+		facts:    facts,
+		prefix:   "!",
+		cooldown: 10 * time.Second,
+		now:      time.Now,
+		lastSaid: make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt.Apply(b)
+	}
+	return b
+}
+
+// Run answers the messages t receives until ctx is cancelled or t fails.
+func (b *Bot) Run(ctx context.Context, t Transport) error {
+	for {
+		m, err := t.Receive(ctx)
+		if err != nil {
+			return err
+		}
+
+		logger := log.With("component", "bot", "channel", m.Channel, "sender", m.Sender)
+
+		reply, err := b.Reply(ctx, m)
+		if err != nil {
+			logger.With("err", err).Error("")
+			reply = "Sorry, something went wrong."
+		}
+		if reply == "" {
+			continue
+		}
+
+		if err := t.Send(ctx, m.Channel, reply); err != nil {
+			return err
+		}
+	}
+}
+
+// Reply returns what the bot says in response to m, or nothing if m
+// isn't a command or the channel is cooling down:
+//
+//	!fact                 a random fact
+//	!fact 42              fact 42
+//	!fact search octopus  facts that mention octopuses
+//
+// Only failures of the repo are returned as errors.
+func (b *Bot) Reply(ctx context.Context, m Message) (string, error) {
+	command := b.prefix + "fact"
+
+	fields := strings.Fields(m.Text)
+	if len(fields) == 0 || fields[0] != command {
+		return "", nil
+	}
+	if !b.speak(m.Channel) {
+		return "", nil
+	}
+
+	args := fields[1:]
+	switch {
+	case len(args) == 0:
+		f, err := b.facts.RandomFact(ctx, service.RandomOptions{})
+		if errors.Is(err, service.ErrNotFound) {
+			return "There are no facts yet.", nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if err := b.facts.MarkServed(ctx, f.ID); err != nil {
+			return "", err
+		}
+		return format(f), nil
+
+	case args[0] == "search":
+		query := strings.Join(args[1:], " ")
+		if query == "" {
+			return fmt.Sprintf("What should I look for? Try %s search octopus", command), nil
+		}
+
+		facts, err := b.facts.Facts(ctx, service.FactsQuery{Search: query, Limit: searchLimit})
+		if err != nil {
+			return "", err
+		}
+		if len(facts) == 0 {
+			return fmt.Sprintf("No facts mention %q.", query), nil
+		}
+
+		lines := make([]string, len(facts))
+		for i, f := range facts {
+			lines[i] = format(f)
+		}
+		return strings.Join(lines, "\n"), nil
+
+	case len(args) == 1:
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			break
+		}
+
+		f, err := b.facts.Fact(ctx, id)
+		if errors.Is(err, service.ErrNotFound) {
+			return fmt.Sprintf("There's no fact %d.", id), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		return format(f), nil
+	}
+
+	return fmt.Sprintf("Try %[1]s for a random fact, %[1]s 42 for fact 42, or %[1]s search octopus to find facts.", command), nil
+}
+
+// speak reports whether the bot may answer in channel, and if so, starts
+// the channel's cooldown.
+func (b *Bot) speak(channel string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if last, ok := b.lastSaid[channel]; ok && now.Sub(last) < b.cooldown {
+		return false
+	}
+
+	// Forget channels that have cooled down, so the map doesn't grow
+	// with every private message.
+	for ch, last := range b.lastSaid {
+		if now.Sub(last) >= b.cooldown {
+			delete(b.lastSaid, ch)
+		}
+	}
+	b.lastSaid[channel] = now
+	return true
+}
+
+// format puts a fact on one line, followed by its ID and where it comes
+// from.
+func format(f service.Fact) string {
+	content := strings.Join(strings.Fields(f.Content), " ")
+
+	var source string
+	switch {
+	case len(f.Citations) > 0:
+		source = f.Citations[0].URL
+	case f.Source != "":
+		source = f.Source
+	}
+
+	if source == "" {
+		return fmt.Sprintf("%s [#%d]", content, f.ID)
+	}
+	return fmt.Sprintf("%s [#%d, %s]", content, f.ID, source)
+}
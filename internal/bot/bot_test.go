@@ -0,0 +1,328 @@
+package bot_test
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/connorkuehl/factoid/internal/bot"
+	sqliterepo "github.com/connorkuehl/factoid/internal/repo/sqlite"
+	"github.com/connorkuehl/factoid/internal/service"
+)
+
+func newTestDB(t *testing.T, facts ...service.Fact) (*sqliterepo.Repo, func()) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(sqliterepo.Schema())
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+
+	repo := sqliterepo.NewRepo(db)
+	for _, f := range facts {
+		f.Weight = 1
+		if _, err := repo.CreateFact(context.TODO(), f); err != nil {
+			db.Close()
+			t.Fatal(err)
+		}
+	}
+
+	return repo, func() { db.Close() }
+}
+
+func TestReply(t *testing.T) {
+	r, cleanup := newTestDB(t,
+		service.Fact{Content: "Octopuses have three hearts.", Source: "a unit test"},
+		service.Fact{Content: "An octopus can\ntaste with its arms.", Citations: []service.Citation{{URL: "https://example.com/octopus"}}},
+		service.Fact{Content: "Honey never spoils."},
+	)
+	defer cleanup()
+
+	tests := []struct {
+		name   string
+		prefix string
+		text   string
+		want   string
+	}{
+		{
+			name: "by id",
+			text: "!fact 1",
+			want: "Octopuses have three hearts. [#1, a unit test]",
+		},
+		{
+			name: "citations and newlines",
+			text: "!fact 2",
+			want: "An octopus can taste with its arms. [#2, https://example.com/octopus]",
+		},
+		{
+			name: "no source",
+			text: "  !fact   3 ",
+			want: "Honey never spoils. [#3]",
+		},
+		{
+			name: "unknown id",
+			text: "!fact 999",
+			want: "There's no fact 999.",
+		},
+		{
+			name: "search",
+			text: "!fact search OCTOPUS",
+			want: "Octopuses have three hearts. [#1, a unit test]\nAn octopus can taste with its arms. [#2, https://example.com/octopus]",
+		},
+		{
+			name: "search without results",
+			text: "!fact search giraffe neck",
+			want: `No facts mention "giraffe neck".`,
+		},
+		{
+			name: "search without terms",
+			text: "!fact search",
+			want: "What should I look for? Try !fact search octopus",
+		},
+		{
+			name: "usage",
+			text: "!fact me up",
+			want: "Try !fact for a random fact, !fact 42 for fact 42, or !fact search octopus to find facts.",
+		},
+		{
+			name:   "custom prefix",
+			prefix: ".",
+			text:   ".fact 3",
+			want:   "Honey never spoils. [#3]",
+		},
+		{
+			name:   "other prefixes are ignored",
+			prefix: ".",
+			text:   "!fact 3",
+		},
+		{
+			name: "not a command",
+			text: "did you know octopuses have three hearts?",
+		},
+		{
+			name: "not this command",
+			text: "!factoid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []bot.Option{bot.WithCooldown(0)}
+			if tt.prefix != "" {
+				opts = append(opts, bot.WithPrefix(tt.prefix))
+			}
+			b := bot.New(r, opts...)
+
+			got, err := b.Reply(context.TODO(), bot.Message{Channel: "#facts", Sender: "alice", Text: tt.text})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	t.Run("random", func(t *testing.T) {
+		got, err := bot.New(r).Reply(context.TODO(), bot.Message{Channel: "#facts", Text: "!fact"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(got, "[#") {
+			t.Errorf("want a fact, got %q", got)
+		}
+	})
+}
+
+func TestCooldown(t *testing.T) {
+	r, cleanup := newTestDB(t, service.Fact{Content: "Honey never spoils."})
+	defer cleanup()
+
+	const cooldown = 500 * time.Millisecond
+	b := bot.New(r, bot.WithCooldown(cooldown))
+
+	steps := []struct {
+		wait      time.Duration
+		channel   string
+		text      string
+		wantReply bool
+	}{
+		{channel: "#facts", text: "!fact", wantReply: true},
+		{channel: "#facts", text: "!fact", wantReply: false},
+		{channel: "#other", text: "!fact 1", wantReply: true},
+		{channel: "#facts", text: "chatter doesn't count", wantReply: false},
+		{wait: cooldown, channel: "#facts", text: "!fact", wantReply: true},
+		{channel: "#other", text: "!fact", wantReply: true},
+		{channel: "#other", text: "!fact", wantReply: false},
+	}
+
+	for i, step := range steps {
+		time.Sleep(step.wait)
+
+		got, err := b.Reply(context.TODO(), bot.Message{Channel: step.channel, Text: step.text})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (got != "") != step.wantReply {
+			t.Errorf("step %d: want reply %v, got %q", i, step.wantReply, got)
+		}
+	}
+}
+
+// ircServer is an IRC server that expects to talk to one client.
+type ircServer struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (s *ircServer) expect(want string) {
+	s.t.Helper()
+
+	s.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := s.r.ReadString('\n')
+	if err != nil {
+		s.t.Fatalf("want %q, got %v", want, err)
+	}
+	if got := strings.TrimRight(line, "\r\n"); got != want {
+		s.t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func (s *ircServer) send(line string) {
+	s.t.Helper()
+
+	if _, err := fmt.Fprintf(s.conn, "%s\r\n", line); err != nil {
+		s.t.Fatal(err)
+	}
+}
+
+func TestIRC(t *testing.T) {
+	r, cleanup := newTestDB(t,
+		service.Fact{Content: "Octopuses have three hearts.", Source: "a unit test"},
+		service.Fact{Content: strings.Repeat("Very long fact. ", 50)},
+	)
+	defer cleanup()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	type dialed struct {
+		irc *bot.IRC
+		err error
+	}
+	dial := make(chan dialed, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		irc, err := bot.DialIRC(ctx, ln.Addr().String(), bot.IRCConfig{
+			Nick:     "factoid",
+			Password: "hunter2",
+			Channels: []string{"#facts", "#trivia"},
+		})
+		dial <- dialed{irc, err}
+	}()
+
+	conn, ok := <-accepted
+	if !ok {
+		t.Fatal("want a connection, got nothing")
+	}
+	defer conn.Close()
+
+	server := &ircServer{t: t, conn: conn, r: bufio.NewReader(conn)}
+
+	server.expect("PASS hunter2")
+	server.expect("NICK factoid")
+	server.expect("USER factoid 0 * factoid")
+	server.send(":irc.example.com NOTICE * :*** Looking up your hostname...")
+	server.send(":irc.example.com 433 * factoid :Nickname is already in use")
+	server.expect("NICK factoid_")
+	server.send("@time=2023-02-26T17:21:36.000Z :irc.example.com 001 factoid_ :Welcome to the Example IRC Network factoid_")
+	server.expect("JOIN #facts")
+	server.expect("JOIN #trivia")
+
+	d := <-dial
+	if d.err != nil {
+		t.Fatal(d.err)
+	}
+	irc := d.irc
+	defer irc.Close()
+
+	if got := irc.Nick(); got != "factoid_" {
+		t.Errorf("want nick %q, got %q", "factoid_", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- bot.New(r, bot.WithCooldown(time.Minute)).Run(ctx, irc) }()
+
+	server.send("PING :irc.example.com")
+	server.expect("PONG irc.example.com")
+
+	server.send(":alice!~alice@example.com PRIVMSG #facts :!fact 1")
+	server.expect("PRIVMSG #facts :Octopuses have three hearts. [#1, a unit test]")
+
+	// #facts is cooling down, but #trivia isn't.
+	server.send(":alice!~alice@example.com PRIVMSG #facts :!fact 1")
+	server.send("@time=2023-02-26T17:21:36.000Z :bob!~bob@example.com PRIVMSG #trivia :!fact 2")
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := server.r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(line, "PRIVMSG #trivia :Very long fact.") {
+		t.Errorf("want a reply in #trivia, got %q", line)
+	}
+	if len(line) > 512 {
+		t.Errorf("want at most %d bytes, got %d", 512, len(line))
+	}
+	if !strings.HasSuffix(line, "…\r\n") {
+		t.Errorf("want a truncated line, got %q", line)
+	}
+
+	// Spaces in the trailing param are kept, and commands aren't case
+	// sensitive.
+	server.send(":alice!~alice@example.com privmsg #search :!fact search  three hearts")
+	server.expect("PRIVMSG #search :Octopuses have three hearts. [#1, a unit test]")
+
+	// Private messages are answered privately.
+	server.send(":carol!~carol@example.com PRIVMSG factoid_ :!fact 1")
+	server.expect("PRIVMSG carol :Octopuses have three hearts. [#1, a unit test]")
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("want %v, got %v", context.Canceled, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the bot to stop, but it's still running")
+	}
+}
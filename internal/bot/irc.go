@@ -0,0 +1,309 @@
+package bot
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxIRCLine is the longest line IRC allows, including the trailing CRLF.
+const maxIRCLine = 512
+
+// maxIRCUserHost is room left for the user and host the server puts in
+// front of the bot's messages when it relays them.
+const maxIRCUserHost = 80
+
+// IRCConfig says who the bot is on an IRC network.
+type IRCConfig struct {
+	// Nick is the nickname the bot asks for. If it's taken, underscores
+	// are added until it isn't.
+	Nick string
+
+	// Password is sent with PASS before registering, if set.
+	Password string
+
+	// Channels are joined once the server accepts the bot.
+	Channels []string
+}
+
+// IRC is a Transport that speaks the plain-text IRC protocol over a
+// single connection. It answers the server's PINGs itself.
+type IRC struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	mu   sync.Mutex
+	nick string
+}
+
+// DialIRC connects to the IRC server at addr, registers as cfg.Nick and
+// joins cfg.Channels.
+func DialIRC(ctx context.Context, addr string, cfg IRCConfig) (*IRC, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	irc, err := NewIRC(ctx, conn, cfg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return irc, nil
+}
+
+// NewIRC registers over conn, which is already connected to an IRC
+// server, and joins cfg.Channels.
+func NewIRC(ctx context.Context, conn net.Conn, cfg IRCConfig) (*IRC, error) {
+	if cfg.Nick == "" {
+		return nil, errors.New("irc: no nick")
+	}
+
+	irc := &IRC{
+		conn:   conn,
+		reader: bufio.NewReaderSize(conn, maxIRCLine),
+		nick:   cfg.Nick,
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	if cfg.Password != "" {
+		if err := irc.write("PASS", cfg.Password); err != nil {
+			return nil, err
+		}
+	}
+	if err := irc.write("NICK", irc.nick); err != nil {
+		return nil, err
+	}
+	if err := irc.write("USER", irc.nick, "0", "*", "factoid"); err != nil {
+		return nil, err
+	}
+
+	for registered := false; !registered; {
+		msg, err := irc.read()
+		if err != nil {
+			return nil, err
+		}
+
+		switch msg.command {
+		case "001": // RPL_WELCOME
+			registered = true
+		case "433": // ERR_NICKNAMEINUSE
+			irc.nick += "_"
+			if err := irc.write("NICK", irc.nick); err != nil {
+				return nil, err
+			}
+		case "ERROR":
+			return nil, fmt.Errorf("irc: %s", msg.trailing())
+		}
+	}
+
+	for _, ch := range cfg.Channels {
+		if err := irc.write("JOIN", ch); err != nil {
+			return nil, err
+		}
+	}
+	return irc, nil
+}
+
+// Nick returns the nickname the server accepted.
+func (irc *IRC) Nick() string {
+	irc.mu.Lock()
+	defer irc.mu.Unlock()
+
+	return irc.nick
+}
+
+// Receive returns the next PRIVMSG sent to a channel the bot is in, or
+// to the bot itself.
+func (irc *IRC) Receive(ctx context.Context) (Message, error) {
+	// Unblock the read when ctx is cancelled.
+	irc.conn.SetReadDeadline(time.Time{})
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			irc.conn.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+
+	for {
+		msg, err := irc.read()
+		if ctx.Err() != nil {
+			return Message{}, ctx.Err()
+		}
+		if err != nil {
+			return Message{}, err
+		}
+
+		switch msg.command {
+		case "PING":
+			if err := irc.write("PONG", msg.trailing()); err != nil {
+				return Message{}, err
+			}
+		case "ERROR":
+			return Message{}, fmt.Errorf("irc: %s", msg.trailing())
+		case "PRIVMSG":
+			if len(msg.params) < 2 {
+				continue
+			}
+
+			sender, _, _ := strings.Cut(msg.prefix, "!")
+			channel := msg.params[0]
+			if strings.EqualFold(channel, irc.Nick()) {
+				channel = sender
+			}
+			return Message{Channel: channel, Sender: sender, Text: msg.trailing()}, nil
+		}
+	}
+}
+
+// Send says each line of text in channel, cutting lines that are too long
+// for IRC.
+func (irc *IRC) Send(ctx context.Context, channel, text string) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		irc.conn.SetWriteDeadline(deadline)
+		defer irc.conn.SetWriteDeadline(time.Time{})
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+
+		// The server relays the line with our prefix, which must fit too.
+		room := maxIRCLine - len(":"+irc.Nick()+"! PRIVMSG "+channel+" :\r\n") - maxIRCUserHost
+		if len(line) > room {
+			line = truncate(line, room)
+		}
+
+		if err := irc.write("PRIVMSG", channel, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close says goodbye and closes the connection.
+func (irc *IRC) Close() error {
+	_ = irc.write("QUIT", "Goodbye")
+	return irc.conn.Close()
+}
+
+// truncate cuts s to at most n bytes, without splitting a UTF-8
+// character, and marks the cut with an ellipsis.
+func truncate(s string, n int) string {
+	const ellipsis = "…"
+
+	n -= len(ellipsis)
+	for n > 0 && n < len(s) && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	if n <= 0 {
+		return ellipsis
+	}
+	return s[:n] + ellipsis
+}
+
+// write sends a command, making the last param a trailing one so it may
+// contain spaces.
+func (irc *IRC) write(command string, params ...string) error {
+	var line strings.Builder
+	line.WriteString(command)
+	for i, p := range params {
+		p = strings.NewReplacer("\r", "", "\n", "").Replace(p)
+
+		line.WriteByte(' ')
+		if i == len(params)-1 && (p == "" || strings.ContainsRune(p, ' ') || p[0] == ':') {
+			line.WriteByte(':')
+		}
+		line.WriteString(p)
+	}
+	line.WriteString("\r\n")
+
+	irc.mu.Lock()
+	defer irc.mu.Unlock()
+
+	_, err := irc.conn.Write([]byte(line.String()))
+	return err
+}
+
+// ircMessage is a line from the server.
+type ircMessage struct {
+	prefix  string
+	command string
+	params  []string
+}
+
+// trailing returns the last param, which is the only one that may
+// contain spaces.
+func (m ircMessage) trailing() string {
+	if len(m.params) == 0 {
+		return ""
+	}
+	return m.params[len(m.params)-1]
+}
+
+func (irc *IRC) read() (ircMessage, error) {
+	for {
+		line, err := irc.reader.ReadString('\n')
+		if err != nil {
+			return ircMessage{}, err
+		}
+
+		if msg, ok := parseIRC(line); ok {
+			return msg, nil
+		}
+	}
+}
+
+// parseIRC parses a line like
+//
+//	:nick!user@host PRIVMSG #channel :hello there
+//
+// ignoring IRCv3 tags.
+func parseIRC(line string) (ircMessage, bool) {
+	line = strings.TrimRight(line, "\r\n")
+
+	if strings.HasPrefix(line, "@") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+
+	var msg ircMessage
+	if strings.HasPrefix(line, ":") {
+		msg.prefix, line, _ = strings.Cut(line[1:], " ")
+	}
+
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		if strings.HasPrefix(line, ":") {
+			msg.params = append(msg.params, line[1:])
+			break
+		}
+
+		var param string
+		param, line, _ = strings.Cut(line, " ")
+		if param == "" {
+			continue
+		}
+		if msg.command == "" {
+			msg.command = strings.ToUpper(param)
+		} else {
+			msg.params = append(msg.params, param)
+		}
+	}
+
+	return msg, msg.command != ""
+}
@@ -0,0 +1,243 @@
+// Package linkcheck periodically fetches the URLs that facts cite and
+// records whether they still resolve.
+package linkcheck
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	log "golang.org/x/exp/slog"
+
+	"github.com/connorkuehl/factoid/internal/service"
+)
+
+type Repo interface {
+	StaleSourceLinks(ctx context.Context, checkedBefore time.Time) ([]string, error)
+	RecordSourceLink(ctx context.Context, link service.SourceLink) error
+}
+
+type Option interface {
+	Apply(c *Checker)
+}
+
+type optionFunc func(c *Checker)
+
+func (opt optionFunc) Apply(c *Checker) {
+	opt(c)
+}
+
+// WithHTTPClient sets the client used to fetch links.
+func WithHTTPClient(client *http.Client) optionFunc {
+	return func(c *Checker) { c.client = client }
+}
+
+// WithInterval sets how long a link's result is trusted before it is
+// checked again, which is also how often the checker wakes up.
+func WithInterval(d time.Duration) optionFunc {
+	return func(c *Checker) { c.interval = d }
+}
+
+// WithPerHostLimit caps the number of in-flight requests to any one host.
+func WithPerHostLimit(n int) optionFunc {
+	return func(c *Checker) { c.perHost = n }
+}
+
+// WithConcurrency caps the total number of in-flight requests.
+func WithConcurrency(n int) optionFunc {
+	return func(c *Checker) { c.concurrency = n }
+}
+
+type Checker struct {
+	repo        Repo
+	client      *http.Client
+	interval    time.Duration
+	perHost     int
+	concurrency int
+	now         func() time.Time
+}
+
+func New(repo Repo, opts ...Option) *Checker {
+	c := &Checker{
+		repo:        repo,
+		client:      &http.Client{Timeout: 15 * time.Second},
+		interval:    24 * time.Hour,
+		perHost:     2,
+		concurrency: 16,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt.Apply(c)
+	}
+	if c.perHost < 1 {
+		c.perHost = 1
+	}
+	if c.concurrency < 1 {
+		c.concurrency = 1
+	}
+	return c
+}
+
+// Run checks stale links every interval until ctx is cancelled.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.CheckOnce(ctx); err != nil && ctx.Err() == nil {
+			log.With("component", "linkcheck", "err", err).Error("")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckOnce checks every link that hasn't been checked within the
+// interval and records the results.
+func (c *Checker) CheckOnce(ctx context.Context) error {
+	urls, err := c.repo.StaleSourceLinks(ctx, c.now().Add(-c.interval))
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg    sync.WaitGroup
+		hosts = make(map[string]chan struct{})
+		jobs  = make(chan string)
+	)
+
+	// Each host gets its own limit on top of the number of workers.
+	for _, u := range urls {
+		if host := hostOf(u); hosts[host] == nil {
+			hosts[host] = make(chan struct{}, c.perHost)
+		}
+	}
+
+	workers := c.concurrency
+	if workers > len(urls) {
+		workers = len(urls)
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for u := range jobs {
+				sem := hosts[hostOf(u)]
+				sem <- struct{}{}
+				link := c.Check(ctx, u)
+				<-sem
+
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := c.repo.RecordSourceLink(ctx, link); err != nil {
+					log.With("component", "linkcheck", "url", u, "err", err).Error("")
+				}
+			}
+		}()
+	}
+
+	// Taking turns between hosts keeps workers from piling up behind
+	// one busy host while the others sit idle.
+	for _, u := range byHost(urls) {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- u
+	}
+	close(jobs)
+
+	wg.Wait()
+	return ctx.Err()
+}
+
+// Check fetches rawURL, preferring HEAD and falling back to GET.
+func (c *Checker) Check(ctx context.Context, rawURL string) service.SourceLink {
+	link := service.SourceLink{
+		URL:       rawURL,
+		Status:    service.SourceStatusBroken,
+		CheckedAt: c.now(),
+	}
+
+	// Plenty of servers mishandle HEAD, so only trust it when it
+	// reports success.
+	rsp, err := c.fetch(ctx, http.MethodHead, rawURL)
+	if err != nil || rsp.StatusCode >= http.StatusBadRequest {
+		rsp, err = c.fetch(ctx, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		link.Error = err.Error()
+		return link
+	}
+
+	link.StatusCode = rsp.StatusCode
+	link.FinalURL = rsp.Request.URL.String()
+	if rsp.StatusCode < http.StatusBadRequest {
+		link.Status = service.SourceStatusOK
+	}
+
+	return link
+}
+
+func (c *Checker) fetch(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "factoid-linkcheck")
+
+	rsp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused
+	// without downloading entire pages.
+	_, _ = io.Copy(io.Discard, io.LimitReader(rsp.Body, 64<<10))
+
+	return rsp, nil
+}
+
+// byHost orders urls so that consecutive ones are for different hosts
+// where possible, taking one from each host in turn.
+func byHost(urls []string) []string {
+	var (
+		order  []string
+		queues = make(map[string][]string)
+	)
+	for _, u := range urls {
+		host := hostOf(u)
+		if _, ok := queues[host]; !ok {
+			order = append(order, host)
+		}
+		queues[host] = append(queues[host], u)
+	}
+
+	result := make([]string, 0, len(urls))
+	for len(result) < len(urls) {
+		for _, host := range order {
+			if q := queues[host]; len(q) > 0 {
+				result = append(result, q[0])
+				queues[host] = q[1:]
+			}
+		}
+	}
+	return result
+}
+
+func hostOf(u string) string {
+	if parsed, err := url.Parse(u); err == nil {
+		return parsed.Host
+	}
+	return u
+}
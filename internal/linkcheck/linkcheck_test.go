@@ -0,0 +1,245 @@
+package linkcheck_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/connorkuehl/factoid/internal/linkcheck"
+	sqliterepo "github.com/connorkuehl/factoid/internal/repo/sqlite"
+	"github.com/connorkuehl/factoid/internal/service"
+)
+
+func newTestDB(t *testing.T) (*sqliterepo.Repo, func()) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(sqliterepo.Schema())
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+
+	return sqliterepo.NewRepo(db), func() { db.Close() }
+}
+
+func TestCheckOnce(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	sources := httptest.NewServer(mux)
+	defer sources.Close()
+
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	for _, path := range []string{"/ok", "/gone", "/moved", "/no-head"} {
+		_, err := r.CreateFact(context.TODO(), service.Fact{
+			Content:   "cites " + path,
+			Citations: []service.Citation{{URL: sources.URL + path}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checker := linkcheck.New(r, linkcheck.WithHTTPClient(sources.Client()))
+	if err := checker.CheckOnce(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	moved := checker.Check(context.TODO(), sources.URL+"/moved")
+	if want := sources.URL + "/ok"; moved.FinalURL != want {
+		t.Errorf("want final URL %q, got %q", want, moved.FinalURL)
+	}
+
+	// Everything was just checked, so nothing should be stale.
+	stale, err := r.StaleSourceLinks(context.TODO(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stale) != 0 {
+		t.Errorf("want no stale links, got %v", stale)
+	}
+
+	ts := httptest.NewServer(service.New(r).Routes())
+	defer ts.Close()
+
+	tests := []struct {
+		status      string
+		wantContent []string
+	}{
+		{
+			status:      service.SourceStatusBroken,
+			wantContent: []string{"cites /gone"},
+		},
+		{
+			status:      service.SourceStatusOK,
+			wantContent: []string{"cites /ok", "cites /moved", "cites /no-head"},
+		},
+		{
+			status: service.SourceStatusUnchecked,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			rsp, err := ts.Client().Get(ts.URL + "/v1/facts?source_status=" + tt.status)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rsp.Body.Close()
+
+			var response struct {
+				Facts []service.Fact `json:"facts"`
+			}
+			if err := json.NewDecoder(rsp.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, f := range response.Facts {
+				got = append(got, f.Content)
+			}
+
+			if !reflect.DeepEqual(tt.wantContent, got) {
+				t.Errorf("want facts %q, got facts %q", tt.wantContent, got)
+			}
+		})
+	}
+}
+
+type fakeRepo struct {
+	urls []string
+
+	mu      sync.Mutex
+	results []service.SourceLink
+}
+
+func (f *fakeRepo) StaleSourceLinks(context.Context, time.Time) ([]string, error) {
+	return f.urls, nil
+}
+
+func (f *fakeRepo) RecordSourceLink(_ context.Context, link service.SourceLink) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.results = append(f.results, link)
+	return nil
+}
+
+func TestCheckOnceRespectsPerHostLimit(t *testing.T) {
+	var inFlight, maxInFlight int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	repo := &fakeRepo{}
+	for _, path := range []string{"/a", "/b", "/c", "/d", "/e", "/f", "/g", "/h"} {
+		repo.urls = append(repo.urls, ts.URL+path)
+	}
+
+	checker := linkcheck.New(
+		repo,
+		linkcheck.WithHTTPClient(ts.Client()),
+		linkcheck.WithPerHostLimit(2),
+	)
+	if err := checker.CheckOnce(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	if max := atomic.LoadInt32(&maxInFlight); max > 2 {
+		t.Errorf("want at most 2 concurrent requests, got %d", max)
+	}
+
+	if len(repo.results) != len(repo.urls) {
+		t.Fatalf("want %d results, got %d", len(repo.urls), len(repo.results))
+	}
+
+	for _, link := range repo.results {
+		if link.Status != service.SourceStatusOK {
+			t.Errorf("want %s to be %q, got %q", link.URL, service.SourceStatusOK, link.Status)
+		}
+	}
+}
+
+func TestCheckOnceRespectsConcurrency(t *testing.T) {
+	var inFlight, maxInFlight int32
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	// Every server is a different host, so only the overall limit
+	// stands in the way.
+	repo := &fakeRepo{}
+	for i := 0; i < 4; i++ {
+		ts := httptest.NewServer(handler)
+		defer ts.Close()
+
+		for _, path := range []string{"/a", "/b", "/c", "/d"} {
+			repo.urls = append(repo.urls, ts.URL+path)
+		}
+	}
+
+	checker := linkcheck.New(
+		repo,
+		linkcheck.WithPerHostLimit(4),
+		linkcheck.WithConcurrency(3),
+	)
+	if err := checker.CheckOnce(context.TODO()); err != nil {
+		t.Fatal(err)
+	}
+
+	if max := atomic.LoadInt32(&maxInFlight); max > 3 {
+		t.Errorf("want at most 3 concurrent requests, got %d", max)
+	}
+
+	if len(repo.results) != len(repo.urls) {
+		t.Fatalf("want %d results, got %d", len(repo.urls), len(repo.results))
+	}
+}
@@ -0,0 +1,175 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/connorkuehl/factoid/internal/service"
+)
+
+// exportBatchSize is how many facts ExportFacts reads at a time.
+const exportBatchSize = 100
+
+// exportFacts reads the batch of facts after a given ID along with their
+// citations. It's written by hand rather than generated, because it's
+// one row per citation, or one row with NULL citation columns for a fact
+// that has none.
+const exportFacts = `
+SELECT facts.id, facts.created_at, facts.updated_at, facts.deleted_at, facts.content, facts.source,
+	facts.weight, facts.served_count, facts.last_served_at, facts.version,
+	citations.url, citations.title, citations.author, citations.publisher, citations.accessed, citations.archive_url
+FROM facts
+LEFT JOIN citations ON citations.fact_id = facts.id
+WHERE facts.id IN (
+	SELECT id FROM facts
+	WHERE id > ? AND (? OR deleted_at IS NULL)
+	ORDER BY id LIMIT ?
+)
+ORDER BY facts.id, citations.position
+`
+
+// ExportFacts calls fn with every fact in order of ID. Facts are read a
+// batch at a time, and nothing is held open while fn runs, so a slow
+// consumer doesn't keep the database from anybody else.
+func (r *Repo) ExportFacts(ctx context.Context, opts service.ExportOptions, fn func(service.FactRecord) error) error {
+	var after int64
+	for {
+		batch, err := r.exportBatch(ctx, after, opts.IncludeDeleted)
+		if err != nil {
+			return err
+		}
+
+		for _, rec := range batch {
+			if err := fn(rec); err != nil {
+				return err
+			}
+		}
+
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+		after = batch[len(batch)-1].ID
+	}
+}
+
+// exportBatch reads up to exportBatchSize facts with IDs after after.
+func (r *Repo) exportBatch(ctx context.Context, after int64, includeDeleted bool) ([]service.FactRecord, error) {
+	rows, err := r.db.QueryContext(ctx, exportFacts, after, includeDeleted, exportBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []service.FactRecord
+	for rows.Next() {
+		var (
+			f Fact
+			c struct {
+				Url, Title, Author, Publisher, Accessed, ArchiveUrl sql.NullString
+			}
+		)
+
+		err := rows.Scan(
+			&f.ID,
+			&f.CreatedAt,
+			&f.UpdatedAt,
+			&f.DeletedAt,
+			&f.Content,
+			&f.Source,
+			&f.Weight,
+			&f.ServedCount,
+			&f.LastServedAt,
+			&f.Version,
+			&c.Url,
+			&c.Title,
+			&c.Author,
+			&c.Publisher,
+			&c.Accessed,
+			&c.ArchiveUrl,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(batch) == 0 || batch[len(batch)-1].ID != f.ID {
+			batch = append(batch, ModelToRecord(f))
+		}
+
+		if c.Url.Valid {
+			rec := &batch[len(batch)-1]
+			rec.Citations = append(rec.Citations, service.Citation{
+				URL:        c.Url.String,
+				Title:      c.Title.String,
+				Author:     c.Author.String,
+				Publisher:  c.Publisher.String,
+				Accessed:   c.Accessed.String,
+				ArchiveURL: c.ArchiveUrl.String,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return batch, rows.Close()
+}
+
+// ImportFact creates a fact exactly as described by rec, keeping its ID,
+// timestamps, version and statistics. If the ID is already taken,
+// service.ErrConflict is returned.
+func (r *Repo) ImportFact(ctx context.Context, rec service.FactRecord) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	db := New(tx)
+	n, err := db.ImportFact(ctx, ImportFactParams{
+		ID:           rec.ID,
+		CreatedAt:    sqliteTime(rec.CreatedAt),
+		UpdatedAt:    sqliteTime(rec.UpdatedAt),
+		DeletedAt:    nullSqliteTime(rec.DeletedAt),
+		Content:      rec.Content,
+		Source:       sql.NullString{String: rec.Source, Valid: true},
+		Weight:       rec.Weight,
+		ServedCount:  rec.ServedCount,
+		LastServedAt: nullSqliteTime(rec.LastServedAt),
+		Version:      rec.Version,
+	})
+	if err != nil {
+		return ErrToDomainErr(err)
+	}
+
+	if n == 0 {
+		return service.ErrConflict
+	}
+
+	if err := createCitations(ctx, db, rec.ID, rec.Citations); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func ModelToRecord(f Fact) service.FactRecord {
+	rec := service.FactRecord{
+		Fact:        ModelToDomain(f),
+		ServedCount: f.ServedCount,
+	}
+	if f.DeletedAt.Valid {
+		rec.DeletedAt = &f.DeletedAt.Time
+	}
+	if f.LastServedAt.Valid {
+		rec.LastServedAt = &f.LastServedAt.Time
+	}
+	return rec
+}
+
+func nullSqliteTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return sqliteTime(*t)
+}
@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/connorkuehl/factoid/internal/service"
+)
+
+func (r *Repo) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, expiredBefore time.Time) (service.IdempotentResponse, bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return service.IdempotentResponse{}, false, err
+	}
+	defer tx.Rollback()
+
+	db := New(tx)
+	if err := db.DeleteExpiredIdempotencyKeys(ctx, sqliteTime(expiredBefore)); err != nil {
+		return service.IdempotentResponse{}, false, ErrToDomainErr(err)
+	}
+
+	n, err := db.CreateIdempotencyKey(ctx, CreateIdempotencyKeyParams{
+		Key:         key,
+		Fingerprint: fingerprint,
+	})
+	if err != nil {
+		return service.IdempotentResponse{}, false, ErrToDomainErr(err)
+	}
+
+	if n == 1 {
+		return service.IdempotentResponse{Key: key, Fingerprint: fingerprint}, true, tx.Commit()
+	}
+
+	result, err := db.GetIdempotencyKey(ctx, key)
+	if err != nil {
+		return service.IdempotentResponse{}, false, ErrToDomainErr(err)
+	}
+
+	rsp := service.IdempotentResponse{
+		Key:         result.Key,
+		Fingerprint: result.Fingerprint,
+		Status:      int(result.Status),
+		Body:        result.Body,
+	}
+	if err := json.Unmarshal([]byte(result.Header), &rsp.Header); err != nil {
+		return service.IdempotentResponse{}, false, err
+	}
+
+	return rsp, false, tx.Commit()
+}
+
+func (r *Repo) CompleteIdempotencyKey(ctx context.Context, rsp service.IdempotentResponse) error {
+	header := rsp.Header
+	if header == nil {
+		header = http.Header{}
+	}
+
+	blob, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	// A nil body would be stored as NULL.
+	body := rsp.Body
+	if body == nil {
+		body = []byte{}
+	}
+
+	db := New(r.db)
+	err = db.CompleteIdempotencyKey(ctx, CompleteIdempotencyKeyParams{
+		Status: int64(rsp.Status),
+		Header: string(blob),
+		Body:   body,
+		Key:    rsp.Key,
+	})
+	return ErrToDomainErr(err)
+}
+
+func (r *Repo) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	db := New(r.db)
+	err := db.DeleteIdempotencyKey(ctx, key)
+	return ErrToDomainErr(err)
+}
@@ -0,0 +1,84 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// factsColumns are the columns added to facts since it was first
+// created, in the order they were added.
+var factsColumns = []struct {
+	name, definition string
+}{
+	{"weight", "REAL NOT NULL DEFAULT 1"},
+	{"served_count", "INTEGER NOT NULL DEFAULT 0"},
+	{"last_served_at", "TIMESTAMP DEFAULT NULL"},
+	{"version", "INTEGER NOT NULL DEFAULT 1"},
+	{"weight_end", "REAL NOT NULL DEFAULT 0"},
+}
+
+// backfillWeightEnd fills in weight_end for facts that existed before
+// it did. From then on, triggers keep it up to date.
+const backfillWeightEnd = `
+UPDATE facts
+SET weight_end = totals.weight_end
+FROM (
+	SELECT id, SUM(CASE WHEN deleted_at IS NULL THEN MAX(weight, 0) ELSE 0 END) OVER (ORDER BY id) AS weight_end
+	FROM facts
+) AS totals
+WHERE facts.id = totals.id
+`
+
+// Migrate brings the schema of db up to date, creating whatever tables,
+// indexes and columns are missing. It's safe to run on every start, on
+// a new database as well as on one created by an older version.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	have, err := columns(ctx, db, "facts")
+	if err != nil {
+		return err
+	}
+
+	// Columns are added before the rest of the schema is created, since
+	// its indexes and triggers refer to them. A new database has no
+	// facts table yet, and gets every column from the schema instead.
+	if len(have) > 0 {
+		for _, c := range factsColumns {
+			if have[c.name] {
+				continue
+			}
+
+			if _, err := db.ExecContext(ctx, fmt.Sprintf("ALTER TABLE facts ADD COLUMN %s %s", c.name, c.definition)); err != nil {
+				return fmt.Errorf("add facts.%s: %w", c.name, err)
+			}
+		}
+
+		if !have["weight_end"] {
+			if _, err := db.ExecContext(ctx, backfillWeightEnd); err != nil {
+				return fmt.Errorf("backfill facts.weight_end: %w", err)
+			}
+		}
+	}
+
+	_, err = db.ExecContext(ctx, schema)
+	return err
+}
+
+// columns returns the names of table's columns.
+func columns(ctx context.Context, db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[name] = true
+	}
+	return names, rows.Err()
+}
@@ -6,13 +6,89 @@ package sqlite
 
 import (
 	"database/sql"
+	"time"
 )
 
+type Citation struct {
+	ID         int64
+	FactID     int64
+	Position   int64
+	Url        string
+	Title      string
+	Author     string
+	Publisher  string
+	Accessed   string
+	ArchiveUrl string
+}
+
+type DailyFact struct {
+	Day    string
+	FactID int64
+	Pinned bool
+}
+
 type Fact struct {
+	ID           int64
+	CreatedAt    sql.NullTime
+	UpdatedAt    sql.NullTime
+	DeletedAt    sql.NullTime
+	Content      string
+	Source       sql.NullString
+	Weight       float64
+	ServedCount  int64
+	LastServedAt sql.NullTime
+	Version      int64
+	WeightEnd    float64
+}
+
+type IdempotencyKey struct {
+	Key         string
+	Fingerprint string
+	Status      int64
+	Header      string
+	Body        []byte
+	CreatedAt   sql.NullTime
+}
+
+type RandomSession struct {
+	Token  string
+	FactID int64
+	SeenAt sql.NullTime
+}
+
+type SourceLink struct {
+	Url        string
+	Status     string
+	StatusCode int64
+	FinalUrl   string
+	Error      string
+	CheckedAt  time.Time
+}
+
+type Webhook struct {
 	ID        int64
+	Url       string
+	Events    string
+	Secret    string
 	CreatedAt sql.NullTime
 	UpdatedAt sql.NullTime
-	DeletedAt sql.NullTime
-	Content   string
-	Source    sql.NullString
+}
+
+type WebhookAttempt struct {
+	ID          int64
+	DeliveryID  int64
+	AttemptedAt time.Time
+	StatusCode  int64
+	Error       string
+	DurationMs  int64
+}
+
+type WebhookDelivery struct {
+	ID            int64
+	WebhookID     int64
+	EventType     string
+	Payload       []byte
+	Status        string
+	NextAttemptAt sql.NullTime
+	CreatedAt     sql.NullTime
 }
@@ -1,27 +1,296 @@
 -- name: GetFact :one
-SELECT id, created_at, updated_at, deleted_at, content, source
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
 FROM facts
 WHERE id = ? AND deleted_at IS NULL LIMIT 1;
 
 -- name: GetFacts :many
-SELECT id, created_at, updated_at, deleted_at, content, source
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
 FROM facts
-WHERE deleted_at IS NULL;
+WHERE deleted_at IS NULL AND id > ? AND content LIKE ? ESCAPE '\'
+ORDER BY id
+LIMIT ?;
 
--- name: GetRandomFact :one
-SELECT id, created_at, updated_at, deleted_at, content, source
+-- name: GetFactsBySourceStatus :many
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+FROM facts
+WHERE deleted_at IS NULL AND EXISTS (
+	SELECT 1
+	FROM citations
+	LEFT JOIN source_links ON source_links.url = citations.url
+	WHERE citations.fact_id = facts.id AND COALESCE(source_links.status, 'unchecked') = ?
+) AND id > ? AND content LIKE ? ESCAPE '\'
+ORDER BY id
+LIMIT ?;
+
+-- name: GetFactsLastModified :one
+SELECT updated_at
+FROM facts
+ORDER BY updated_at DESC
+LIMIT 1;
+
+-- name: GetRecentFacts :many
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
 FROM facts
 WHERE deleted_at IS NULL
-ORDER BY RANDOM() LIMIT 1;
+ORDER BY created_at DESC, id DESC
+LIMIT ?;
+
+-- name: GetDeletedFacts :many
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+FROM facts
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC, id DESC
+LIMIT ?;
+
+-- name: GetFactIDBounds :one
+SELECT CAST(COALESCE(MIN(id), 0) AS INTEGER) AS min_id, CAST(COALESCE(MAX(id), 0) AS INTEGER) AS max_id
+FROM facts;
+
+-- name: GetRandomFact :one
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+FROM facts
+WHERE id >= ? AND deleted_at IS NULL
+	AND id NOT IN (SELECT value FROM json_each(?))
+	AND id NOT IN (SELECT fact_id FROM random_sessions WHERE token = ?)
+ORDER BY id LIMIT 1;
+
+-- name: GetTotalWeight :one
+SELECT CAST(COALESCE(MAX(weight_end), 0) AS REAL) AS total
+FROM facts;
+
+-- name: GetFactByWeight :one
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+FROM facts
+WHERE weight_end > ? AND deleted_at IS NULL AND weight > 0
+ORDER BY weight_end, id
+LIMIT 1;
+
+-- name: IsFactSeen :one
+SELECT EXISTS (SELECT 1 FROM random_sessions WHERE token = ? AND fact_id = ?);
+
+-- name: GetWeightedRandomFact :one
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+FROM facts
+WHERE deleted_at IS NULL AND weight > 0
+	AND id NOT IN (SELECT value FROM json_each(?))
+	AND id NOT IN (SELECT fact_id FROM random_sessions WHERE token = ?)
+ORDER BY weighted_rank(id, ?, CASE ?
+	WHEN 'favor-recent' THEN weight / (1 + MAX(julianday(?) - julianday(created_at), 0) / 30.0)
+	WHEN 'favor-unseen' THEN weight / (1 + served_count)
+	ELSE weight
+END) DESC
+LIMIT 1;
 
 -- name: CreateFact :one
-INSERT INTO facts (content, source) VALUES (?, ?)
-RETURNING id, created_at, updated_at, deleted_at, content, source;
+INSERT INTO facts (content, source, weight) VALUES (?, ?, ?)
+RETURNING id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version;
+
+-- name: UpdateFact :one
+UPDATE facts
+SET content = COALESCE(?, content),
+	source = COALESCE(?, source),
+	weight = COALESCE(?, weight),
+	updated_at = CURRENT_TIMESTAMP,
+	version = version + 1
+WHERE id = ? AND deleted_at IS NULL AND version = COALESCE(?, version)
+RETURNING id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version;
+
+-- name: ImportFact :execrows
+INSERT INTO facts (id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version)
+VALUES (?, datetime(?), datetime(?), datetime(?), ?, ?, ?, ?, datetime(?), ?)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: MarkFactServed :exec
+UPDATE facts
+SET served_count = served_count + 1, last_served_at = CURRENT_TIMESTAMP
+WHERE id = ?;
 
 -- name: DeleteFact :exec
 DELETE FROM facts WHERE id = ?;
 
--- name: SoftDeleteFact :exec
+-- name: SoftDeleteFact :execrows
 UPDATE facts
-SET deleted_at = DATETIME('now')
+SET deleted_at = DATETIME('now'),
+	updated_at = CURRENT_TIMESTAMP,
+	version = version + 1
+WHERE id = ? AND deleted_at IS NULL AND version = COALESCE(?, version);
+
+-- name: RestoreFact :one
+UPDATE facts
+SET deleted_at = NULL,
+	updated_at = CURRENT_TIMESTAMP,
+	version = version + 1
+WHERE id = ? AND deleted_at IS NOT NULL
+RETURNING id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version;
+
+-- name: GetCitations :many
+SELECT id, fact_id, position, url, title, author, publisher, accessed, archive_url
+FROM citations
+WHERE fact_id = ?
+ORDER BY position;
+
+-- name: GetCitationsForFacts :many
+SELECT id, fact_id, position, url, title, author, publisher, accessed, archive_url
+FROM citations
+WHERE fact_id IN (SELECT value FROM json_each(?))
+ORDER BY fact_id, position;
+
+-- name: DeleteCitations :exec
+DELETE FROM citations WHERE fact_id = ?;
+
+-- name: CreateCitation :exec
+INSERT INTO citations (fact_id, position, url, title, author, publisher, accessed, archive_url)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: GetStaleSourceLinks :many
+SELECT DISTINCT citations.url
+FROM citations
+JOIN facts ON facts.id = citations.fact_id
+LEFT JOIN source_links ON source_links.url = citations.url
+WHERE facts.deleted_at IS NULL
+	AND (source_links.checked_at IS NULL OR source_links.checked_at < datetime(?))
+ORDER BY citations.url;
+
+-- name: UpsertSourceLink :exec
+INSERT INTO source_links (url, status, status_code, final_url, error, checked_at)
+VALUES (?, ?, ?, ?, ?, datetime(?))
+ON CONFLICT (url) DO UPDATE SET
+	status = excluded.status,
+	status_code = excluded.status_code,
+	final_url = excluded.final_url,
+	error = excluded.error,
+	checked_at = excluded.checked_at;
+
+-- name: GetFactIDsCreatedBefore :many
+SELECT id
+FROM facts
+WHERE deleted_at IS NULL AND created_at < datetime(?)
+ORDER BY id;
+
+-- name: GetDailyFact :one
+SELECT day, fact_id, pinned
+FROM daily_facts
+WHERE day = ?;
+
+-- name: GetDailyFactIDsBetween :many
+SELECT fact_id
+FROM daily_facts
+WHERE day >= ? AND day < ?;
+
+-- name: CreateDailyFact :exec
+INSERT INTO daily_facts (day, fact_id) VALUES (?, ?)
+ON CONFLICT (day) DO NOTHING;
+
+-- name: PinDailyFact :exec
+INSERT INTO daily_facts (day, fact_id, pinned) VALUES (?, ?, TRUE)
+ON CONFLICT (day) DO UPDATE SET fact_id = excluded.fact_id, pinned = TRUE;
+
+-- name: DeleteDailyFact :exec
+DELETE FROM daily_facts WHERE day = ?;
+
+-- name: CreateRandomSessionFact :exec
+INSERT INTO random_sessions (token, fact_id) VALUES (?, ?)
+ON CONFLICT (token, fact_id) DO NOTHING;
+
+-- name: DeleteRandomSession :exec
+DELETE FROM random_sessions WHERE token = ?;
+
+-- name: DeleteIdleRandomSessions :exec
+DELETE FROM random_sessions
+WHERE token IN (
+	SELECT token
+	FROM random_sessions
+	GROUP BY token
+	HAVING MAX(seen_at) < datetime(?)
+);
+
+-- name: CreateIdempotencyKey :execrows
+INSERT INTO idempotency_keys (key, fingerprint) VALUES (?, ?)
+ON CONFLICT (key) DO NOTHING;
+
+-- name: GetIdempotencyKey :one
+SELECT key, fingerprint, status, header, body, created_at
+FROM idempotency_keys
+WHERE key = ?;
+
+-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET status = ?, header = ?, body = ?
+WHERE key = ?;
+
+-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE key = ?;
+
+-- name: DeleteExpiredIdempotencyKeys :exec
+DELETE FROM idempotency_keys WHERE created_at < datetime(?);
+
+-- name: CreateWebhook :one
+INSERT INTO webhooks (url, events, secret) VALUES (?, ?, ?)
+RETURNING id, url, events, secret, created_at, updated_at;
+
+-- name: GetWebhooks :many
+SELECT id, url, events, secret, created_at, updated_at
+FROM webhooks
+ORDER BY id;
+
+-- name: GetWebhook :one
+SELECT id, url, events, secret, created_at, updated_at
+FROM webhooks
+WHERE id = ?;
+
+-- name: UpdateWebhook :one
+UPDATE webhooks
+SET url = COALESCE(?, url),
+	events = COALESCE(?, events),
+	secret = COALESCE(?, secret),
+	updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+RETURNING id, url, events, secret, created_at, updated_at;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = ?;
+
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (webhook_id, event_type, payload, next_attempt_at)
+VALUES (?, ?, ?, datetime(?));
+
+-- name: GetWebhookDeliveries :many
+SELECT id, webhook_id, event_type, payload, status, next_attempt_at, created_at
+FROM webhook_deliveries
+WHERE webhook_id = ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: GetWebhookDelivery :one
+SELECT id, webhook_id, event_type, payload, status, next_attempt_at, created_at
+FROM webhook_deliveries
 WHERE id = ?;
+
+-- name: GetDueWebhookDeliveries :many
+SELECT id, webhook_id, event_type, payload, status, next_attempt_at, created_at
+FROM webhook_deliveries
+WHERE status = 'pending' AND next_attempt_at <= datetime(?)
+ORDER BY next_attempt_at, id
+LIMIT ?;
+
+-- name: UpdateWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET status = ?, next_attempt_at = datetime(?)
+WHERE id = ?;
+
+-- name: DeleteWebhookDeliveries :exec
+DELETE FROM webhook_deliveries WHERE webhook_id = ?;
+
+-- name: CreateWebhookAttempt :exec
+INSERT INTO webhook_attempts (delivery_id, attempted_at, status_code, error, duration_ms)
+VALUES (?, datetime(?), ?, ?, ?);
+
+-- name: GetWebhookAttempts :many
+SELECT id, delivery_id, attempted_at, status_code, error, duration_ms
+FROM webhook_attempts
+WHERE delivery_id = ?
+ORDER BY id;
+
+-- name: DeleteWebhookAttempts :exec
+DELETE FROM webhook_attempts
+WHERE delivery_id IN (SELECT id FROM webhook_deliveries WHERE webhook_id = ?);
@@ -10,18 +10,87 @@ import (
 	"database/sql"
 )
 
+const completeIdempotencyKey = `-- name: CompleteIdempotencyKey :exec
+UPDATE idempotency_keys
+SET status = ?, header = ?, body = ?
+WHERE key = ?
+`
+
+type CompleteIdempotencyKeyParams struct {
+	Status int64
+	Header string
+	Body   []byte
+	Key    string
+}
+
+func (q *Queries) CompleteIdempotencyKey(ctx context.Context, arg CompleteIdempotencyKeyParams) error {
+	_, err := q.db.ExecContext(ctx, completeIdempotencyKey,
+		arg.Status,
+		arg.Header,
+		arg.Body,
+		arg.Key,
+	)
+	return err
+}
+
+const createCitation = `-- name: CreateCitation :exec
+INSERT INTO citations (fact_id, position, url, title, author, publisher, accessed, archive_url)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type CreateCitationParams struct {
+	FactID     int64
+	Position   int64
+	Url        string
+	Title      string
+	Author     string
+	Publisher  string
+	Accessed   string
+	ArchiveUrl string
+}
+
+func (q *Queries) CreateCitation(ctx context.Context, arg CreateCitationParams) error {
+	_, err := q.db.ExecContext(ctx, createCitation,
+		arg.FactID,
+		arg.Position,
+		arg.Url,
+		arg.Title,
+		arg.Author,
+		arg.Publisher,
+		arg.Accessed,
+		arg.ArchiveUrl,
+	)
+	return err
+}
+
+const createDailyFact = `-- name: CreateDailyFact :exec
+INSERT INTO daily_facts (day, fact_id) VALUES (?, ?)
+ON CONFLICT (day) DO NOTHING
+`
+
+type CreateDailyFactParams struct {
+	Day    string
+	FactID int64
+}
+
+func (q *Queries) CreateDailyFact(ctx context.Context, arg CreateDailyFactParams) error {
+	_, err := q.db.ExecContext(ctx, createDailyFact, arg.Day, arg.FactID)
+	return err
+}
+
 const createFact = `-- name: CreateFact :one
-INSERT INTO facts (content, source) VALUES (?, ?)
-RETURNING id, created_at, updated_at, deleted_at, content, source
+INSERT INTO facts (content, source, weight) VALUES (?, ?, ?)
+RETURNING id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
 `
 
 type CreateFactParams struct {
 	Content string
 	Source  sql.NullString
+	Weight  float64
 }
 
 func (q *Queries) CreateFact(ctx context.Context, arg CreateFactParams) (Fact, error) {
-	row := q.db.QueryRowContext(ctx, createFact, arg.Content, arg.Source)
+	row := q.db.QueryRowContext(ctx, createFact, arg.Content, arg.Source, arg.Weight)
 	var i Fact
 	err := row.Scan(
 		&i.ID,
@@ -30,47 +99,355 @@ func (q *Queries) CreateFact(ctx context.Context, arg CreateFactParams) (Fact, e
 		&i.DeletedAt,
 		&i.Content,
 		&i.Source,
+		&i.Weight,
+		&i.ServedCount,
+		&i.LastServedAt,
+		&i.Version,
 	)
 	return i, err
 }
 
-const deleteFact = `-- name: DeleteFact :exec
-DELETE FROM facts WHERE id = ?
+const createIdempotencyKey = `-- name: CreateIdempotencyKey :execrows
+INSERT INTO idempotency_keys (key, fingerprint) VALUES (?, ?)
+ON CONFLICT (key) DO NOTHING
 `
 
-func (q *Queries) DeleteFact(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, deleteFact, id)
+type CreateIdempotencyKeyParams struct {
+	Key         string
+	Fingerprint string
+}
+
+func (q *Queries) CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, createIdempotencyKey, arg.Key, arg.Fingerprint)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const createRandomSessionFact = `-- name: CreateRandomSessionFact :exec
+INSERT INTO random_sessions (token, fact_id) VALUES (?, ?)
+ON CONFLICT (token, fact_id) DO NOTHING
+`
+
+type CreateRandomSessionFactParams struct {
+	Token  string
+	FactID int64
+}
+
+func (q *Queries) CreateRandomSessionFact(ctx context.Context, arg CreateRandomSessionFactParams) error {
+	_, err := q.db.ExecContext(ctx, createRandomSessionFact, arg.Token, arg.FactID)
 	return err
 }
 
-const getFact = `-- name: GetFact :one
-SELECT id, created_at, updated_at, deleted_at, content, source
-FROM facts
-WHERE id = ? AND deleted_at IS NULL LIMIT 1
+const createWebhook = `-- name: CreateWebhook :one
+INSERT INTO webhooks (url, events, secret) VALUES (?, ?, ?)
+RETURNING id, url, events, secret, created_at, updated_at
 `
 
-func (q *Queries) GetFact(ctx context.Context, id int64) (Fact, error) {
-	row := q.db.QueryRowContext(ctx, getFact, id)
-	var i Fact
+type CreateWebhookParams struct {
+	Url    string
+	Events string
+	Secret string
+}
+
+func (q *Queries) CreateWebhook(ctx context.Context, arg CreateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, createWebhook, arg.Url, arg.Events, arg.Secret)
+	var i Webhook
 	err := row.Scan(
 		&i.ID,
+		&i.Url,
+		&i.Events,
+		&i.Secret,
 		&i.CreatedAt,
 		&i.UpdatedAt,
-		&i.DeletedAt,
-		&i.Content,
-		&i.Source,
 	)
 	return i, err
 }
 
-const getFacts = `-- name: GetFacts :many
-SELECT id, created_at, updated_at, deleted_at, content, source
+const createWebhookAttempt = `-- name: CreateWebhookAttempt :exec
+INSERT INTO webhook_attempts (delivery_id, attempted_at, status_code, error, duration_ms)
+VALUES (?, datetime(?), ?, ?, ?)
+`
+
+type CreateWebhookAttemptParams struct {
+	DeliveryID  int64
+	AttemptedAt interface{}
+	StatusCode  int64
+	Error       string
+	DurationMs  int64
+}
+
+func (q *Queries) CreateWebhookAttempt(ctx context.Context, arg CreateWebhookAttemptParams) error {
+	_, err := q.db.ExecContext(ctx, createWebhookAttempt,
+		arg.DeliveryID,
+		arg.AttemptedAt,
+		arg.StatusCode,
+		arg.Error,
+		arg.DurationMs,
+	)
+	return err
+}
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (webhook_id, event_type, payload, next_attempt_at)
+VALUES (?, ?, ?, datetime(?))
+`
+
+type CreateWebhookDeliveryParams struct {
+	WebhookID     int64
+	EventType     string
+	Payload       []byte
+	NextAttemptAt interface{}
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, createWebhookDelivery,
+		arg.WebhookID,
+		arg.EventType,
+		arg.Payload,
+		arg.NextAttemptAt,
+	)
+	return err
+}
+
+const deleteCitations = `-- name: DeleteCitations :exec
+DELETE FROM citations WHERE fact_id = ?
+`
+
+func (q *Queries) DeleteCitations(ctx context.Context, factID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteCitations, factID)
+	return err
+}
+
+const deleteDailyFact = `-- name: DeleteDailyFact :exec
+DELETE FROM daily_facts WHERE day = ?
+`
+
+func (q *Queries) DeleteDailyFact(ctx context.Context, day string) error {
+	_, err := q.db.ExecContext(ctx, deleteDailyFact, day)
+	return err
+}
+
+const deleteExpiredIdempotencyKeys = `-- name: DeleteExpiredIdempotencyKeys :exec
+DELETE FROM idempotency_keys WHERE created_at < datetime(?)
+`
+
+func (q *Queries) DeleteExpiredIdempotencyKeys(ctx context.Context, createdBefore interface{}) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredIdempotencyKeys, createdBefore)
+	return err
+}
+
+const deleteFact = `-- name: DeleteFact :exec
+DELETE FROM facts WHERE id = ?
+`
+
+func (q *Queries) DeleteFact(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteFact, id)
+	return err
+}
+
+const deleteIdempotencyKey = `-- name: DeleteIdempotencyKey :exec
+DELETE FROM idempotency_keys WHERE key = ?
+`
+
+func (q *Queries) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	_, err := q.db.ExecContext(ctx, deleteIdempotencyKey, key)
+	return err
+}
+
+const deleteIdleRandomSessions = `-- name: DeleteIdleRandomSessions :exec
+DELETE FROM random_sessions
+WHERE token IN (
+	SELECT token
+	FROM random_sessions
+	GROUP BY token
+	HAVING MAX(seen_at) < datetime(?)
+)
+`
+
+func (q *Queries) DeleteIdleRandomSessions(ctx context.Context, idleSince interface{}) error {
+	_, err := q.db.ExecContext(ctx, deleteIdleRandomSessions, idleSince)
+	return err
+}
+
+const deleteRandomSession = `-- name: DeleteRandomSession :exec
+DELETE FROM random_sessions WHERE token = ?
+`
+
+func (q *Queries) DeleteRandomSession(ctx context.Context, token string) error {
+	_, err := q.db.ExecContext(ctx, deleteRandomSession, token)
+	return err
+}
+
+const deleteWebhook = `-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = ?
+`
+
+func (q *Queries) DeleteWebhook(ctx context.Context, id int64) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteWebhook, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteWebhookAttempts = `-- name: DeleteWebhookAttempts :exec
+DELETE FROM webhook_attempts
+WHERE delivery_id IN (SELECT id FROM webhook_deliveries WHERE webhook_id = ?)
+`
+
+func (q *Queries) DeleteWebhookAttempts(ctx context.Context, webhookID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteWebhookAttempts, webhookID)
+	return err
+}
+
+const deleteWebhookDeliveries = `-- name: DeleteWebhookDeliveries :exec
+DELETE FROM webhook_deliveries WHERE webhook_id = ?
+`
+
+func (q *Queries) DeleteWebhookDeliveries(ctx context.Context, webhookID int64) error {
+	_, err := q.db.ExecContext(ctx, deleteWebhookDeliveries, webhookID)
+	return err
+}
+
+const getCitations = `-- name: GetCitations :many
+SELECT id, fact_id, position, url, title, author, publisher, accessed, archive_url
+FROM citations
+WHERE fact_id = ?
+ORDER BY position
+`
+
+func (q *Queries) GetCitations(ctx context.Context, factID int64) ([]Citation, error) {
+	rows, err := q.db.QueryContext(ctx, getCitations, factID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Citation
+	for rows.Next() {
+		var i Citation
+		if err := rows.Scan(
+			&i.ID,
+			&i.FactID,
+			&i.Position,
+			&i.Url,
+			&i.Title,
+			&i.Author,
+			&i.Publisher,
+			&i.Accessed,
+			&i.ArchiveUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCitationsForFacts = `-- name: GetCitationsForFacts :many
+SELECT id, fact_id, position, url, title, author, publisher, accessed, archive_url
+FROM citations
+WHERE fact_id IN (SELECT value FROM json_each(?))
+ORDER BY fact_id, position
+`
+
+func (q *Queries) GetCitationsForFacts(ctx context.Context, ids interface{}) ([]Citation, error) {
+	rows, err := q.db.QueryContext(ctx, getCitationsForFacts, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Citation
+	for rows.Next() {
+		var i Citation
+		if err := rows.Scan(
+			&i.ID,
+			&i.FactID,
+			&i.Position,
+			&i.Url,
+			&i.Title,
+			&i.Author,
+			&i.Publisher,
+			&i.Accessed,
+			&i.ArchiveUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDailyFact = `-- name: GetDailyFact :one
+SELECT day, fact_id, pinned
+FROM daily_facts
+WHERE day = ?
+`
+
+func (q *Queries) GetDailyFact(ctx context.Context, day string) (DailyFact, error) {
+	row := q.db.QueryRowContext(ctx, getDailyFact, day)
+	var i DailyFact
+	err := row.Scan(&i.Day, &i.FactID, &i.Pinned)
+	return i, err
+}
+
+const getDailyFactIDsBetween = `-- name: GetDailyFactIDsBetween :many
+SELECT fact_id
+FROM daily_facts
+WHERE day >= ? AND day < ?
+`
+
+type GetDailyFactIDsBetweenParams struct {
+	Day   string
+	Day_2 string
+}
+
+func (q *Queries) GetDailyFactIDsBetween(ctx context.Context, arg GetDailyFactIDsBetweenParams) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, getDailyFactIDsBetween, arg.Day, arg.Day_2)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var fact_id int64
+		if err := rows.Scan(&fact_id); err != nil {
+			return nil, err
+		}
+		items = append(items, fact_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeletedFacts = `-- name: GetDeletedFacts :many
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
 FROM facts
-WHERE deleted_at IS NULL
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC, id DESC
+LIMIT ?
 `
 
-func (q *Queries) GetFacts(ctx context.Context) ([]Fact, error) {
-	rows, err := q.db.QueryContext(ctx, getFacts)
+func (q *Queries) GetDeletedFacts(ctx context.Context, limit int64) ([]Fact, error) {
+	rows, err := q.db.QueryContext(ctx, getDeletedFacts, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +462,10 @@ func (q *Queries) GetFacts(ctx context.Context) ([]Fact, error) {
 			&i.DeletedAt,
 			&i.Content,
 			&i.Source,
+			&i.Weight,
+			&i.ServedCount,
+			&i.LastServedAt,
+			&i.Version,
 		); err != nil {
 			return nil, err
 		}
@@ -99,15 +480,58 @@ func (q *Queries) GetFacts(ctx context.Context) ([]Fact, error) {
 	return items, nil
 }
 
-const getRandomFact = `-- name: GetRandomFact :one
-SELECT id, created_at, updated_at, deleted_at, content, source
+const getDueWebhookDeliveries = `-- name: GetDueWebhookDeliveries :many
+SELECT id, webhook_id, event_type, payload, status, next_attempt_at, created_at
+FROM webhook_deliveries
+WHERE status = 'pending' AND next_attempt_at <= datetime(?)
+ORDER BY next_attempt_at, id
+LIMIT ?
+`
+
+type GetDueWebhookDeliveriesParams struct {
+	NextAttemptAt interface{}
+	Limit         int64
+}
+
+func (q *Queries) GetDueWebhookDeliveries(ctx context.Context, arg GetDueWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, getDueWebhookDeliveries, arg.NextAttemptAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFact = `-- name: GetFact :one
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
 FROM facts
-WHERE deleted_at IS NULL
-ORDER BY RANDOM() LIMIT 1
+WHERE id = ? AND deleted_at IS NULL LIMIT 1
 `
 
-func (q *Queries) GetRandomFact(ctx context.Context) (Fact, error) {
-	row := q.db.QueryRowContext(ctx, getRandomFact)
+func (q *Queries) GetFact(ctx context.Context, id int64) (Fact, error) {
+	row := q.db.QueryRowContext(ctx, getFact, id)
 	var i Fact
 	err := row.Scan(
 		&i.ID,
@@ -116,17 +540,807 @@ func (q *Queries) GetRandomFact(ctx context.Context) (Fact, error) {
 		&i.DeletedAt,
 		&i.Content,
 		&i.Source,
+		&i.Weight,
+		&i.ServedCount,
+		&i.LastServedAt,
+		&i.Version,
 	)
 	return i, err
 }
 
-const softDeleteFact = `-- name: SoftDeleteFact :exec
-UPDATE facts
-SET deleted_at = DATETIME('now')
-WHERE id = ?
+const getFactByWeight = `-- name: GetFactByWeight :one
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+FROM facts
+WHERE weight_end > ? AND deleted_at IS NULL AND weight > 0
+ORDER BY weight_end, id
+LIMIT 1
+`
+
+func (q *Queries) GetFactByWeight(ctx context.Context, weightEnd float64) (Fact, error) {
+	row := q.db.QueryRowContext(ctx, getFactByWeight, weightEnd)
+	var i Fact
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Content,
+		&i.Source,
+		&i.Weight,
+		&i.ServedCount,
+		&i.LastServedAt,
+		&i.Version,
+	)
+	return i, err
+}
+
+const getFactIDBounds = `-- name: GetFactIDBounds :one
+SELECT CAST(COALESCE(MIN(id), 0) AS INTEGER) AS min_id, CAST(COALESCE(MAX(id), 0) AS INTEGER) AS max_id
+FROM facts
+`
+
+type GetFactIDBoundsRow struct {
+	MinID int64
+	MaxID int64
+}
+
+func (q *Queries) GetFactIDBounds(ctx context.Context) (GetFactIDBoundsRow, error) {
+	row := q.db.QueryRowContext(ctx, getFactIDBounds)
+	var i GetFactIDBoundsRow
+	err := row.Scan(&i.MinID, &i.MaxID)
+	return i, err
+}
+
+const getFactIDsCreatedBefore = `-- name: GetFactIDsCreatedBefore :many
+SELECT id
+FROM facts
+WHERE deleted_at IS NULL AND created_at < datetime(?)
+ORDER BY id
 `
 
-func (q *Queries) SoftDeleteFact(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, softDeleteFact, id)
+func (q *Queries) GetFactIDsCreatedBefore(ctx context.Context, createdBefore interface{}) ([]int64, error) {
+	rows, err := q.db.QueryContext(ctx, getFactIDsCreatedBefore, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFacts = `-- name: GetFacts :many
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+FROM facts
+WHERE deleted_at IS NULL AND id > ? AND content LIKE ? ESCAPE '\'
+ORDER BY id
+LIMIT ?
+`
+
+type GetFactsParams struct {
+	ID      int64
+	Content string
+	Limit   int64
+}
+
+func (q *Queries) GetFacts(ctx context.Context, arg GetFactsParams) ([]Fact, error) {
+	rows, err := q.db.QueryContext(ctx, getFacts, arg.ID, arg.Content, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Fact
+	for rows.Next() {
+		var i Fact
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.Content,
+			&i.Source,
+			&i.Weight,
+			&i.ServedCount,
+			&i.LastServedAt,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFactsBySourceStatus = `-- name: GetFactsBySourceStatus :many
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+FROM facts
+WHERE deleted_at IS NULL AND EXISTS (
+	SELECT 1
+	FROM citations
+	LEFT JOIN source_links ON source_links.url = citations.url
+	WHERE citations.fact_id = facts.id AND COALESCE(source_links.status, 'unchecked') = ?
+) AND id > ? AND content LIKE ? ESCAPE '\'
+ORDER BY id
+LIMIT ?
+`
+
+type GetFactsBySourceStatusParams struct {
+	Status  string
+	ID      int64
+	Content string
+	Limit   int64
+}
+
+func (q *Queries) GetFactsBySourceStatus(ctx context.Context, arg GetFactsBySourceStatusParams) ([]Fact, error) {
+	rows, err := q.db.QueryContext(ctx, getFactsBySourceStatus,
+		arg.Status,
+		arg.ID,
+		arg.Content,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Fact
+	for rows.Next() {
+		var i Fact
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.Content,
+			&i.Source,
+			&i.Weight,
+			&i.ServedCount,
+			&i.LastServedAt,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFactsLastModified = `-- name: GetFactsLastModified :one
+SELECT updated_at
+FROM facts
+ORDER BY updated_at DESC
+LIMIT 1
+`
+
+func (q *Queries) GetFactsLastModified(ctx context.Context) (sql.NullTime, error) {
+	row := q.db.QueryRowContext(ctx, getFactsLastModified)
+	var updated_at sql.NullTime
+	err := row.Scan(&updated_at)
+	return updated_at, err
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT key, fingerprint, status, header, body, created_at
+FROM idempotency_keys
+WHERE key = ?
+`
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, key string) (IdempotencyKey, error) {
+	row := q.db.QueryRowContext(ctx, getIdempotencyKey, key)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Key,
+		&i.Fingerprint,
+		&i.Status,
+		&i.Header,
+		&i.Body,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getRandomFact = `-- name: GetRandomFact :one
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+FROM facts
+WHERE id >= ? AND deleted_at IS NULL
+	AND id NOT IN (SELECT value FROM json_each(?))
+	AND id NOT IN (SELECT fact_id FROM random_sessions WHERE token = ?)
+ORDER BY id LIMIT 1
+`
+
+type GetRandomFactParams struct {
+	ID      int64
+	Exclude interface{}
+	Token   string
+}
+
+func (q *Queries) GetRandomFact(ctx context.Context, arg GetRandomFactParams) (Fact, error) {
+	row := q.db.QueryRowContext(ctx, getRandomFact, arg.ID, arg.Exclude, arg.Token)
+	var i Fact
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Content,
+		&i.Source,
+		&i.Weight,
+		&i.ServedCount,
+		&i.LastServedAt,
+		&i.Version,
+	)
+	return i, err
+}
+
+const getRecentFacts = `-- name: GetRecentFacts :many
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+FROM facts
+WHERE deleted_at IS NULL
+ORDER BY created_at DESC, id DESC
+LIMIT ?
+`
+
+func (q *Queries) GetRecentFacts(ctx context.Context, limit int64) ([]Fact, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentFacts, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Fact
+	for rows.Next() {
+		var i Fact
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.DeletedAt,
+			&i.Content,
+			&i.Source,
+			&i.Weight,
+			&i.ServedCount,
+			&i.LastServedAt,
+			&i.Version,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStaleSourceLinks = `-- name: GetStaleSourceLinks :many
+SELECT DISTINCT citations.url
+FROM citations
+JOIN facts ON facts.id = citations.fact_id
+LEFT JOIN source_links ON source_links.url = citations.url
+WHERE facts.deleted_at IS NULL
+	AND (source_links.checked_at IS NULL OR source_links.checked_at < datetime(?))
+ORDER BY citations.url
+`
+
+func (q *Queries) GetStaleSourceLinks(ctx context.Context, checkedBefore interface{}) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getStaleSourceLinks, checkedBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+		items = append(items, url)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTotalWeight = `-- name: GetTotalWeight :one
+SELECT CAST(COALESCE(MAX(weight_end), 0) AS REAL) AS total
+FROM facts
+`
+
+func (q *Queries) GetTotalWeight(ctx context.Context) (float64, error) {
+	row := q.db.QueryRowContext(ctx, getTotalWeight)
+	var total float64
+	err := row.Scan(&total)
+	return total, err
+}
+
+const getWebhook = `-- name: GetWebhook :one
+SELECT id, url, events, secret, created_at, updated_at
+FROM webhooks
+WHERE id = ?
+`
+
+func (q *Queries) GetWebhook(ctx context.Context, id int64) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, getWebhook, id)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Events,
+		&i.Secret,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getWebhookAttempts = `-- name: GetWebhookAttempts :many
+SELECT id, delivery_id, attempted_at, status_code, error, duration_ms
+FROM webhook_attempts
+WHERE delivery_id = ?
+ORDER BY id
+`
+
+func (q *Queries) GetWebhookAttempts(ctx context.Context, deliveryID int64) ([]WebhookAttempt, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookAttempts, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookAttempt
+	for rows.Next() {
+		var i WebhookAttempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.DeliveryID,
+			&i.AttemptedAt,
+			&i.StatusCode,
+			&i.Error,
+			&i.DurationMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhookDeliveries = `-- name: GetWebhookDeliveries :many
+SELECT id, webhook_id, event_type, payload, status, next_attempt_at, created_at
+FROM webhook_deliveries
+WHERE webhook_id = ?
+ORDER BY id DESC
+LIMIT ?
+`
+
+type GetWebhookDeliveriesParams struct {
+	WebhookID int64
+	Limit     int64
+}
+
+func (q *Queries) GetWebhookDeliveries(ctx context.Context, arg GetWebhookDeliveriesParams) ([]WebhookDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhookDeliveries, arg.WebhookID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []WebhookDelivery
+	for rows.Next() {
+		var i WebhookDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.WebhookID,
+			&i.EventType,
+			&i.Payload,
+			&i.Status,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWebhookDelivery = `-- name: GetWebhookDelivery :one
+SELECT id, webhook_id, event_type, payload, status, next_attempt_at, created_at
+FROM webhook_deliveries
+WHERE id = ?
+`
+
+func (q *Queries) GetWebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error) {
+	row := q.db.QueryRowContext(ctx, getWebhookDelivery, id)
+	var i WebhookDelivery
+	err := row.Scan(
+		&i.ID,
+		&i.WebhookID,
+		&i.EventType,
+		&i.Payload,
+		&i.Status,
+		&i.NextAttemptAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getWebhooks = `-- name: GetWebhooks :many
+SELECT id, url, events, secret, created_at, updated_at
+FROM webhooks
+ORDER BY id
+`
+
+func (q *Queries) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := q.db.QueryContext(ctx, getWebhooks)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Webhook
+	for rows.Next() {
+		var i Webhook
+		if err := rows.Scan(
+			&i.ID,
+			&i.Url,
+			&i.Events,
+			&i.Secret,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getWeightedRandomFact = `-- name: GetWeightedRandomFact :one
+SELECT id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+FROM facts
+WHERE deleted_at IS NULL AND weight > 0
+	AND id NOT IN (SELECT value FROM json_each(?))
+	AND id NOT IN (SELECT fact_id FROM random_sessions WHERE token = ?)
+ORDER BY weighted_rank(id, ?, CASE ?
+	WHEN 'favor-recent' THEN weight / (1 + MAX(julianday(?) - julianday(created_at), 0) / 30.0)
+	WHEN 'favor-unseen' THEN weight / (1 + served_count)
+	ELSE weight
+END) DESC
+LIMIT 1
+`
+
+type GetWeightedRandomFactParams struct {
+	Exclude  interface{}
+	Token    string
+	Salt     interface{}
+	Strategy interface{}
+	Now      interface{}
+}
+
+func (q *Queries) GetWeightedRandomFact(ctx context.Context, arg GetWeightedRandomFactParams) (Fact, error) {
+	row := q.db.QueryRowContext(ctx, getWeightedRandomFact,
+		arg.Exclude,
+		arg.Token,
+		arg.Salt,
+		arg.Strategy,
+		arg.Now,
+	)
+	var i Fact
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Content,
+		&i.Source,
+		&i.Weight,
+		&i.ServedCount,
+		&i.LastServedAt,
+		&i.Version,
+	)
+	return i, err
+}
+
+const importFact = `-- name: ImportFact :execrows
+INSERT INTO facts (id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version)
+VALUES (?, datetime(?), datetime(?), datetime(?), ?, ?, ?, ?, datetime(?), ?)
+ON CONFLICT (id) DO NOTHING
+`
+
+type ImportFactParams struct {
+	ID           int64
+	CreatedAt    interface{}
+	UpdatedAt    interface{}
+	DeletedAt    interface{}
+	Content      string
+	Source       sql.NullString
+	Weight       float64
+	ServedCount  int64
+	LastServedAt interface{}
+	Version      int64
+}
+
+func (q *Queries) ImportFact(ctx context.Context, arg ImportFactParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, importFact,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.DeletedAt,
+		arg.Content,
+		arg.Source,
+		arg.Weight,
+		arg.ServedCount,
+		arg.LastServedAt,
+		arg.Version,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const isFactSeen = `-- name: IsFactSeen :one
+SELECT EXISTS (SELECT 1 FROM random_sessions WHERE token = ? AND fact_id = ?)
+`
+
+type IsFactSeenParams struct {
+	Token  string
+	FactID int64
+}
+
+func (q *Queries) IsFactSeen(ctx context.Context, arg IsFactSeenParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, isFactSeen, arg.Token, arg.FactID)
+	var column_1 int64
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const markFactServed = `-- name: MarkFactServed :exec
+UPDATE facts
+SET served_count = served_count + 1, last_served_at = CURRENT_TIMESTAMP
+WHERE id = ?
+`
+
+func (q *Queries) MarkFactServed(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, markFactServed, id)
+	return err
+}
+
+const pinDailyFact = `-- name: PinDailyFact :exec
+INSERT INTO daily_facts (day, fact_id, pinned) VALUES (?, ?, TRUE)
+ON CONFLICT (day) DO UPDATE SET fact_id = excluded.fact_id, pinned = TRUE
+`
+
+type PinDailyFactParams struct {
+	Day    string
+	FactID int64
+}
+
+func (q *Queries) PinDailyFact(ctx context.Context, arg PinDailyFactParams) error {
+	_, err := q.db.ExecContext(ctx, pinDailyFact, arg.Day, arg.FactID)
+	return err
+}
+
+const restoreFact = `-- name: RestoreFact :one
+UPDATE facts
+SET deleted_at = NULL,
+	updated_at = CURRENT_TIMESTAMP,
+	version = version + 1
+WHERE id = ? AND deleted_at IS NOT NULL
+RETURNING id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+`
+
+func (q *Queries) RestoreFact(ctx context.Context, id int64) (Fact, error) {
+	row := q.db.QueryRowContext(ctx, restoreFact, id)
+	var i Fact
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Content,
+		&i.Source,
+		&i.Weight,
+		&i.ServedCount,
+		&i.LastServedAt,
+		&i.Version,
+	)
+	return i, err
+}
+
+const softDeleteFact = `-- name: SoftDeleteFact :execrows
+UPDATE facts
+SET deleted_at = DATETIME('now'),
+	updated_at = CURRENT_TIMESTAMP,
+	version = version + 1
+WHERE id = ? AND deleted_at IS NULL AND version = COALESCE(?, version)
+`
+
+type SoftDeleteFactParams struct {
+	ID      int64
+	Version interface{}
+}
+
+func (q *Queries) SoftDeleteFact(ctx context.Context, arg SoftDeleteFactParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteFact, arg.ID, arg.Version)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateFact = `-- name: UpdateFact :one
+UPDATE facts
+SET content = COALESCE(?, content),
+	source = COALESCE(?, source),
+	weight = COALESCE(?, weight),
+	updated_at = CURRENT_TIMESTAMP,
+	version = version + 1
+WHERE id = ? AND deleted_at IS NULL AND version = COALESCE(?, version)
+RETURNING id, created_at, updated_at, deleted_at, content, source, weight, served_count, last_served_at, version
+`
+
+type UpdateFactParams struct {
+	Content interface{}
+	Source  interface{}
+	Weight  interface{}
+	ID      int64
+	Version interface{}
+}
+
+func (q *Queries) UpdateFact(ctx context.Context, arg UpdateFactParams) (Fact, error) {
+	row := q.db.QueryRowContext(ctx, updateFact,
+		arg.Content,
+		arg.Source,
+		arg.Weight,
+		arg.ID,
+		arg.Version,
+	)
+	var i Fact
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.DeletedAt,
+		&i.Content,
+		&i.Source,
+		&i.Weight,
+		&i.ServedCount,
+		&i.LastServedAt,
+		&i.Version,
+	)
+	return i, err
+}
+
+const updateWebhook = `-- name: UpdateWebhook :one
+UPDATE webhooks
+SET url = COALESCE(?, url),
+	events = COALESCE(?, events),
+	secret = COALESCE(?, secret),
+	updated_at = CURRENT_TIMESTAMP
+WHERE id = ?
+RETURNING id, url, events, secret, created_at, updated_at
+`
+
+type UpdateWebhookParams struct {
+	Url    interface{}
+	Events interface{}
+	Secret interface{}
+	ID     int64
+}
+
+func (q *Queries) UpdateWebhook(ctx context.Context, arg UpdateWebhookParams) (Webhook, error) {
+	row := q.db.QueryRowContext(ctx, updateWebhook,
+		arg.Url,
+		arg.Events,
+		arg.Secret,
+		arg.ID,
+	)
+	var i Webhook
+	err := row.Scan(
+		&i.ID,
+		&i.Url,
+		&i.Events,
+		&i.Secret,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateWebhookDelivery = `-- name: UpdateWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET status = ?, next_attempt_at = datetime(?)
+WHERE id = ?
+`
+
+type UpdateWebhookDeliveryParams struct {
+	Status        string
+	NextAttemptAt interface{}
+	ID            int64
+}
+
+func (q *Queries) UpdateWebhookDelivery(ctx context.Context, arg UpdateWebhookDeliveryParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateWebhookDelivery, arg.Status, arg.NextAttemptAt, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertSourceLink = `-- name: UpsertSourceLink :exec
+INSERT INTO source_links (url, status, status_code, final_url, error, checked_at)
+VALUES (?, ?, ?, ?, ?, datetime(?))
+ON CONFLICT (url) DO UPDATE SET
+	status = excluded.status,
+	status_code = excluded.status_code,
+	final_url = excluded.final_url,
+	error = excluded.error,
+	checked_at = excluded.checked_at
+`
+
+type UpsertSourceLinkParams struct {
+	Url        string
+	Status     string
+	StatusCode int64
+	FinalUrl   string
+	Error      string
+	CheckedAt  interface{}
+}
+
+func (q *Queries) UpsertSourceLink(ctx context.Context, arg UpsertSourceLinkParams) error {
+	_, err := q.db.ExecContext(ctx, upsertSourceLink,
+		arg.Url,
+		arg.Status,
+		arg.StatusCode,
+		arg.FinalUrl,
+		arg.Error,
+		arg.CheckedAt,
+	)
 	return err
 }
@@ -0,0 +1,57 @@
+package sqlite
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+
+	moderncsqlite "modernc.org/sqlite"
+)
+
+func init() {
+	moderncsqlite.MustRegisterDeterministicScalarFunction("weighted_rank", 3, weightedRank)
+}
+
+// weightedRank implements weighted_rank(id, salt, weight) for weighted
+// random sampling as described by Efraimidis and Spirakis: every row
+// draws u from (0, 1) and the row with the largest u^(1/weight) wins,
+// which picks each row with a probability proportional to its weight.
+//
+// u is derived from the row's ID and a per-query salt rather than
+// RANDOM() so that seeded picks are reproducible.
+func weightedRank(_ *moderncsqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	id, ok := args[0].(int64)
+	if !ok {
+		return nil, fmt.Errorf("weighted_rank: id must be an integer, got %T", args[0])
+	}
+
+	salt, ok := args[1].(int64)
+	if !ok {
+		return nil, fmt.Errorf("weighted_rank: salt must be an integer, got %T", args[1])
+	}
+
+	var weight float64
+	switch w := args[2].(type) {
+	case int64:
+		weight = float64(w)
+	case float64:
+		weight = w
+	default:
+		return nil, fmt.Errorf("weighted_rank: weight must be a number, got %T", args[2])
+	}
+
+	if weight <= 0 {
+		return float64(-1), nil
+	}
+
+	u := (float64(splitmix64(uint64(id)^uint64(salt))>>11) + 0.5) / (1 << 53)
+	return math.Pow(u, 1/weight), nil
+}
+
+// splitmix64 scrambles x into a well distributed 64-bit value.
+func splitmix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
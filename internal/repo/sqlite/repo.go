@@ -4,7 +4,11 @@ import (
 	"context"
 	"database/sql"
 	_ "embed"
+	"encoding/json"
 	"errors"
+	"math/rand"
+	"strings"
+	"time"
 
 	"github.com/connorkuehl/factoid/internal/service"
 )
@@ -26,16 +30,165 @@ func NewRepo(db *sql.DB) *Repo {
 	}
 }
 
-func (r *Repo) Facts(ctx context.Context) ([]service.Fact, error) {
+func (r *Repo) Facts(ctx context.Context, query service.FactsQuery) ([]service.Fact, error) {
 	db := New(r.db)
-	result, err := db.GetFacts(ctx)
+
+	// SQLite treats a negative limit as no limit at all.
+	limit := int64(query.Limit)
+	if limit <= 0 {
+		limit = -1
+	}
+
+	// Without a search, the pattern matches every fact.
+	pattern := "%" + likeEscaper.Replace(query.Search) + "%"
+
+	var result []Fact
+	var err error
+	if query.SourceStatus != "" {
+		result, err = db.GetFactsBySourceStatus(ctx, GetFactsBySourceStatusParams{
+			Status:  query.SourceStatus,
+			ID:      query.After,
+			Content: pattern,
+			Limit:   limit,
+		})
+	} else {
+		result, err = db.GetFacts(ctx, GetFactsParams{
+			ID:      query.After,
+			Content: pattern,
+			Limit:   limit,
+		})
+	}
+	if err != nil {
+		return nil, ErrToDomainErr(err)
+	}
+
+	return allWithCitations(ctx, db, result)
+}
+
+// RecentFacts returns the limit most recently created facts, newest
+// first.
+func (r *Repo) RecentFacts(ctx context.Context, limit int) ([]service.Fact, error) {
+	db := New(r.db)
+
+	result, err := db.GetRecentFacts(ctx, int64(limit))
+	if err != nil {
+		return nil, ErrToDomainErr(err)
+	}
+
+	return allWithCitations(ctx, db, result)
+}
+
+// FactsLastModified returns when a fact was last created, changed or
+// deleted, or the zero time if there are no facts.
+func (r *Repo) FactsLastModified(ctx context.Context) (time.Time, error) {
+	updated, err := New(r.db).GetFactsLastModified(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, ErrToDomainErr(err)
+	}
+	return updated.Time, nil
+}
+
+// maxWeightedDraws is how many times drawWeighted tries before giving up.
+const maxWeightedDraws = 16
+
+// drawWeighted picks a fact for a weighted strategy by rejection
+// sampling: a fact is drawn with odds proportional to its weight, then
+// kept with the odds the strategy scales its weight by, if it's
+// eligible at all. ok is false if nothing was kept after
+// maxWeightedDraws draws.
+func drawWeighted(ctx context.Context, db *Queries, opts service.RandomOptions, now time.Time, int63 func() int64) (f Fact, ok bool, err error) {
+	total, err := db.GetTotalWeight(ctx)
+	if err != nil || total <= 0 {
+		return Fact{}, false, err
+	}
+
+	excluded := make(map[int64]bool, len(opts.Exclude))
+	for _, id := range opts.Exclude {
+		excluded[id] = true
+	}
+
+	uniform := func() float64 {
+		return float64(int63()>>10) / (1 << 53)
+	}
+
+	for i := 0; i < maxWeightedDraws; i++ {
+		f, err := db.GetFactByWeight(ctx, uniform()*total)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return Fact{}, false, err
+		}
+
+		if excluded[f.ID] || uniform() >= strategyOdds(opts.Strategy, f, now) {
+			continue
+		}
+
+		if opts.Session != "" {
+			seen, err := db.IsFactSeen(ctx, IsFactSeenParams{Token: opts.Session, FactID: f.ID})
+			if err != nil {
+				return Fact{}, false, err
+			}
+			if seen != 0 {
+				continue
+			}
+		}
+
+		return f, true, nil
+	}
+
+	return Fact{}, false, nil
+}
+
+// strategyOdds returns what strategy scales f's weight by, between 0 and
+// 1, matching GetWeightedRandomFact.
+func strategyOdds(strategy string, f Fact, now time.Time) float64 {
+	switch strategy {
+	case service.StrategyFavorRecent:
+		age := now.Sub(f.CreatedAt.Time).Hours() / 24
+		if age < 0 {
+			age = 0
+		}
+		return 1 / (1 + age/30)
+	case service.StrategyFavorUnseen:
+		return 1 / (1 + float64(f.ServedCount))
+	default:
+		return 1
+	}
+}
+
+// allWithCitations converts facts to the domain, attaching their
+// citations.
+func allWithCitations(ctx context.Context, db *Queries, result []Fact) ([]service.Fact, error) {
+	ids := make([]int64, 0, len(result))
+	for _, f := range result {
+		ids = append(ids, f.ID)
+	}
+	idsJSON, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	citations, err := db.GetCitationsForFacts(ctx, string(idsJSON))
 	if err != nil {
 		return nil, ErrToDomainErr(err)
 	}
 
+	byFact := make(map[int64][]service.Citation)
+	for _, c := range citations {
+		byFact[c.FactID] = append(byFact[c.FactID], CitationToDomain(c))
+	}
+
 	facts := make([]service.Fact, 0, len(result))
 	for _, f := range result {
-		facts = append(facts, ModelToDomain(f))
+		fact := ModelToDomain(f)
+		if c, ok := byFact[f.ID]; ok {
+			fact.Citations = c
+		}
+		facts = append(facts, fact)
 	}
 
 	return facts, nil
@@ -44,27 +197,374 @@ func (r *Repo) Facts(ctx context.Context) ([]service.Fact, error) {
 func (r *Repo) Fact(ctx context.Context, id int64) (service.Fact, error) {
 	db := New(r.db)
 	result, err := db.GetFact(ctx, id)
-	return ModelToDomain(result), ErrToDomainErr(err)
+	if err != nil {
+		return service.Fact{}, ErrToDomainErr(err)
+	}
+	return withCitations(ctx, db, result)
 }
 
-func (r *Repo) RandomFact(ctx context.Context) (service.Fact, error) {
+// RandomFact returns a random fact chosen according to opts.Strategy.
+//
+// Uniform picks choose a random ID between the smallest and largest IDs
+// and return the first eligible fact at or after it, wrapping around to
+// the start if there are none. This only ever walks the primary key
+// index rather than the whole table, at the cost of favoring facts that
+// follow gaps left by deleted or excluded ones.
+//
+// Weighted picks draw a point along the running total of every live
+// fact's weight and look up the fact it falls on by its weight_end, which
+// is indexed. Facts that are excluded or already seen are drawn again,
+// as are facts turned down with the odds the strategy scales their weight
+// by, so each pick is a handful of index lookups. If too many draws are
+// turned down, the pick falls back to ranking every candidate; see
+// weightedRank. Either way, facts are chosen with the same odds.
+func (r *Repo) RandomFact(ctx context.Context, opts service.RandomOptions) (service.Fact, error) {
 	db := New(r.db)
-	result, err := db.GetRandomFact(ctx)
-	return ModelToDomain(result), ErrToDomainErr(err)
+
+	int63 := rand.Int63
+	int63n := rand.Int63n
+	if opts.Rand != nil {
+		int63 = opts.Rand.Int63
+		int63n = opts.Rand.Int63n
+	}
+
+	exclude := opts.Exclude
+	if exclude == nil {
+		exclude = []int64{}
+	}
+	excludeJSON, err := json.Marshal(exclude)
+	if err != nil {
+		return service.Fact{}, err
+	}
+
+	if opts.Strategy != "" && opts.Strategy != service.StrategyUniform {
+		now := opts.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+
+		result, ok, err := drawWeighted(ctx, db, opts, now, int63)
+		if err != nil {
+			return service.Fact{}, ErrToDomainErr(err)
+		}
+
+		if !ok {
+			result, err = db.GetWeightedRandomFact(ctx, GetWeightedRandomFactParams{
+				Exclude:  string(excludeJSON),
+				Token:    opts.Session,
+				Salt:     int63(),
+				Strategy: opts.Strategy,
+				Now:      sqliteTime(now),
+			})
+			if err != nil {
+				return service.Fact{}, ErrToDomainErr(err)
+			}
+		}
+		return withCitations(ctx, db, result)
+	}
+
+	bounds, err := db.GetFactIDBounds(ctx)
+	if err != nil {
+		return service.Fact{}, ErrToDomainErr(err)
+	}
+
+	if bounds.MaxID == 0 {
+		return service.Fact{}, service.ErrNotFound
+	}
+
+	params := GetRandomFactParams{
+		ID:      bounds.MinID + int63n(bounds.MaxID-bounds.MinID+1),
+		Exclude: string(excludeJSON),
+		Token:   opts.Session,
+	}
+
+	result, err := db.GetRandomFact(ctx, params)
+	if errors.Is(err, sql.ErrNoRows) {
+		params.ID = bounds.MinID
+		result, err = db.GetRandomFact(ctx, params)
+	}
+	if err != nil {
+		return service.Fact{}, ErrToDomainErr(err)
+	}
+
+	return withCitations(ctx, db, result)
+}
+
+// MarkServed counts that fact id has been handed out at random.
+func (r *Repo) MarkServed(ctx context.Context, id int64) error {
+	db := New(r.db)
+	err := db.MarkFactServed(ctx, id)
+	return ErrToDomainErr(err)
 }
 
-func (r *Repo) CreateFact(ctx context.Context, content, source string) (service.Fact, error) {
+// MarkSeen records that the session has been shown fact id.
+func (r *Repo) MarkSeen(ctx context.Context, session string, id int64) error {
 	db := New(r.db)
+	err := db.CreateRandomSessionFact(ctx, CreateRandomSessionFactParams{
+		Token:  session,
+		FactID: id,
+	})
+	return ErrToDomainErr(err)
+}
+
+// ResetSession forgets every fact the session has been shown.
+func (r *Repo) ResetSession(ctx context.Context, session string) error {
+	db := New(r.db)
+	err := db.DeleteRandomSession(ctx, session)
+	return ErrToDomainErr(err)
+}
+
+// PruneSessions forgets sessions that haven't been used since idleSince.
+func (r *Repo) PruneSessions(ctx context.Context, idleSince time.Time) error {
+	db := New(r.db)
+	err := db.DeleteIdleRandomSessions(ctx, sqliteTime(idleSince))
+	return ErrToDomainErr(err)
+}
+
+func (r *Repo) CreateFact(ctx context.Context, f service.Fact) (service.Fact, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return service.Fact{}, err
+	}
+	defer tx.Rollback()
+
+	created, err := insertFact(ctx, New(tx), f)
+	if err != nil {
+		return service.Fact{}, err
+	}
+
+	return created, tx.Commit()
+}
+
+// CreateFacts creates every fact in facts, or none of them if any of
+// them can't be created.
+func (r *Repo) CreateFacts(ctx context.Context, facts []service.Fact) ([]service.Fact, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	db := New(tx)
+	created := make([]service.Fact, 0, len(facts))
+	for _, f := range facts {
+		c, err := insertFact(ctx, db, f)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, c)
+	}
+
+	return created, tx.Commit()
+}
+
+func insertFact(ctx context.Context, db *Queries, f service.Fact) (service.Fact, error) {
 	result, err := db.CreateFact(ctx, CreateFactParams{
-		Content: content,
-		Source:  sql.NullString{String: source, Valid: true},
+		Content: f.Content,
+		Source:  sql.NullString{String: f.Source, Valid: true},
+		Weight:  f.Weight,
 	})
-	return ModelToDomain(result), ErrToDomainErr(err)
+	if err != nil {
+		return service.Fact{}, ErrToDomainErr(err)
+	}
+
+	if err := createCitations(ctx, db, result.ID, f.Citations); err != nil {
+		return service.Fact{}, err
+	}
+
+	return withCitations(ctx, db, result)
+}
+
+// UpdateFact changes the fields of fact id that are set in u.
+func (r *Repo) UpdateFact(ctx context.Context, id int64, u service.FactUpdate) (service.Fact, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return service.Fact{}, err
+	}
+	defer tx.Rollback()
+
+	params := UpdateFactParams{ID: id}
+	if u.Version != 0 {
+		params.Version = u.Version
+	}
+	if u.Content != nil {
+		params.Content = *u.Content
+	}
+	if u.Source != nil {
+		params.Source = *u.Source
+	}
+	if u.Weight != nil {
+		params.Weight = *u.Weight
+	}
+
+	db := New(tx)
+	result, err := db.UpdateFact(ctx, params)
+	if errors.Is(err, sql.ErrNoRows) && u.Version != 0 {
+		return service.Fact{}, versionMismatch(ctx, db, id)
+	}
+	if err != nil {
+		return service.Fact{}, ErrToDomainErr(err)
+	}
+
+	if u.Citations != nil {
+		if err := db.DeleteCitations(ctx, id); err != nil {
+			return service.Fact{}, ErrToDomainErr(err)
+		}
+
+		if err := createCitations(ctx, db, id, *u.Citations); err != nil {
+			return service.Fact{}, err
+		}
+	}
+
+	updated, err := withCitations(ctx, db, result)
+	if err != nil {
+		return service.Fact{}, err
+	}
+
+	return updated, tx.Commit()
 }
 
-func (r *Repo) DeleteFact(ctx context.Context, id int64) error {
+func createCitations(ctx context.Context, db *Queries, factID int64, citations []service.Citation) error {
+	for i, c := range citations {
+		err := db.CreateCitation(ctx, CreateCitationParams{
+			FactID:     factID,
+			Position:   int64(i),
+			Url:        c.URL,
+			Title:      c.Title,
+			Author:     c.Author,
+			Publisher:  c.Publisher,
+			Accessed:   c.Accessed,
+			ArchiveUrl: c.ArchiveURL,
+		})
+		if err != nil {
+			return ErrToDomainErr(err)
+		}
+	}
+	return nil
+}
+
+// DeleteFact soft deletes fact id. If version isn't zero, the fact is
+// only deleted if it is still at that version.
+func (r *Repo) DeleteFact(ctx context.Context, id, version int64) error {
+	params := SoftDeleteFactParams{ID: id}
+	if version != 0 {
+		params.Version = version
+	}
+
 	db := New(r.db)
-	err := db.SoftDeleteFact(ctx, id)
+	n, err := db.SoftDeleteFact(ctx, params)
+	if err != nil {
+		return ErrToDomainErr(err)
+	}
+
+	if n == 0 {
+		if version != 0 {
+			return versionMismatch(ctx, db, id)
+		}
+		return service.ErrNotFound
+	}
+
+	return nil
+}
+
+// RestoreFact undoes the soft deletion of fact id.
+func (r *Repo) RestoreFact(ctx context.Context, id int64) (service.Fact, error) {
+	db := New(r.db)
+	result, err := db.RestoreFact(ctx, id)
+	if err != nil {
+		return service.Fact{}, ErrToDomainErr(err)
+	}
+	return withCitations(ctx, db, result)
+}
+
+// DeletedFacts returns the limit most recently soft-deleted facts, most
+// recently deleted first.
+func (r *Repo) DeletedFacts(ctx context.Context, limit int) ([]service.Fact, error) {
+	db := New(r.db)
+
+	result, err := db.GetDeletedFacts(ctx, int64(limit))
+	if err != nil {
+		return nil, ErrToDomainErr(err)
+	}
+
+	return allWithCitations(ctx, db, result)
+}
+
+// versionMismatch explains why a conditional write to fact id changed
+// nothing: either the fact is gone or it's at another version.
+func versionMismatch(ctx context.Context, db *Queries, id int64) error {
+	_, err := db.GetFact(ctx, id)
+	if err != nil {
+		return ErrToDomainErr(err)
+	}
+	return service.ErrVersionMismatch
+}
+
+func (r *Repo) FactIDsCreatedBefore(ctx context.Context, t time.Time) ([]int64, error) {
+	db := New(r.db)
+	ids, err := db.GetFactIDsCreatedBefore(ctx, sqliteTime(t))
+	return ids, ErrToDomainErr(err)
+}
+
+func (r *Repo) DailyFact(ctx context.Context, day string) (service.DailyFact, error) {
+	db := New(r.db)
+	result, err := db.GetDailyFact(ctx, day)
+	return service.DailyFact{
+		Day:    result.Day,
+		FactID: result.FactID,
+		Pinned: result.Pinned,
+	}, ErrToDomainErr(err)
+}
+
+// DailyFactIDs returns the IDs of the facts chosen for the days in
+// [from, to).
+func (r *Repo) DailyFactIDs(ctx context.Context, from, to string) ([]int64, error) {
+	db := New(r.db)
+	ids, err := db.GetDailyFactIDsBetween(ctx, GetDailyFactIDsBetweenParams{
+		Day:   from,
+		Day_2: to,
+	})
+	return ids, ErrToDomainErr(err)
+}
+
+// SaveDailyFact records id as the fact for day unless one has already
+// been chosen.
+func (r *Repo) SaveDailyFact(ctx context.Context, day string, id int64) error {
+	db := New(r.db)
+	err := db.CreateDailyFact(ctx, CreateDailyFactParams{Day: day, FactID: id})
+	return ErrToDomainErr(err)
+}
+
+func (r *Repo) PinDailyFact(ctx context.Context, day string, id int64) error {
+	db := New(r.db)
+	err := db.PinDailyFact(ctx, PinDailyFactParams{Day: day, FactID: id})
+	return ErrToDomainErr(err)
+}
+
+func (r *Repo) DeleteDailyFact(ctx context.Context, day string) error {
+	db := New(r.db)
+	err := db.DeleteDailyFact(ctx, day)
+	return ErrToDomainErr(err)
+}
+
+// StaleSourceLinks returns the distinct citation URLs that have not been
+// checked since checkedBefore.
+func (r *Repo) StaleSourceLinks(ctx context.Context, checkedBefore time.Time) ([]string, error) {
+	db := New(r.db)
+	urls, err := db.GetStaleSourceLinks(ctx, sqliteTime(checkedBefore))
+	return urls, ErrToDomainErr(err)
+}
+
+func (r *Repo) RecordSourceLink(ctx context.Context, link service.SourceLink) error {
+	db := New(r.db)
+	err := db.UpsertSourceLink(ctx, UpsertSourceLinkParams{
+		Url:        link.URL,
+		Status:     link.Status,
+		StatusCode: int64(link.StatusCode),
+		FinalUrl:   link.FinalURL,
+		Error:      link.Error,
+		CheckedAt:  sqliteTime(link.CheckedAt),
+	})
 	return ErrToDomainErr(err)
 }
 
@@ -76,7 +576,36 @@ func ModelToDomain(f Fact) service.Fact {
 		DeletedAt: f.DeletedAt.Time,
 		Content:   f.Content,
 		Source:    f.Source.String,
+		Citations: []service.Citation{},
+		Weight:    f.Weight,
+		Version:   f.Version,
+	}
+}
+
+func CitationToDomain(c Citation) service.Citation {
+	return service.Citation{
+		URL:        c.Url,
+		Title:      c.Title,
+		Author:     c.Author,
+		Publisher:  c.Publisher,
+		Accessed:   c.Accessed,
+		ArchiveURL: c.ArchiveUrl,
+	}
+}
+
+func withCitations(ctx context.Context, db *Queries, f Fact) (service.Fact, error) {
+	fact := ModelToDomain(f)
+
+	citations, err := db.GetCitations(ctx, f.ID)
+	if err != nil {
+		return service.Fact{}, ErrToDomainErr(err)
 	}
+
+	for _, c := range citations {
+		fact.Citations = append(fact.Citations, CitationToDomain(c))
+	}
+
+	return fact, nil
 }
 
 func ErrToDomainErr(err error) error {
@@ -86,3 +615,13 @@ func ErrToDomainErr(err error) error {
 	}
 	return err
 }
+
+// likeEscaper escapes the wildcards of a LIKE pattern, using the escape
+// character the queries declare.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// sqliteTime formats t the same way SQLite's CURRENT_TIMESTAMP does, so
+// timestamps written from Go compare correctly with the column defaults.
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02 15:04:05")
+}
@@ -1,8 +1,126 @@
-CREATE TABLE facts (
+CREATE TABLE IF NOT EXISTS facts (
 	id INTEGER PRIMARY KEY AUTOINCREMENT,
 	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 	deleted_at TIMESTAMP DEFAULT NULL,
 	content TEXT NOT NULL,
-	source TEXT
+	source TEXT,
+	weight REAL NOT NULL DEFAULT 1,
+	served_count INTEGER NOT NULL DEFAULT 0,
+	last_served_at TIMESTAMP DEFAULT NULL,
+	version INTEGER NOT NULL DEFAULT 1,
+	-- weight_end is the total weight of the live facts up to and
+	-- including this one, so weighted picks can look facts up by it.
+	weight_end REAL NOT NULL DEFAULT 0
 );
+
+CREATE INDEX IF NOT EXISTS facts_updated_at ON facts (updated_at);
+CREATE INDEX IF NOT EXISTS facts_weight_end ON facts (weight_end, id);
+
+CREATE TRIGGER IF NOT EXISTS facts_weight_end_insert AFTER INSERT ON facts
+BEGIN
+	UPDATE facts
+	SET weight_end = weight_end + CASE WHEN NEW.deleted_at IS NULL THEN MAX(NEW.weight, 0) ELSE 0 END
+	WHERE id > NEW.id;
+
+	UPDATE facts
+	SET weight_end = CASE WHEN NEW.deleted_at IS NULL THEN MAX(NEW.weight, 0) ELSE 0 END
+		+ COALESCE((SELECT weight_end FROM facts WHERE id < NEW.id ORDER BY id DESC LIMIT 1), 0)
+	WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS facts_weight_end_update AFTER UPDATE OF weight, deleted_at ON facts
+WHEN CASE WHEN NEW.deleted_at IS NULL THEN MAX(NEW.weight, 0) ELSE 0 END
+	!= CASE WHEN OLD.deleted_at IS NULL THEN MAX(OLD.weight, 0) ELSE 0 END
+BEGIN
+	UPDATE facts
+	SET weight_end = weight_end
+		+ CASE WHEN NEW.deleted_at IS NULL THEN MAX(NEW.weight, 0) ELSE 0 END
+		- CASE WHEN OLD.deleted_at IS NULL THEN MAX(OLD.weight, 0) ELSE 0 END
+	WHERE id >= NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS facts_weight_end_delete AFTER DELETE ON facts
+BEGIN
+	UPDATE facts
+	SET weight_end = weight_end - CASE WHEN OLD.deleted_at IS NULL THEN MAX(OLD.weight, 0) ELSE 0 END
+	WHERE id > OLD.id;
+END;
+
+CREATE TABLE IF NOT EXISTS citations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	fact_id INTEGER NOT NULL REFERENCES facts (id),
+	position INTEGER NOT NULL,
+	url TEXT NOT NULL,
+	title TEXT NOT NULL DEFAULT '',
+	author TEXT NOT NULL DEFAULT '',
+	publisher TEXT NOT NULL DEFAULT '',
+	accessed TEXT NOT NULL DEFAULT '',
+	archive_url TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS citations_fact_id ON citations (fact_id, position);
+
+CREATE TABLE IF NOT EXISTS source_links (
+	url TEXT PRIMARY KEY,
+	status TEXT NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	final_url TEXT NOT NULL DEFAULT '',
+	error TEXT NOT NULL DEFAULT '',
+	checked_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS daily_facts (
+	day TEXT PRIMARY KEY,
+	fact_id INTEGER NOT NULL REFERENCES facts (id),
+	pinned BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS random_sessions (
+	token TEXT NOT NULL,
+	fact_id INTEGER NOT NULL REFERENCES facts (id),
+	seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (token, fact_id)
+);
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key TEXT PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	status INTEGER NOT NULL DEFAULT 0,
+	header TEXT NOT NULL DEFAULT '{}',
+	body BLOB NOT NULL DEFAULT x'',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhooks (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	events TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	webhook_id INTEGER NOT NULL REFERENCES webhooks (id),
+	event_type TEXT NOT NULL,
+	payload BLOB NOT NULL,
+	status TEXT NOT NULL DEFAULT 'pending',
+	next_attempt_at TIMESTAMP DEFAULT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, id);
+
+CREATE TABLE IF NOT EXISTS webhook_attempts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	delivery_id INTEGER NOT NULL REFERENCES webhook_deliveries (id),
+	attempted_at TIMESTAMP NOT NULL,
+	status_code INTEGER NOT NULL DEFAULT 0,
+	error TEXT NOT NULL DEFAULT '',
+	duration_ms INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS webhook_attempts_delivery_id ON webhook_attempts (delivery_id, id);
@@ -0,0 +1,318 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/connorkuehl/factoid/internal/service"
+)
+
+func (r *Repo) CreateWebhook(ctx context.Context, w service.Webhook) (service.Webhook, error) {
+	events, err := json.Marshal(w.Events)
+	if err != nil {
+		return service.Webhook{}, err
+	}
+
+	db := New(r.db)
+	result, err := db.CreateWebhook(ctx, CreateWebhookParams{
+		Url:    w.URL,
+		Events: string(events),
+		Secret: w.Secret,
+	})
+	if err != nil {
+		return service.Webhook{}, ErrToDomainErr(err)
+	}
+	return WebhookToDomain(result)
+}
+
+func (r *Repo) Webhooks(ctx context.Context) ([]service.Webhook, error) {
+	db := New(r.db)
+	result, err := db.GetWebhooks(ctx)
+	if err != nil {
+		return nil, ErrToDomainErr(err)
+	}
+
+	hooks := make([]service.Webhook, 0, len(result))
+	for _, w := range result {
+		hook, err := WebhookToDomain(w)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+func (r *Repo) Webhook(ctx context.Context, id int64) (service.Webhook, error) {
+	db := New(r.db)
+	result, err := db.GetWebhook(ctx, id)
+	if err != nil {
+		return service.Webhook{}, ErrToDomainErr(err)
+	}
+	return WebhookToDomain(result)
+}
+
+// UpdateWebhook changes the fields of webhook id that are set in u.
+func (r *Repo) UpdateWebhook(ctx context.Context, id int64, u service.WebhookUpdate) (service.Webhook, error) {
+	params := UpdateWebhookParams{ID: id}
+	if u.URL != nil {
+		params.Url = *u.URL
+	}
+	if u.Events != nil {
+		events, err := json.Marshal(*u.Events)
+		if err != nil {
+			return service.Webhook{}, err
+		}
+		params.Events = string(events)
+	}
+	if u.Secret != nil {
+		params.Secret = *u.Secret
+	}
+
+	db := New(r.db)
+	result, err := db.UpdateWebhook(ctx, params)
+	if err != nil {
+		return service.Webhook{}, ErrToDomainErr(err)
+	}
+	return WebhookToDomain(result)
+}
+
+// DeleteWebhook deletes webhook id and everything queued for it.
+// Foreign keys aren't enforced, so nothing cascades on its own.
+func (r *Repo) DeleteWebhook(ctx context.Context, id int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	db := New(tx)
+	if err := db.DeleteWebhookAttempts(ctx, id); err != nil {
+		return ErrToDomainErr(err)
+	}
+	if err := db.DeleteWebhookDeliveries(ctx, id); err != nil {
+		return ErrToDomainErr(err)
+	}
+
+	n, err := db.DeleteWebhook(ctx, id)
+	if err != nil {
+		return ErrToDomainErr(err)
+	}
+	if n == 0 {
+		return service.ErrNotFound
+	}
+
+	return tx.Commit()
+}
+
+// EnqueueWebhookDeliveries queues payload for every webhook subscribed
+// to eventType.
+func (r *Repo) EnqueueWebhookDeliveries(ctx context.Context, eventType string, payload []byte, at time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	db := New(tx)
+	hooks, err := db.GetWebhooks(ctx)
+	if err != nil {
+		return ErrToDomainErr(err)
+	}
+
+	for _, w := range hooks {
+		hook, err := WebhookToDomain(w)
+		if err != nil {
+			return err
+		}
+		if !subscribes(hook, eventType) {
+			continue
+		}
+
+		err = db.CreateWebhookDelivery(ctx, CreateWebhookDeliveryParams{
+			WebhookID:     hook.ID,
+			EventType:     eventType,
+			Payload:       payload,
+			NextAttemptAt: sqliteTime(at),
+		})
+		if err != nil {
+			return ErrToDomainErr(err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func subscribes(hook service.Webhook, eventType string) bool {
+	for _, e := range hook.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Repo) WebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]service.WebhookDelivery, error) {
+	db := New(r.db)
+	result, err := db.GetWebhookDeliveries(ctx, GetWebhookDeliveriesParams{
+		WebhookID: webhookID,
+		Limit:     int64(limit),
+	})
+	if err != nil {
+		return nil, ErrToDomainErr(err)
+	}
+	return withAttempts(ctx, db, result)
+}
+
+func (r *Repo) WebhookDelivery(ctx context.Context, id int64) (service.WebhookDelivery, error) {
+	db := New(r.db)
+	result, err := db.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		return service.WebhookDelivery{}, ErrToDomainErr(err)
+	}
+
+	deliveries, err := withAttempts(ctx, db, []WebhookDelivery{result})
+	if err != nil {
+		return service.WebhookDelivery{}, err
+	}
+	return deliveries[0], nil
+}
+
+// DueWebhookDeliveries returns up to limit pending deliveries that are
+// due to be tried by now, the longest overdue first.
+func (r *Repo) DueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]service.WebhookDelivery, error) {
+	db := New(r.db)
+	result, err := db.GetDueWebhookDeliveries(ctx, GetDueWebhookDeliveriesParams{
+		NextAttemptAt: sqliteTime(now),
+		Limit:         int64(limit),
+	})
+	if err != nil {
+		return nil, ErrToDomainErr(err)
+	}
+	return withAttempts(ctx, db, result)
+}
+
+// RecordWebhookAttempt records an attempt at delivery id and moves it to
+// status. A pending delivery is tried again at next.
+func (r *Repo) RecordWebhookAttempt(ctx context.Context, id int64, a service.WebhookAttempt, status string, next time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	db := New(tx)
+	err = db.CreateWebhookAttempt(ctx, CreateWebhookAttemptParams{
+		DeliveryID:  id,
+		AttemptedAt: sqliteTime(a.At),
+		StatusCode:  int64(a.StatusCode),
+		Error:       a.Error,
+		DurationMs:  a.DurationMS,
+	})
+	if err != nil {
+		return ErrToDomainErr(err)
+	}
+
+	params := UpdateWebhookDeliveryParams{ID: id, Status: status}
+	if status == service.DeliveryPending {
+		params.NextAttemptAt = sqliteTime(next)
+	}
+
+	n, err := db.UpdateWebhookDelivery(ctx, params)
+	if err != nil {
+		return ErrToDomainErr(err)
+	}
+	if n == 0 {
+		return service.ErrNotFound
+	}
+
+	return tx.Commit()
+}
+
+func (r *Repo) RedeliverWebhookDelivery(ctx context.Context, id int64, at time.Time) (service.WebhookDelivery, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return service.WebhookDelivery{}, err
+	}
+	defer tx.Rollback()
+
+	db := New(tx)
+	n, err := db.UpdateWebhookDelivery(ctx, UpdateWebhookDeliveryParams{
+		ID:            id,
+		Status:        service.DeliveryPending,
+		NextAttemptAt: sqliteTime(at),
+	})
+	if err != nil {
+		return service.WebhookDelivery{}, ErrToDomainErr(err)
+	}
+	if n == 0 {
+		return service.WebhookDelivery{}, service.ErrNotFound
+	}
+
+	result, err := db.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		return service.WebhookDelivery{}, ErrToDomainErr(err)
+	}
+
+	deliveries, err := withAttempts(ctx, db, []WebhookDelivery{result})
+	if err != nil {
+		return service.WebhookDelivery{}, err
+	}
+
+	return deliveries[0], tx.Commit()
+}
+
+func WebhookToDomain(w Webhook) (service.Webhook, error) {
+	hook := service.Webhook{
+		ID:        w.ID,
+		URL:       w.Url,
+		Secret:    w.Secret,
+		CreatedAt: w.CreatedAt.Time,
+		UpdatedAt: w.UpdatedAt.Time,
+	}
+	if err := json.Unmarshal([]byte(w.Events), &hook.Events); err != nil {
+		return service.Webhook{}, err
+	}
+	return hook, nil
+}
+
+func WebhookDeliveryToDomain(d WebhookDelivery) service.WebhookDelivery {
+	delivery := service.WebhookDelivery{
+		ID:        d.ID,
+		WebhookID: d.WebhookID,
+		EventType: d.EventType,
+		Payload:   d.Payload,
+		Status:    d.Status,
+		CreatedAt: d.CreatedAt.Time,
+		Attempts:  []service.WebhookAttempt{},
+	}
+	if d.NextAttemptAt.Valid {
+		next := d.NextAttemptAt.Time
+		delivery.NextAttemptAt = &next
+	}
+	return delivery
+}
+
+func withAttempts(ctx context.Context, db *Queries, result []WebhookDelivery) ([]service.WebhookDelivery, error) {
+	deliveries := make([]service.WebhookDelivery, 0, len(result))
+	for _, d := range result {
+		delivery := WebhookDeliveryToDomain(d)
+
+		attempts, err := db.GetWebhookAttempts(ctx, d.ID)
+		if err != nil {
+			return nil, ErrToDomainErr(err)
+		}
+		for _, a := range attempts {
+			delivery.Attempts = append(delivery.Attempts, service.WebhookAttempt{
+				At:         a.AttemptedAt,
+				StatusCode: int(a.StatusCode),
+				Error:      a.Error,
+				DurationMS: a.DurationMs,
+			})
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,628 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	log "golang.org/x/exp/slog"
+)
+
+const (
+	// adminCookie holds the token of an admin's session.
+	adminCookie = "factoid_admin"
+
+	// adminSessionTTL is how long an admin stays signed in.
+	adminSessionTTL = 12 * time.Hour
+
+	// adminPageSize is how many facts the admin pages list at once.
+	adminPageSize = 50
+
+	// maxAdminForm is the largest form the admin pages accept, which
+	// leaves room for imports.
+	maxAdminForm = 32 << 20
+
+	// maxActivity is the most events the activity page shows.
+	maxActivity = 100
+)
+
+var adminPages = func() map[string]*template.Template {
+	pages := make(map[string]*template.Template)
+	for _, name := range []string{"login", "facts", "edit", "deleted", "import", "activity", "message"} {
+		pages[name] = template.Must(template.ParseFS(webAssets,
+			"web/templates/admin/layout.html",
+			"web/templates/admin/"+name+".html",
+		))
+	}
+	return pages
+}()
+
+// adminNotices are shown after an admin was sent back to a page, keyed
+// by its done query parameter.
+var adminNotices = map[string]string{
+	"created":  "Fact created.",
+	"saved":    "Changes saved.",
+	"deleted":  "Fact deleted. It can be restored below.",
+	"restored": "Fact restored.",
+}
+
+// adminSession is an admin who signed in.
+type adminSession struct {
+	// csrf must be sent with every form, so other sites can't submit
+	// forms on the admin's behalf.
+	csrf    string
+	expires time.Time
+}
+
+// adminData is what the templates in web/templates/admin show.
+type adminData struct {
+	Title string
+
+	// CSRF is sent back with forms. It's empty on pages for admins who
+	// haven't signed in.
+	CSRF string
+
+	// Notice says what just happened, and Error what went wrong.
+	Notice string
+	Error  string
+
+	// Query is what was searched for.
+	Query string
+
+	// Facts are the facts listed on the page, and First and Next link to
+	// the first and following pages of them.
+	Facts []Fact
+	First string
+	Next  string
+
+	// Fact is the fact being edited. Its ID is zero for new facts.
+	Fact Fact
+	Form factForm
+
+	// Events are recent changes to facts, newest first.
+	Events []Event
+
+	// Import is what was pasted to import, and Rejected the facts that
+	// kept it from being imported.
+	Import   string
+	Rejected []ImportRejection
+
+	// Return is where to go after signing in.
+	Return string
+}
+
+// factForm is a fact as it's edited in a form.
+type factForm struct {
+	Content string
+	Source  string
+
+	// Citations has a line for each citation: a URL, optionally followed
+	// by a title.
+	Citations string
+
+	Weight  string
+	Version int64
+}
+
+func newFactForm(f Fact) factForm {
+	var citations strings.Builder
+	for _, c := range f.Citations {
+		citations.WriteString(c.URL)
+		if c.Title != "" {
+			citations.WriteString(" " + c.Title)
+		}
+		citations.WriteString("\n")
+	}
+
+	return factForm{
+		Content:   f.Content,
+		Source:    f.Source,
+		Citations: citations.String(),
+		Weight:    strconv.FormatFloat(f.Weight, 'f', -1, 64),
+		Version:   f.Version,
+	}
+}
+
+// fact validates the form and turns it into a fact. Citations of old
+// with the same URL as one in the form keep the details the form
+// doesn't show, such as their author.
+func (form factForm) fact(old []Citation) (Fact, error) {
+	in := factInput{
+		Content: strings.TrimSpace(form.Content),
+		Source:  strings.TrimSpace(form.Source),
+	}
+
+	if w := strings.TrimSpace(form.Weight); w != "" {
+		weight, err := strconv.ParseFloat(w, 64)
+		if err != nil {
+			return Fact{}, errors.New("weight must be a number")
+		}
+		in.Weight = &weight
+	}
+
+	for _, line := range strings.Split(form.Citations, "\n") {
+		u, title, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if u == "" {
+			continue
+		}
+
+		c := Citation{URL: u}
+		for _, o := range old {
+			if o.URL == u {
+				c = o
+				break
+			}
+		}
+		c.Title = strings.TrimSpace(title)
+
+		in.Citations = append(in.Citations, c)
+	}
+
+	return in.fact()
+}
+
+// adminSession returns the session of the admin making r, if they're
+// signed in.
+func (s *Service) adminSession(r *http.Request) (adminSession, bool) {
+	cookie, err := r.Cookie(adminCookie)
+	if err != nil {
+		return adminSession{}, false
+	}
+
+	s.adminMu.Lock()
+	defer s.adminMu.Unlock()
+
+	sess, ok := s.adminSessions[cookie.Value]
+	if !ok || !s.now().Before(sess.expires) {
+		return adminSession{}, false
+	}
+	return sess, true
+}
+
+// adminOnly lets only signed in admins through. Forms must also carry
+// the session's CSRF token.
+func (s *Service) adminOnly(next http.Handler) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, ok := s.adminSession(r)
+		if !ok && r.Method == http.MethodGet {
+			v := url.Values{"return": {r.URL.RequestURI()}}
+			http.Redirect(w, r, "/admin/login?"+v.Encode(), http.StatusSeeOther)
+			return
+		}
+		if !ok {
+			s.respondAdminMessage(w, r, http.StatusForbidden, "Signed out", "You're signed out, so nothing was changed. Sign in and try again.")
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			r.Body = http.MaxBytesReader(w, r.Body, maxAdminForm)
+			if subtle.ConstantTimeCompare([]byte(r.PostFormValue("csrf")), []byte(sess.csrf)) != 1 {
+				s.respondAdminMessage(w, r, http.StatusForbidden, "Form expired", "The form was too old or came from another site, so nothing was changed. Reload the page and try again.")
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminLoginHandler signs admins in with the secret given to
+// WithAuthorizer.
+func (s *Service) AdminLoginHandler(w http.ResponseWriter, r *http.Request) {
+	ret := r.FormValue("return")
+	if !strings.HasPrefix(ret, "/admin") {
+		ret = "/admin"
+	}
+
+	data := adminData{Title: "Sign in", Return: ret}
+
+	if r.Method == http.MethodGet {
+		if _, ok := s.adminSession(r); ok {
+			http.Redirect(w, r, ret, http.StatusSeeOther)
+			return
+		}
+		s.respondAdminPage(w, r, http.StatusOK, "login", data)
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(r.PostFormValue("secret")), []byte(s.auth)) != 1 {
+		data.Error = "That's not the secret."
+		s.respondAdminPage(w, r, http.StatusUnauthorized, "login", data)
+		return
+	}
+
+	token, err := newAdminToken()
+	if err != nil {
+		s.respondAdminError(w, r, err)
+		return
+	}
+	csrf, err := newAdminToken()
+	if err != nil {
+		s.respondAdminError(w, r, err)
+		return
+	}
+
+	now := s.now()
+
+	s.adminMu.Lock()
+	for t, sess := range s.adminSessions {
+		if !now.Before(sess.expires) {
+			delete(s.adminSessions, t)
+		}
+	}
+	s.adminSessions[token] = adminSession{csrf: csrf, expires: now.Add(adminSessionTTL)}
+	s.adminMu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminCookie,
+		Value:    token,
+		Path:     "/admin",
+		MaxAge:   int(adminSessionTTL / time.Second),
+		Secure:   r.TLS != nil || strings.HasPrefix(s.baseURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, ret, http.StatusSeeOther)
+}
+
+// AdminLogoutHandler signs the admin out.
+func (s *Service) AdminLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(adminCookie); err == nil {
+		s.adminMu.Lock()
+		delete(s.adminSessions, cookie.Value)
+		s.adminMu.Unlock()
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminCookie,
+		Path:     "/admin",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
+// AdminFactsHandler lists the facts that mention the q query parameter,
+// or every fact, a page at a time.
+func (s *Service) AdminFactsHandler(w http.ResponseWriter, r *http.Request) {
+	data := s.newAdminData(r, "Facts")
+	data.Query = strings.TrimSpace(r.URL.Query().Get("q"))
+
+	var err error
+	data.Facts, data.First, data.Next, err = s.factPage(r, data.Query, adminPageSize)
+	if errors.Is(err, errBadCursor) {
+		s.respondAdminMessage(w, r, http.StatusNotFound, "Not found", "There's no such page of facts.")
+		return
+	}
+	if err != nil {
+		s.respondAdminError(w, r, err)
+		return
+	}
+
+	s.respondAdminPage(w, r, http.StatusOK, "facts", data)
+}
+
+// AdminNewHandler shows a form for a new fact, and creates it when the
+// form is sent.
+func (s *Service) AdminNewHandler(w http.ResponseWriter, r *http.Request) {
+	data := s.newAdminData(r, "New fact")
+
+	if r.Method == http.MethodGet {
+		data.Form.Weight = "1"
+		s.respondAdminPage(w, r, http.StatusOK, "edit", data)
+		return
+	}
+
+	data.Form = postedFactForm(r)
+	fact, err := data.Form.fact(nil)
+	if err != nil {
+		data.Error = err.Error()
+		s.respondAdminPage(w, r, http.StatusBadRequest, "edit", data)
+		return
+	}
+
+	f, err := s.facts.CreateFact(r.Context(), fact)
+	if err != nil {
+		s.respondAdminError(w, r, err)
+		return
+	}
+
+	s.publish(r.Context(), EventFactCreated, f.ID, &f)
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/fact/%d?done=created", f.ID), http.StatusSeeOther)
+}
+
+// AdminFactHandler shows a form to edit a fact, and saves the changes
+// when the form is sent. Changes made since the form was shown aren't
+// overwritten.
+func (s *Service) AdminFactHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := adminFactID(r)
+	if !ok {
+		s.respondAdminNotFound(w, r)
+		return
+	}
+
+	f, err := s.facts.Fact(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		s.respondAdminNotFound(w, r)
+		return
+	}
+	if err != nil {
+		s.respondAdminError(w, r, err)
+		return
+	}
+
+	data := s.newAdminData(r, fmt.Sprintf("Fact #%d", f.ID))
+	data.Fact = f
+
+	if r.Method == http.MethodGet {
+		data.Form = newFactForm(f)
+		s.respondAdminPage(w, r, http.StatusOK, "edit", data)
+		return
+	}
+
+	data.Form = postedFactForm(r)
+	version, ok := postedVersion(r)
+	if !ok {
+		s.respondAdminMessage(w, r, http.StatusBadRequest, "Not saved", "The form didn't say which version of the fact you edited, so your changes weren't saved. Reload the page and make them again.")
+		return
+	}
+	data.Form.Version = version
+
+	fact, err := data.Form.fact(f.Citations)
+	if err != nil {
+		data.Error = err.Error()
+		s.respondAdminPage(w, r, http.StatusBadRequest, "edit", data)
+		return
+	}
+
+	f, err = s.facts.UpdateFact(r.Context(), id, FactUpdate{
+		Content:   &fact.Content,
+		Source:    &fact.Source,
+		Citations: &fact.Citations,
+		Weight:    &fact.Weight,
+		Version:   data.Form.Version,
+	})
+	switch {
+	case errors.Is(err, ErrVersionMismatch):
+		data.Error = "Someone else changed this fact after you started editing it. Reload the page to see their changes, then make yours again."
+		s.respondAdminPage(w, r, http.StatusConflict, "edit", data)
+		return
+	case errors.Is(err, ErrNotFound):
+		s.respondAdminNotFound(w, r)
+		return
+	case err != nil:
+		s.respondAdminError(w, r, err)
+		return
+	}
+
+	s.publish(r.Context(), EventFactUpdated, f.ID, &f)
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/fact/%d?done=saved", f.ID), http.StatusSeeOther)
+}
+
+// AdminDeleteHandler deletes a fact, unless it was changed after the
+// admin last saw it.
+func (s *Service) AdminDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := adminFactID(r)
+	if !ok {
+		s.respondAdminNotFound(w, r)
+		return
+	}
+
+	version, ok := postedVersion(r)
+	if !ok {
+		s.respondAdminMessage(w, r, http.StatusBadRequest, "Not deleted", "The form didn't say which version of the fact you saw, so it wasn't deleted. Reload the page and try again.")
+		return
+	}
+
+	err := s.facts.DeleteFact(r.Context(), id, version)
+	switch {
+	case errors.Is(err, ErrVersionMismatch):
+		s.respondAdminMessage(w, r, http.StatusConflict, "Not deleted", "Someone else changed this fact after you last saw it, so it wasn't deleted. Look at their changes and try again.")
+		return
+	case errors.Is(err, ErrNotFound):
+		s.respondAdminNotFound(w, r)
+		return
+	case err != nil:
+		s.respondAdminError(w, r, err)
+		return
+	}
+
+	s.publish(r.Context(), EventFactDeleted, id, nil)
+
+	http.Redirect(w, r, "/admin/deleted?done=deleted", http.StatusSeeOther)
+}
+
+// AdminRestoreHandler undoes the deletion of a fact.
+func (s *Service) AdminRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := adminFactID(r)
+	if !ok {
+		s.respondAdminNotFound(w, r)
+		return
+	}
+
+	f, err := s.facts.RestoreFact(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		s.respondAdminNotFound(w, r)
+		return
+	}
+	if err != nil {
+		s.respondAdminError(w, r, err)
+		return
+	}
+
+	// To everyone else, the fact is new again.
+	s.publish(r.Context(), EventFactCreated, f.ID, &f)
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/fact/%d?done=restored", f.ID), http.StatusSeeOther)
+}
+
+// AdminDeletedHandler lists the most recently deleted facts.
+func (s *Service) AdminDeletedHandler(w http.ResponseWriter, r *http.Request) {
+	data := s.newAdminData(r, "Deleted facts")
+
+	var err error
+	data.Facts, err = s.facts.DeletedFacts(r.Context(), adminPageSize)
+	if err != nil {
+		s.respondAdminError(w, r, err)
+		return
+	}
+
+	s.respondAdminPage(w, r, http.StatusOK, "deleted", data)
+}
+
+// AdminImportHandler creates many facts at once from a JSON array or
+// newline-delimited JSON, pasted or uploaded, in the format taken by
+// BatchHandler. Nothing is created unless every fact is valid.
+func (s *Service) AdminImportHandler(w http.ResponseWriter, r *http.Request) {
+	data := s.newAdminData(r, "Import facts")
+
+	if r.Method == http.MethodGet {
+		if n, err := strconv.Atoi(r.URL.Query().Get("imported")); err == nil {
+			data.Notice = fmt.Sprintf("Imported %d facts.", n)
+		}
+		s.respondAdminPage(w, r, http.StatusOK, "import", data)
+		return
+	}
+
+	// An uploaded file takes the place of what was pasted.
+	data.Import = r.PostFormValue("facts")
+	var src io.Reader = strings.NewReader(data.Import)
+	if file, _, err := r.FormFile("file"); err == nil {
+		defer file.Close()
+		src = file
+	}
+
+	items, err := decodeBatch(src)
+	switch {
+	case err != nil:
+		data.Error = err.Error()
+	case len(items) == 0:
+		data.Error = "There are no facts to import."
+	}
+	if data.Error != "" {
+		s.respondAdminPage(w, r, http.StatusBadRequest, "import", data)
+		return
+	}
+
+	results, created, err := s.createBatch(r.Context(), items, BatchModeAtomic)
+	if err != nil {
+		s.respondAdminError(w, r, err)
+		return
+	}
+
+	if created < len(items) {
+		for _, result := range results {
+			if result.Status == http.StatusBadRequest {
+				data.Rejected = append(data.Rejected, ImportRejection{Line: result.Index + 1, Error: result.Error})
+			}
+		}
+		data.Error = fmt.Sprintf("%d of %d facts are invalid, so none were imported.", len(data.Rejected), len(items))
+		s.respondAdminPage(w, r, http.StatusBadRequest, "import", data)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/admin/import?imported=%d", created), http.StatusSeeOther)
+}
+
+// AdminActivityHandler lists the most recent changes to facts, as kept
+// by the event log.
+func (s *Service) AdminActivityHandler(w http.ResponseWriter, r *http.Request) {
+	data := s.newAdminData(r, "Activity")
+
+	events, _, err := s.events.log.EventsAfter(r.Context(), 0)
+	if err != nil {
+		s.respondAdminError(w, r, err)
+		return
+	}
+
+	for i := len(events) - 1; i >= 0 && len(data.Events) < maxActivity; i-- {
+		data.Events = append(data.Events, events[i])
+	}
+
+	s.respondAdminPage(w, r, http.StatusOK, "activity", data)
+}
+
+// newAdminData starts the data for an admin page, with the notice asked
+// for by the done query parameter.
+func (s *Service) newAdminData(r *http.Request, title string) adminData {
+	sess, _ := s.adminSession(r)
+	return adminData{
+		Title:  title,
+		CSRF:   sess.csrf,
+		Notice: adminNotices[r.URL.Query().Get("done")],
+	}
+}
+
+// postedFactForm returns the fact form sent with r. Its version is left
+// for postedVersion, since only edits need one.
+func postedFactForm(r *http.Request) factForm {
+	return factForm{
+		Content:   r.PostFormValue("content"),
+		Source:    r.PostFormValue("source"),
+		Citations: r.PostFormValue("citations"),
+		Weight:    r.PostFormValue("weight"),
+	}
+}
+
+// postedVersion returns the version of the fact the admin saw when the
+// form sent with r was shown.
+func postedVersion(r *http.Request) (int64, bool) {
+	version, err := strconv.ParseInt(r.PostFormValue("version"), 10, 64)
+	return version, err == nil && version > 0
+}
+
+func adminFactID(r *http.Request) (int64, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	return id, err == nil
+}
+
+func newAdminToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// respondAdminPage shows the admin page called name.
+func (s *Service) respondAdminPage(w http.ResponseWriter, r *http.Request, code int, name string, data adminData) {
+	h := w.Header()
+	h.Set("Cache-Control", cacheControlNoStore)
+	h.Set("X-Frame-Options", "DENY")
+
+	s.render(w, r, code, adminPages[name], name, data)
+}
+
+func (s *Service) respondAdminMessage(w http.ResponseWriter, r *http.Request, code int, title, message string) {
+	data := s.newAdminData(r, title)
+	data.Error = message
+	s.respondAdminPage(w, r, code, "message", data)
+}
+
+func (s *Service) respondAdminNotFound(w http.ResponseWriter, r *http.Request) {
+	s.respondAdminMessage(w, r, http.StatusNotFound, "Not found", "There's no such fact. It may have been deleted.")
+}
+
+func (s *Service) respondAdminError(w http.ResponseWriter, r *http.Request, err error) {
+	log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+		"err", err,
+	).Error("")
+
+	s.respondAdminMessage(w, r, http.StatusInternalServerError, "Something went wrong", "Sorry, something went wrong. Please try again later.")
+}
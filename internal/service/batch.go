@@ -0,0 +1,217 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	log "golang.org/x/exp/slog"
+)
+
+const (
+	BatchModeAtomic     = "atomic"
+	BatchModeBestEffort = "best-effort"
+)
+
+const maxBatchSize = 10000
+
+// maxBatchBody is the largest request body the batch endpoint accepts,
+// the same as the admin pages allow for imports.
+const maxBatchBody = maxAdminForm
+
+// errBodyTooLarge is the error for a request body over its limit.
+var errBodyTooLarge = errors.New("request body is too large")
+
+// BatchResult describes what happened to one item in a batch.
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Status int    `json:"status"`
+	Fact   *Fact  `json:"fact,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BatchHandler creates many facts at once. The body is either a JSON
+// array of facts or a stream of newline-delimited JSON facts.
+//
+// In atomic mode, the default, nothing is created unless every item is
+// valid. In best-effort mode the valid items are created and the
+// invalid ones are reported.
+func (s *Service) BatchHandler(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	switch mode {
+	case "":
+		mode = BatchModeAtomic
+	case BatchModeAtomic, BatchModeBestEffort:
+	default:
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("mode must be 'atomic' or 'best-effort'"))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBody)
+	items, err := decodeBatch(r.Body)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		s.RespondErrorJSON(w, http.StatusRequestEntityTooLarge, errBodyTooLarge)
+		return
+	}
+	if err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if len(items) == 0 {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("batch is empty"))
+		return
+	}
+
+	results, created, err := s.createBatch(r.Context(), items, mode)
+	if err != nil {
+		log.With(
+			"request_uri", r.RequestURI,
+			"http_method", r.Method,
+			"batch_size", len(items),
+			"err", err,
+		).Error("")
+		s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+
+	failed := len(items) - created
+	if failed > 0 && mode == BatchModeAtomic {
+		s.RespondJSON(w, http.StatusBadRequest, map[string]any{
+			"error":   fmt.Sprintf("%d of %d items are invalid", failed, len(items)),
+			"results": results,
+		})
+		return
+	}
+
+	status := http.StatusCreated
+	if failed > 0 {
+		status = http.StatusOK
+	}
+
+	s.RespondJSON(w, status, map[string]any{
+		"created": created,
+		"failed":  failed,
+		"results": results,
+	})
+}
+
+// createBatch validates each item of a batch and creates the valid ones,
+// unless mode is atomic and some are invalid, in which case nothing is
+// created. It returns what happened to each item and how many facts were
+// created.
+func (s *Service) createBatch(ctx context.Context, items []json.RawMessage, mode string) ([]BatchResult, int, error) {
+	results := make([]BatchResult, len(items))
+	var facts []Fact
+	var indexes []int
+	for i, item := range items {
+		results[i] = BatchResult{Index: i}
+
+		var in factInput
+		if err := json.Unmarshal(item, &in); err != nil {
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = "bad request"
+			continue
+		}
+
+		f, err := in.fact()
+		if err != nil {
+			results[i].Status = http.StatusBadRequest
+			results[i].Error = err.Error()
+			continue
+		}
+
+		facts = append(facts, f)
+		indexes = append(indexes, i)
+	}
+
+	if len(facts) < len(items) && mode == BatchModeAtomic {
+		for i := range results {
+			if results[i].Status == 0 {
+				results[i].Status = http.StatusFailedDependency
+				results[i].Error = "not created because other items are invalid"
+			}
+		}
+		return results, 0, nil
+	}
+
+	if len(facts) == 0 {
+		return results, 0, nil
+	}
+
+	created, err := s.facts.CreateFacts(ctx, facts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for j, f := range created {
+		f := f
+		results[indexes[j]].Status = http.StatusCreated
+		results[indexes[j]].Fact = &f
+
+		s.publish(ctx, EventFactCreated, f.ID, &f)
+	}
+	return results, len(created), nil
+}
+
+// decodeBatch splits a JSON array or a stream of newline-delimited JSON
+// values into its items without interpreting them, so that one bad item
+// doesn't stop the others from being checked.
+func decodeBatch(r io.Reader) ([]json.RawMessage, error) {
+	br := bufio.NewReader(r)
+
+	array := false
+	for {
+		b, err := br.Peek(1)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(bytes.TrimSpace(b)) == 0 {
+			br.ReadByte()
+			continue
+		}
+		array = b[0] == '['
+		break
+	}
+
+	dec := json.NewDecoder(br)
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return nil, errors.New("bad request")
+		}
+	}
+
+	var items []json.RawMessage
+	for dec.More() {
+		if len(items) == maxBatchSize {
+			return nil, fmt.Errorf("too many items, at most %d are allowed", maxBatchSize)
+		}
+
+		var item json.RawMessage
+		if err := dec.Decode(&item); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("item %d is not valid JSON", len(items))
+		}
+		items = append(items, item)
+	}
+
+	if array {
+		if _, err := dec.Token(); err != nil {
+			return nil, errors.New("bad request")
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// Facts can change at any moment, so caches may keep them but must
+	// check they're still current before reusing them.
+	cacheControlRevalidate = "no-cache"
+
+	// Random facts are different every time.
+	cacheControlNoStore = "no-store"
+)
+
+// factVersion identifies the current state of f.
+func factVersion(f Fact) string {
+	return fmt.Sprintf("%d-%d", f.ID, f.Version)
+}
+
+// factsVersion identifies the current state of a list of facts. Adding,
+// changing or removing any of them changes the version.
+func factsVersion(facts []Fact) string {
+	h := fnv.New64a()
+	for _, f := range facts {
+		fmt.Fprintf(h, "%s;", factVersion(f))
+	}
+	return fmt.Sprintf("%d-%x", len(facts), h.Sum64())
+}
+
+// etag turns a version into a strong entity tag for one of its
+// representations, since a fact rendered as text isn't byte-for-byte
+// the same as the fact rendered as JSON.
+func etag(version, format string) string {
+	if format != "json" {
+		version += "." + format
+	}
+	return `"` + version + `"`
+}
+
+// notModified reports whether the client already has the representation
+// described by tag and modified, following the precedence in RFC 9110:
+// If-None-Match is used when present, otherwise If-Modified-Since.
+func notModified(r *http.Request, tag string, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, tag, true)
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || modified.IsZero() {
+		return false
+	}
+
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	return !modified.Truncate(time.Second).After(t)
+}
+
+// etagMatches reports whether tag appears in a list of entity tags like
+// the one in an If-None-Match or If-Match header. Weak comparison
+// ignores the W/ prefix.
+func etagMatches(header, tag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == tag {
+			return true
+		}
+	}
+	return false
+}
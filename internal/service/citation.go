@@ -0,0 +1,51 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Citation is a structured reference backing up a fact.
+type Citation struct {
+	URL        string `json:"url" xml:"url" yaml:"url"`
+	Title      string `json:"title,omitempty" xml:"title,omitempty" yaml:"title,omitempty"`
+	Author     string `json:"author,omitempty" xml:"author,omitempty" yaml:"author,omitempty"`
+	Publisher  string `json:"publisher,omitempty" xml:"publisher,omitempty" yaml:"publisher,omitempty"`
+	Accessed   string `json:"accessed,omitempty" xml:"accessed,omitempty" yaml:"accessed,omitempty"`
+	ArchiveURL string `json:"archive_url,omitempty" xml:"archive_url,omitempty" yaml:"archive_url,omitempty"`
+}
+
+const maxCitations = 16
+
+func validateCitations(citations []Citation) error {
+	if len(citations) > maxCitations {
+		return fmt.Errorf("too many citations, at most %d are allowed", maxCitations)
+	}
+
+	for i, c := range citations {
+		if !isWebURL(c.URL) {
+			return fmt.Errorf("citations[%d].url must be an absolute http or https URL", i)
+		}
+
+		if c.ArchiveURL != "" && !isWebURL(c.ArchiveURL) {
+			return fmt.Errorf("citations[%d].archive_url must be an absolute http or https URL", i)
+		}
+
+		if c.Accessed != "" {
+			if _, err := time.Parse("2006-01-02", c.Accessed); err != nil {
+				return fmt.Errorf("citations[%d].accessed must be a date formatted as YYYY-MM-DD", i)
+			}
+		}
+	}
+
+	return nil
+}
+
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
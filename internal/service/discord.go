@@ -0,0 +1,345 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	log "golang.org/x/exp/slog"
+)
+
+// DiscordAPI is the base URL of Discord's HTTP API.
+const DiscordAPI = "https://discord.com/api/v10"
+
+// maxDiscordRequestSize is far more than Discord ever sends for an
+// interaction.
+const maxDiscordRequestSize = 64 << 10
+
+// Discord rejects embeds whose description or field values are longer than
+// these many characters.
+const (
+	maxDiscordDescription = 4096
+	maxDiscordFieldValue  = 1024
+)
+
+// Kinds of interaction Discord sends.
+const (
+	DiscordPing               = 1
+	DiscordApplicationCommand = 2
+)
+
+// Kinds of response to an interaction.
+const (
+	DiscordPong                     = 1
+	DiscordChannelMessageWithSource = 4
+)
+
+// DiscordEphemeral is the message flag that shows a response only to the
+// person who used the command.
+const DiscordEphemeral = 1 << 6
+
+// Kinds of application command and command option.
+const (
+	DiscordChatInput     = 1
+	DiscordIntegerOption = 4
+)
+
+// WithDiscord answers Discord interactions signed with publicKey, the
+// Discord application's public key. Without one, the endpoint responds
+// with HTTP 501.
+func WithDiscord(publicKey ed25519.PublicKey) optionFunc {
+	return func(s *Service) { s.discordKey = publicKey }
+}
+
+// DiscordInteraction is the part of an interaction factoid reads.
+type DiscordInteraction struct {
+	Type int                 `json:"type"`
+	Data *DiscordCommandData `json:"data,omitempty"`
+}
+
+// DiscordCommandData says which command was used, and how.
+type DiscordCommandData struct {
+	Name    string               `json:"name"`
+	Options []DiscordOptionValue `json:"options,omitempty"`
+}
+
+// DiscordOptionValue is an option given to a command.
+type DiscordOptionValue struct {
+	Name  string          `json:"name"`
+	Type  int             `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// DiscordResponse is a response to an interaction.
+type DiscordResponse struct {
+	Type int             `json:"type"`
+	Data *DiscordMessage `json:"data,omitempty"`
+}
+
+// DiscordMessage is a message sent in response to a command.
+type DiscordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []DiscordEmbed `json:"embeds,omitempty"`
+	Flags   int            `json:"flags,omitempty"`
+}
+
+// DiscordEmbed is a rich message showing a fact.
+type DiscordEmbed struct {
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	URL         string              `json:"url,omitempty"`
+	Timestamp   *time.Time          `json:"timestamp,omitempty"`
+	Fields      []DiscordEmbedField `json:"fields,omitempty"`
+}
+
+// DiscordEmbedField is a titled piece of an embed.
+type DiscordEmbedField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// DiscordCommand describes an application command to Discord.
+type DiscordCommand struct {
+	Name        string                 `json:"name"`
+	Type        int                    `json:"type"`
+	Description string                 `json:"description"`
+	Options     []DiscordCommandOption `json:"options,omitempty"`
+}
+
+// DiscordCommandOption describes an option of an application command.
+type DiscordCommandOption struct {
+	Type        int    `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required,omitempty"`
+	MinValue    *int64 `json:"min_value,omitempty"`
+}
+
+// DiscordFactCommand is the /fact command DiscordHandler answers.
+var DiscordFactCommand = DiscordCommand{
+	Name:        "fact",
+	Type:        DiscordChatInput,
+	Description: "Share a fun fact",
+	Options: []DiscordCommandOption{
+		{
+			Type:        DiscordIntegerOption,
+			Name:        "id",
+			Description: "The fact to share, instead of a random one",
+			MinValue:    new(int64),
+		},
+	},
+}
+
+// RegisterDiscordCommands replaces the application's global commands with
+// commands, using the bot token of the application with the given ID.
+// Discord keeps commands once they're registered, so this only needs to
+// happen when they change.
+func RegisterDiscordCommands(ctx context.Context, client *http.Client, apiURL, appID, token string, commands ...DiscordCommand) error {
+	body, err := json.Marshal(commands)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, apiURL+"/applications/"+appID+"/commands", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bot "+token)
+
+	rsp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(rsp.Body, 1<<10))
+		return fmt.Errorf("registering discord commands: http %d: %s", rsp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// DiscordHandler answers Discord interactions: PINGs, and the /fact
+// command described by DiscordFactCommand, which shares a random fact,
+// or the one whose ID is given. Requests must carry a valid signature.
+func (s *Service) DiscordHandler(w http.ResponseWriter, r *http.Request) {
+	if s.discordKey == nil {
+		s.unimplemented(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxDiscordRequestSize))
+	if err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("bad request"))
+		return
+	}
+
+	if err := verifyDiscordRequest(s.discordKey, r.Header, body); err != nil {
+		s.RespondErrorJSON(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	var interaction DiscordInteraction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("bad request"))
+		return
+	}
+
+	logger := log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+		"discord_interaction_type", interaction.Type,
+	)
+
+	var rsp DiscordResponse
+	switch interaction.Type {
+	case DiscordPing:
+		rsp = DiscordResponse{Type: DiscordPong}
+	case DiscordApplicationCommand:
+		if interaction.Data == nil || interaction.Data.Name != DiscordFactCommand.Name {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("unknown command"))
+			return
+		}
+
+		msg, err := s.discordCommand(r.Context(), interaction.Data)
+		if err != nil {
+			logger.With("err", err).Error("")
+			msg = discordNotice("Sorry, something went wrong. Please try again later.")
+		}
+		rsp = DiscordResponse{Type: DiscordChannelMessageWithSource, Data: &msg}
+	default:
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("unsupported interaction type"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(rsp); err != nil {
+		logger.With("err", err).Error("")
+	}
+}
+
+// verifyDiscordRequest checks the Ed25519 signature Discord makes of the
+// timestamp and body of every request.
+func verifyDiscordRequest(key ed25519.PublicKey, header http.Header, body []byte) error {
+	timestamp := header.Get("X-Signature-Timestamp")
+	signature := header.Get("X-Signature-Ed25519")
+	if timestamp == "" || signature == "" {
+		return errors.New("request is not signed")
+	}
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return errors.New("request signature is invalid")
+	}
+
+	msg := append([]byte(timestamp), body...)
+	if !ed25519.Verify(key, msg, sig) {
+		return errors.New("request signature is invalid")
+	}
+	return nil
+}
+
+// discordCommand carries out the /fact command. Only failures of the
+// server are returned as errors.
+func (s *Service) discordCommand(ctx context.Context, data *DiscordCommandData) (DiscordMessage, error) {
+	var id *int64
+	for _, opt := range data.Options {
+		if opt.Name != "id" {
+			return discordNotice(fmt.Sprintf("/%s doesn't have a %q option.", DiscordFactCommand.Name, opt.Name)), nil
+		}
+
+		n, err := strconv.ParseInt(string(opt.Value), 10, 64)
+		if err != nil {
+			return discordNotice("The id should be a whole number."), nil
+		}
+		id = &n
+	}
+
+	if id != nil {
+		f, err := s.facts.Fact(ctx, *id)
+		if errors.Is(err, ErrNotFound) {
+			return discordNotice(fmt.Sprintf("There's no fact %d.", *id)), nil
+		}
+		if err != nil {
+			return DiscordMessage{}, err
+		}
+		return DiscordMessage{Embeds: []DiscordEmbed{discordEmbed(f)}}, nil
+	}
+
+	facts, err := s.randomFacts(ctx, 1, RandomOptions{})
+	if errors.Is(err, ErrNotFound) {
+		return discordNotice("There are no facts yet."), nil
+	}
+	if err != nil {
+		return DiscordMessage{}, err
+	}
+
+	if err := s.facts.MarkServed(ctx, facts[0].ID); err != nil {
+		return DiscordMessage{}, err
+	}
+	return DiscordMessage{Embeds: []DiscordEmbed{discordEmbed(facts[0])}}, nil
+}
+
+// discordNotice is a message only the person who used a command sees.
+func discordNotice(text string) DiscordMessage {
+	return DiscordMessage{Content: text, Flags: DiscordEphemeral}
+}
+
+// discordEmbed shows a fact, linking its title to the first citation and
+// listing where it comes from. Text too long for Discord is cut short.
+func discordEmbed(f Fact) DiscordEmbed {
+	e := DiscordEmbed{
+		Title:       fmt.Sprintf("Fact %d", f.ID),
+		Description: discordTruncate(f.Content, maxDiscordDescription),
+	}
+	if !f.CreatedAt.IsZero() {
+		created := f.CreatedAt.UTC()
+		e.Timestamp = &created
+	}
+
+	if len(f.Citations) > 0 {
+		e.URL = f.Citations[0].URL
+
+		// Whole citations are dropped rather than cutting a link in half,
+		// unless even the first doesn't fit.
+		var sources bytes.Buffer
+		for i, c := range f.Citations {
+			line := c.URL
+			if c.Title != "" {
+				line = fmt.Sprintf("[%s](%s)", c.Title, c.URL)
+			}
+			if i > 0 {
+				if utf8.RuneCount(sources.Bytes())+utf8.RuneCountInString(line)+len("\n\n…") > maxDiscordFieldValue {
+					sources.WriteString("\n…")
+					break
+				}
+				sources.WriteByte('\n')
+			}
+			sources.WriteString(line)
+		}
+		e.Fields = append(e.Fields, DiscordEmbedField{Name: "Sources", Value: discordTruncate(sources.String(), maxDiscordFieldValue)})
+	} else if f.Source != "" {
+		e.Fields = append(e.Fields, DiscordEmbedField{Name: "Source", Value: discordTruncate(f.Source, maxDiscordFieldValue)})
+	}
+	return e
+}
+
+// discordTruncate cuts s to at most n characters, without splitting a
+// UTF-8 character, and marks the cut with an ellipsis.
+func discordTruncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n-1]) + "…"
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"net/http"
+	"time"
+)
+
+// openAPIDocument describes the API. It's written by hand, and the
+// tests in servicetest check it against Routes.
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+//go:embed docs
+var docsAssets embed.FS
+
+var (
+	openAPIETag = func() string {
+		h := fnv.New64a()
+		h.Write(openAPIDocument)
+		return fmt.Sprintf(`"%x"`, h.Sum64())
+	}()
+
+	docsFiles = func() http.Handler {
+		sub, err := fs.Sub(docsAssets, "docs")
+		if err != nil {
+			panic(err)
+		}
+		return http.StripPrefix("/docs", http.FileServer(http.FS(sub)))
+	}()
+)
+
+// OpenAPIHandler serves the OpenAPI document describing the API.
+func (s *Service) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", cacheControlRevalidate)
+	w.Header().Set("ETag", openAPIETag)
+	http.ServeContent(w, r, "openapi.json", time.Time{}, bytes.NewReader(openAPIDocument))
+}
+
+// DocsHandler serves a page that renders the OpenAPI document for
+// people to read.
+func (s *Service) DocsHandler(w http.ResponseWriter, r *http.Request) {
+	docsFiles.ServeHTTP(w, r)
+}
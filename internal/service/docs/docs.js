@@ -0,0 +1,199 @@
+// Renders the service's OpenAPI document as a page. It's deliberately
+// small and only understands the parts of OpenAPI the document uses.
+"use strict";
+
+const methods = ["get", "put", "post", "patch", "delete"];
+
+function el(tag, attrs, ...children) {
+  const e = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    e.setAttribute(k, v);
+  }
+  for (const child of children) {
+    if (child != null) {
+      e.append(child);
+    }
+  }
+  return e;
+}
+
+// prose renders text with `code` spans.
+function prose(tag, text) {
+  const e = el(tag);
+  (text || "").split(/(`[^`]*`)/).forEach((part) => {
+    if (part.startsWith("`") && part.endsWith("`") && part.length > 1) {
+      e.append(el("code", {}, part.slice(1, -1)));
+    } else {
+      e.append(part);
+    }
+  });
+  return e;
+}
+
+function resolve(doc, obj) {
+  while (obj && obj.$ref) {
+    const { $ref, ...rest } = obj;
+    const target = $ref.replace(/^#\//, "").split("/").reduce((o, key) => o[key], doc);
+    obj = { ...target, ...rest };
+  }
+  return obj;
+}
+
+// outline describes a schema as JSON-like text.
+function outline(doc, schema, indent, seen) {
+  const pad = "  ".repeat(indent);
+  const ref = schema && schema.$ref;
+  schema = resolve(doc, schema) || {};
+
+  if (ref && seen.includes(ref)) {
+    return ref.split("/").pop();
+  }
+  seen = ref ? [...seen, ref] : seen;
+
+  if (schema.allOf) {
+    const merged = { type: "object", properties: {}, required: [] };
+    for (const part of schema.allOf.map((s) => resolve(doc, s))) {
+      Object.assign(merged.properties, part.properties);
+      merged.required.push(...(part.required || []));
+    }
+    return outline(doc, merged, indent, seen);
+  }
+
+  if (schema.type === "array") {
+    return "[" + outline(doc, schema.items, indent, seen) + ", …]";
+  }
+
+  if (schema.type === "object" || schema.properties) {
+    const required = schema.required || [];
+    const lines = Object.entries(schema.properties || {}).map(([name, prop]) => {
+      const optional = required.includes(name) ? "" : "?";
+      return pad + "  " + JSON.stringify(name) + optional + ": " + outline(doc, prop, indent + 1, seen);
+    });
+    return "{\n" + lines.join(",\n") + "\n" + pad + "}";
+  }
+
+  let s = schema.type || "any";
+  if (schema.format) {
+    s += " (" + schema.format + ")";
+  }
+  if (schema.enum) {
+    s = schema.enum.map((v) => JSON.stringify(v)).join(" | ");
+  }
+  if (schema.const) {
+    s = JSON.stringify(schema.const);
+  }
+  return s;
+}
+
+function parametersTable(doc, params) {
+  if (params.length === 0) {
+    return null;
+  }
+
+  const rows = params.map((p) => el("tr", {},
+    el("td", {}, el("code", {}, p.name), p.required ? " *" : ""),
+    el("td", {}, p.in),
+    el("td", {}, el("code", {}, outline(doc, p.schema, 0, []))),
+    prose("td", p.description),
+  ));
+
+  return el("table", {},
+    el("thead", {}, el("tr", {}, el("th", {}, "Name"), el("th", {}, "In"), el("th", {}, "Type"), el("th", {}, "Description"))),
+    el("tbody", {}, ...rows),
+  );
+}
+
+function content(doc, body) {
+  const section = el("div");
+  for (const [type, media] of Object.entries(body.content || {})) {
+    section.append(el("p", {}, el("code", {}, type)));
+    if (media.description) {
+      section.append(prose("p", media.description));
+    }
+    if (media.schema) {
+      section.append(el("pre", {}, outline(doc, media.schema, 0, [])));
+    }
+  }
+  return section;
+}
+
+function operation(doc, path, method, pathItem, op) {
+  const id = op.operationId || method + path;
+  const params = [...(pathItem.parameters || []), ...(op.parameters || [])].map((p) => resolve(doc, p));
+
+  const section = el("section", { class: "operation", id: id },
+    el("h3", {},
+      el("span", { class: "method " + method }, method),
+      " ",
+      el("code", { class: op.security ? "locked" : "" }, path),
+    ),
+    el("p", {}, el("strong", {}, op.summary || "")),
+  );
+
+  if (op.description) {
+    section.append(prose("p", op.description));
+  }
+  if (op.security) {
+    section.append(prose("p", "May need the secret the server was started with in the `Authorization` header."));
+  }
+
+  const table = parametersTable(doc, params);
+  if (table) {
+    section.append(el("h4", {}, "Parameters"), table);
+  }
+
+  if (op.requestBody) {
+    section.append(el("h4", {}, "Request body"), content(doc, resolve(doc, op.requestBody)));
+  }
+
+  section.append(el("h4", {}, "Responses"));
+  for (const [status, ref] of Object.entries(op.responses || {})) {
+    const rsp = resolve(doc, ref);
+    section.append(el("p", {}, el("strong", {}, status), " ", prose("span", rsp.description)), content(doc, rsp));
+  }
+
+  return section;
+}
+
+function render(doc) {
+  document.title = doc.info.title + " API";
+  document.getElementById("title").textContent = doc.info.title + " API";
+  document.getElementById("summary").replaceWith(prose("p", doc.info.description));
+
+  const toc = el("ul");
+  const main = document.getElementById("operations");
+  main.replaceChildren();
+
+  const tags = (doc.tags || []).map((t) => t.name);
+  for (const tag of tags) {
+    const ops = [];
+    for (const [path, pathItem] of Object.entries(doc.paths)) {
+      for (const method of methods) {
+        const op = pathItem[method];
+        if (op && (op.tags || []).includes(tag)) {
+          ops.push(operation(doc, path, method, pathItem, op));
+          toc.append(el("li", {}, el("a", { href: "#" + (op.operationId || method + path) }, op.summary || path)));
+        }
+      }
+    }
+
+    const info = doc.tags.find((t) => t.name === tag);
+    main.append(el("h2", {}, tag), prose("p", info.description), ...ops);
+  }
+
+  document.getElementById("toc").append(el("h2", {}, "Operations"), toc);
+}
+
+fetch("../openapi.json")
+  .then((rsp) => {
+    if (!rsp.ok) {
+      throw new Error(rsp.status + " " + rsp.statusText);
+    }
+    return rsp.json();
+  })
+  .then(render)
+  .catch((err) => {
+    document.getElementById("operations").replaceChildren(
+      el("p", { class: "error" }, "Couldn't load the API description: " + err.message),
+    );
+  });
@@ -5,5 +5,7 @@ import (
 )
 
 var (
-	ErrNotFound = errors.New("not found")
+	ErrNotFound        = errors.New("not found")
+	ErrVersionMismatch = errors.New("version mismatch")
+	ErrConflict        = errors.New("conflict")
 )
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// Kinds of events.
+const (
+	EventFactCreated = "fact.created"
+	EventFactUpdated = "fact.updated"
+	EventFactDeleted = "fact.deleted"
+)
+
+// Event is something that happened to a fact.
+type Event struct {
+	// ID increases with every event.
+	ID     int64     `json:"id"`
+	Type   string    `json:"type"`
+	Time   time.Time `json:"time"`
+	FactID int64     `json:"fact_id"`
+
+	// Fact is the fact after it was created or updated. It's nil for
+	// deleted facts.
+	Fact *Fact `json:"fact,omitempty"`
+}
+
+type EventLog interface {
+	// AppendEvent records e and returns it with its ID set.
+	AppendEvent(ctx context.Context, e Event) (Event, error)
+
+	// EventsAfter returns the events that came after the one with the
+	// given ID, oldest first. If some of them are no longer kept,
+	// complete is false and every event still kept is returned.
+	EventsAfter(ctx context.Context, id int64) (events []Event, complete bool, err error)
+}
+
+// WithEventLog keeps the events sent to streaming clients in l, so that
+// clients that reconnect can catch up on what they missed. By default
+// the last 1000 events are kept in memory.
+func WithEventLog(l EventLog) optionFunc {
+	return func(s *Service) { s.events.log = l }
+}
+
+// MemoryEventLog keeps the most recent events in memory.
+type MemoryEventLog struct {
+	mu     sync.Mutex
+	events []Event
+	size   int
+	lastID int64
+}
+
+// NewMemoryEventLog returns an EventLog that keeps the last size events.
+func NewMemoryEventLog(size int) *MemoryEventLog {
+	return &MemoryEventLog{size: size}
+}
+
+func (m *MemoryEventLog) AppendEvent(_ context.Context, e Event) (Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastID++
+	e.ID = m.lastID
+
+	m.events = append(m.events, e)
+	if len(m.events) > m.size {
+		m.events = append(m.events[:0], m.events[len(m.events)-m.size:]...)
+	}
+	return e, nil
+}
+
+func (m *MemoryEventLog) EventsAfter(_ context.Context, id int64) ([]Event, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// An ID from the future was handed out before a restart.
+	if id > m.lastID {
+		return append([]Event(nil), m.events...), false, nil
+	}
+
+	oldest := m.lastID + 1
+	if len(m.events) > 0 {
+		oldest = m.events[0].ID
+	}
+	if id+1 < oldest {
+		return append([]Event(nil), m.events...), false, nil
+	}
+
+	return append([]Event(nil), m.events[id+1-oldest:]...), true, nil
+}
+
+// subscriberBuffer is how many events a subscriber may fall behind by
+// before it's dropped.
+const subscriberBuffer = 64
+
+// eventBroker records events and hands them to subscribers.
+type eventBroker struct {
+	log EventLog
+
+	// mu makes publishing and subscribing take turns, so subscribers
+	// don't miss or repeat events published while they catch up.
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+	closed      bool
+}
+
+func newEventBroker(l EventLog) *eventBroker {
+	return &eventBroker{
+		log:         l,
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// publish returns e with its ID set.
+func (b *eventBroker) publish(ctx context.Context, e Event) (Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e, err := b.log.AppendEvent(ctx, e)
+	if err != nil {
+		return Event{}, err
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// The subscriber can catch up by reconnecting with the
+			// ID of the last event it got.
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+	return e, nil
+}
+
+// subscribe returns the events after the one with the given ID and a
+// channel of events to come. A negative ID skips straight to the events
+// to come. The channel is closed if the subscriber falls behind or the
+// broker is closed. cancel must be called once the subscriber is done.
+func (b *eventBroker) subscribe(ctx context.Context, after int64) (backlog []Event, complete bool, ch <-chan Event, cancel func(), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = true
+	if after >= 0 {
+		backlog, complete, err = b.log.EventsAfter(ctx, after)
+		if err != nil {
+			return nil, false, nil, nil, err
+		}
+	}
+
+	c := make(chan Event, subscriberBuffer)
+	if b.closed {
+		close(c)
+		return backlog, complete, c, func() {}, nil
+	}
+	b.subscribers[c] = struct{}{}
+
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		if _, ok := b.subscribers[c]; ok {
+			delete(b.subscribers, c)
+			close(c)
+		}
+	}
+	return backlog, complete, c, cancel, nil
+}
+
+// close ends every subscription.
+func (b *eventBroker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for ch := range b.subscribers {
+		delete(b.subscribers, ch)
+		close(ch)
+	}
+}
+
+// publish tells subscribers and webhooks what happened to a fact. Failing
+// to do so doesn't fail the change, which has already been made.
+func (s *Service) publish(ctx context.Context, typ string, id int64, f *Fact) {
+	e := Event{
+		Type:   typ,
+		Time:   s.now().UTC(),
+		FactID: id,
+		Fact:   f,
+	}
+
+	e, err := s.events.publish(ctx, e)
+	if err != nil {
+		log.With("event_type", typ, "fact_id", id, "err", err).Error("")
+		return
+	}
+
+	if s.webhooks != nil {
+		s.enqueueWebhooks(e)
+	}
+}
+
+// Close ends the streams of events and WebSocket connections being
+// served, which would otherwise keep the server from shutting down.
+func (s *Service) Close() {
+	s.closeOnce.Do(func() { close(s.done) })
+	s.events.close()
+}
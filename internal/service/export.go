@@ -0,0 +1,241 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// maxImportLine is the longest line accepted by ImportHandler.
+const maxImportLine = 1 << 20
+
+// FactRecord is a fact along with what the server knows about it, as
+// it's written to and read from an export.
+type FactRecord struct {
+	Fact
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
+	ServedCount  int64      `json:"served_count,omitempty"`
+	LastServedAt *time.Time `json:"last_served_at,omitempty"`
+}
+
+// importRecord is a line of an import. Its weight is optional, so that a
+// fact without one gets the default like it would when created.
+type importRecord struct {
+	FactRecord
+	Weight *float64 `json:"weight"`
+}
+
+// ExportOptions narrows down what is exported.
+type ExportOptions struct {
+	// IncludeDeleted exports soft-deleted facts too.
+	IncludeDeleted bool
+}
+
+// ImportRejection describes a line of an import that wasn't imported,
+// for example because its ID conflicts with an existing fact.
+type ImportRejection struct {
+	Line  int    `json:"line"`
+	ID    int64  `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+// ExportHandler streams every fact as newline-delimited JSON, one
+// FactRecord per line, in order of ID.
+//
+// Soft-deleted facts are left out unless ?deleted=true, and the serving
+// statistics are left out unless ?metadata=true.
+func (s *Service) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	includeDeleted, err := parseBoolParam(query.Get("deleted"))
+	if err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("deleted must be a boolean"))
+		return
+	}
+
+	metadata, err := parseBoolParam(query.Get("metadata"))
+	if err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("metadata must be a boolean"))
+		return
+	}
+
+	logger := log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+	)
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", cacheControlNoStore)
+
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+
+	// Nothing is written until the first fact arrives, so a failure to
+	// even start the export can still be reported properly.
+	started := false
+	n := 0
+	err = s.facts.ExportFacts(r.Context(), ExportOptions{IncludeDeleted: includeDeleted}, func(rec FactRecord) error {
+		started = true
+
+		if !metadata {
+			rec.ServedCount = 0
+			rec.LastServedAt = nil
+		}
+
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+
+		n++
+		if flusher != nil && n%100 == 0 {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		logger.With("err", err).Error("")
+		if !started {
+			s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+		}
+		return
+	}
+
+	if !started {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// ImportHandler creates facts from newline-delimited JSON in the format
+// written by ExportHandler. Each line is imported on its own, so lines
+// that can't be imported are reported without stopping the others.
+//
+// With ?preserve=true, facts keep their IDs, timestamps, versions and
+// statistics, and a fact whose ID is already taken is reported as a
+// conflict. Otherwise facts are created anew and deleted facts are
+// skipped.
+func (s *Service) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	preserve, err := parseBoolParam(r.URL.Query().Get("preserve"))
+	if err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("preserve must be a boolean"))
+		return
+	}
+
+	logger := log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+	)
+
+	var (
+		imported, skipped int
+		rejected          = []ImportRejection{}
+		line              int
+	)
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxImportLine)
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rec importRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			rejected = append(rejected, ImportRejection{Line: line, Error: "bad request"})
+			continue
+		}
+
+		if !preserve && rec.DeletedAt != nil {
+			skipped++
+			continue
+		}
+
+		if preserve && rec.ID < 1 {
+			rejected = append(rejected, ImportRejection{Line: line, Error: "id must be a positive integer"})
+			continue
+		}
+
+		f, err := factInput{
+			Content:   rec.Content,
+			Source:    rec.Source,
+			Citations: rec.Citations,
+			Weight:    rec.Weight,
+		}.fact()
+		if err != nil {
+			rejected = append(rejected, ImportRejection{Line: line, ID: rec.ID, Error: err.Error()})
+			continue
+		}
+
+		if preserve {
+			rec.FactRecord.Weight = f.Weight
+			err = s.importFact(r.Context(), rec.FactRecord)
+		} else {
+			f, err = s.facts.CreateFact(r.Context(), f)
+			if err == nil {
+				s.publish(r.Context(), EventFactCreated, f.ID, &f)
+			}
+		}
+
+		switch {
+		case errors.Is(err, ErrConflict):
+			rejected = append(rejected, ImportRejection{Line: line, ID: rec.ID, Error: "a fact with this id already exists"})
+		case err != nil:
+			logger.With("line", line, "err", err).Error("")
+			s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+			return
+		default:
+			imported++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		s.RespondJSON(w, http.StatusBadRequest, map[string]any{
+			"error":    "line " + strconv.Itoa(line+1) + " could not be read",
+			"imported": imported,
+			"skipped":  skipped,
+			"rejected": rejected,
+		})
+		return
+	}
+
+	s.RespondJSON(w, http.StatusOK, map[string]any{
+		"imported": imported,
+		"skipped":  skipped,
+		"rejected": rejected,
+	})
+}
+
+func (s *Service) importFact(ctx context.Context, rec FactRecord) error {
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = s.now()
+	}
+	if rec.UpdatedAt.IsZero() {
+		rec.UpdatedAt = rec.CreatedAt
+	}
+	if rec.Version < 1 {
+		rec.Version = 1
+	}
+
+	if err := s.facts.ImportFact(ctx, rec); err != nil {
+		return err
+	}
+
+	if rec.DeletedAt == nil {
+		s.publish(ctx, EventFactCreated, rec.ID, &rec.Fact)
+	}
+	return nil
+}
+
+func parseBoolParam(v string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
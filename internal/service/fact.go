@@ -1,14 +1,90 @@
 package service
 
 import (
+	"errors"
 	"time"
 )
 
 type Fact struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	DeletedAt time.Time `json:"-"`
-	Content   string    `json:"content"`
-	Source    string    `json:"source"`
+	ID        int64      `json:"id" xml:"id,attr" yaml:"id"`
+	CreatedAt time.Time  `json:"created_at" xml:"created_at" yaml:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" xml:"updated_at" yaml:"updated_at"`
+	DeletedAt time.Time  `json:"-" xml:"-" yaml:"-"`
+	Content   string     `json:"content" xml:"content" yaml:"content"`
+	Source    string     `json:"source" xml:"source" yaml:"source"`
+	Citations []Citation `json:"citations" xml:"citations>citation" yaml:"citations"`
+	Weight    float64    `json:"weight" xml:"weight" yaml:"weight"`
+	Version   int64      `json:"version" xml:"version" yaml:"version"`
+}
+
+// FactUpdate holds the changes to make to a fact. Nil fields are left
+// as they are.
+type FactUpdate struct {
+	Content   *string
+	Source    *string
+	Citations *[]Citation
+	Weight    *float64
+
+	// Version, if not zero, is the version the fact must be at for the
+	// update to happen.
+	Version int64
+}
+
+// FactsQuery narrows down which facts are listed.
+type FactsQuery struct {
+	// SourceStatus, if set, only matches facts with at least one
+	// citation whose link is in that state.
+	SourceStatus string
+
+	// After, if set, only matches facts with a greater ID.
+	After int64
+
+	// Search, if set, only matches facts whose content contains it,
+	// ignoring the case of ASCII letters.
+	Search string
+
+	// Limit, if set, caps the number of facts returned.
+	Limit int
+}
+
+// factInput is what clients send to create a fact.
+type factInput struct {
+	Content   string     `json:"content"`
+	Source    string     `json:"source"`
+	Citations []Citation `json:"citations"`
+	Weight    *float64   `json:"weight"`
+}
+
+// fact validates in and turns it into a fact ready to be created.
+func (in factInput) fact() (Fact, error) {
+	if in.Content == "" {
+		return Fact{}, errors.New("content field missing or blank")
+	}
+
+	if err := validateCitations(in.Citations); err != nil {
+		return Fact{}, err
+	}
+
+	weight := 1.0
+	if in.Weight != nil {
+		weight = *in.Weight
+	}
+
+	if weight < 0 {
+		return Fact{}, errors.New("weight must not be negative")
+	}
+
+	// Older clients only know about the free-text source, so give
+	// them something to show when a fact is cited structurally.
+	source := in.Source
+	if source == "" && len(in.Citations) > 0 {
+		source = in.Citations[0].URL
+	}
+
+	return Fact{
+		Content:   in.Content,
+		Source:    source,
+		Citations: in.Citations,
+		Weight:    weight,
+	}, nil
 }
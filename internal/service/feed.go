@@ -0,0 +1,244 @@
+package service
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+const (
+	// defaultFeedSize is how many facts a feed lists unless asked for
+	// more or fewer.
+	defaultFeedSize = 20
+
+	// maxFeedSize is the most facts a feed lists.
+	maxFeedSize = 100
+)
+
+// WithBaseURL sets the URL the server is reachable at, such as
+// "https://factoid.example.com", for links in responses that need to be
+// absolute. Without one, it's worked out from each request's Host.
+func WithBaseURL(u string) optionFunc {
+	return func(s *Service) { s.baseURL = strings.TrimSuffix(u, "/") }
+}
+
+// base returns the URL the server is reachable at.
+func (s *Service) base(r *http.Request) string {
+	if s.baseURL != "" {
+		return s.baseURL
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// factGUID identifies a fact in feeds. It only depends on the fact's ID,
+// so it doesn't change if the fact is edited or the server moves.
+func factGUID(id int64) string {
+	return "urn:factoid:fact:" + strconv.FormatInt(id, 10)
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          atomLink  `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Published string      `xml:"published"`
+	Link      atomLink    `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// RSSHandler lists the most recently added facts as an RSS 2.0 feed.
+func (s *Service) RSSHandler(w http.ResponseWriter, r *http.Request) {
+	s.respondFeed(w, r, "rss", "application/rss+xml", func(base string, facts []Fact, modified time.Time) any {
+		feed := rssFeed{
+			Version: "2.0",
+			Atom:    "http://www.w3.org/2005/Atom",
+			Channel: rssChannel{
+				Title:       "factoid",
+				Link:        base + "/",
+				Description: "Recently added facts.",
+				Self:        atomLink{Rel: "self", Type: "application/rss+xml", Href: base + r.URL.RequestURI()},
+			},
+		}
+		if !modified.IsZero() {
+			feed.Channel.LastBuildDate = modified.UTC().Format(time.RFC1123Z)
+		}
+
+		for _, f := range facts {
+			feed.Channel.Items = append(feed.Channel.Items, rssItem{
+				Title:       fmt.Sprintf("Fact %d", f.ID),
+				Link:        fmt.Sprintf("%s/fact/%d", base, f.ID),
+				Description: f.Content,
+				GUID:        rssGUID{Value: factGUID(f.ID)},
+				PubDate:     f.CreatedAt.UTC().Format(time.RFC1123Z),
+			})
+		}
+		return feed
+	})
+}
+
+// AtomHandler lists the most recently added facts as an Atom feed.
+func (s *Service) AtomHandler(w http.ResponseWriter, r *http.Request) {
+	s.respondFeed(w, r, "atom", "application/atom+xml", func(base string, facts []Fact, modified time.Time) any {
+		// Atom feeds must say when they were last updated, even when
+		// they're empty.
+		updated := modified
+		if updated.IsZero() {
+			updated = time.Unix(0, 0)
+		}
+
+		feed := atomFeed{
+			ID:      "urn:factoid:facts",
+			Title:   "factoid",
+			Updated: updated.UTC().Format(time.RFC3339),
+			Author:  atomAuthor{Name: "factoid"},
+			Links: []atomLink{
+				{Rel: "self", Type: "application/atom+xml", Href: base + r.URL.RequestURI()},
+				{Rel: "alternate", Href: base + "/"},
+			},
+		}
+
+		for _, f := range facts {
+			feed.Entries = append(feed.Entries, atomEntry{
+				ID:        factGUID(f.ID),
+				Title:     fmt.Sprintf("Fact %d", f.ID),
+				Updated:   f.UpdatedAt.UTC().Format(time.RFC3339),
+				Published: f.CreatedAt.UTC().Format(time.RFC3339),
+				Link:      atomLink{Rel: "alternate", Href: fmt.Sprintf("%s/fact/%d", base, f.ID)},
+				Content:   atomContent{Type: "text", Value: f.Content},
+			})
+		}
+		return feed
+	})
+}
+
+// respondFeed writes the feed build makes of the most recent facts, or
+// only the headers if the client already has it.
+func (s *Service) respondFeed(w http.ResponseWriter, r *http.Request, format, mediaType string, build func(base string, facts []Fact, modified time.Time) any) {
+	limit := defaultFeedSize
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxFeedSize {
+			s.RespondErrorJSON(w, http.StatusBadRequest, fmt.Errorf("limit must be an integer between 1 and %d", maxFeedSize))
+			return
+		}
+		limit = n
+	}
+
+	logger := log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+	)
+
+	facts, err := s.facts.RecentFacts(r.Context(), limit)
+	if err != nil {
+		logger.With("err", err).Error("")
+		s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+
+	// A fact that drops out of the feed because it was deleted changes
+	// the feed too.
+	modified, err := s.facts.FactsLastModified(r.Context())
+	if err != nil {
+		logger.With("err", err).Error("")
+		s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+	tag := etag(factsVersion(facts), format)
+
+	h := w.Header()
+	h.Set("Cache-Control", cacheControlRevalidate)
+	h.Set("ETag", tag)
+	if !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if notModified(r, tag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Type", contentType(mediaType))
+	w.WriteHeader(http.StatusOK)
+	if err := writeXML(w, build(s.base(r), facts, modified)); err != nil {
+		logger.With("err", err).Error("")
+	}
+}
+
+func writeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
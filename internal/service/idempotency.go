@@ -0,0 +1,194 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// idempotencyFinishTimeout bounds storing or releasing a key once the
+// request has been handled.
+const idempotencyFinishTimeout = 5 * time.Second
+
+// IdempotentResponse is the response to a request made with an
+// Idempotency-Key header, kept so that retries get the same answer.
+type IdempotentResponse struct {
+	Key string
+
+	// Fingerprint identifies the request the key was first used with.
+	Fingerprint string
+
+	// Status is zero while the first request is still being handled.
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey claims key for a request with the given
+	// fingerprint, forgetting keys claimed before expiredBefore. If the
+	// key is already claimed, reserved is false and the response stored
+	// for it is returned.
+	ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, expiredBefore time.Time) (rsp IdempotentResponse, reserved bool, err error)
+
+	// CompleteIdempotencyKey stores the response to a reserved key.
+	CompleteIdempotencyKey(ctx context.Context, rsp IdempotentResponse) error
+
+	// ReleaseIdempotencyKey forgets key so it can be used again.
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
+
+// WithIdempotencyStore keeps the responses to create requests made with
+// an Idempotency-Key header in store for ttl. Without a store the header
+// is ignored.
+func WithIdempotencyStore(store IdempotencyStore, ttl time.Duration) optionFunc {
+	return func(s *Service) {
+		s.idempotency = store
+		s.idempotencyTTL = ttl
+	}
+}
+
+// idempotent makes retries of a request with the same Idempotency-Key
+// header get the response to the first request instead of repeating it.
+// Server errors aren't kept, so a request that failed that way can be
+// retried for real.
+func (s *Service) idempotent(next http.Handler) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if s.idempotency == nil || key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("Idempotency-Key must be at most 255 characters"))
+			return
+		}
+
+		logger := log.With(
+			"request_uri", r.RequestURI,
+			"http_method", r.Method,
+			"idempotency_key", key,
+		)
+
+		// The body is kept in memory to fingerprint it, so it's held to
+		// the largest any endpoint taking an Idempotency-Key accepts.
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBatchBody))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			s.RespondErrorJSON(w, http.StatusRequestEntityTooLarge, errBodyTooLarge)
+			return
+		}
+		if err != nil {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("bad request"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := r.Context()
+		fingerprint := requestFingerprint(r, body)
+
+		stored, reserved, err := s.idempotency.ReserveIdempotencyKey(ctx, key, fingerprint, s.now().Add(-s.idempotencyTTL))
+		if err != nil {
+			logger.With("err", err).Error("")
+			s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+			return
+		}
+
+		if !reserved {
+			switch {
+			case stored.Fingerprint != fingerprint:
+				s.RespondErrorJSON(w, http.StatusUnprocessableEntity, errors.New("Idempotency-Key was already used for a different request"))
+			case stored.Status == 0:
+				s.RespondErrorJSON(w, http.StatusConflict, errors.New("a request with this Idempotency-Key is still in progress"))
+			default:
+				for k, v := range stored.Header {
+					w.Header()[k] = v
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.Status)
+				w.Write(stored.Body)
+			}
+			return
+		}
+
+		// The key is stored or released even if the client has gone away,
+		// which is when it's most likely to retry. Otherwise the key would
+		// stay reserved, and every retry refused, until it expires.
+		finish := func(fn func(ctx context.Context) error) {
+			ctx, cancel := context.WithTimeout(context.Background(), idempotencyFinishTimeout)
+			defer cancel()
+
+			if err := fn(ctx); err != nil {
+				logger.With("err", err).Error("")
+			}
+		}
+		release := func(ctx context.Context) error {
+			return s.idempotency.ReleaseIdempotencyKey(ctx, key)
+		}
+
+		defer func() {
+			if p := recover(); p != nil {
+				finish(release)
+				panic(p)
+			}
+		}()
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		if rec.status >= http.StatusInternalServerError {
+			finish(release)
+			return
+		}
+
+		finish(func(ctx context.Context) error {
+			return s.idempotency.CompleteIdempotencyKey(ctx, IdempotentResponse{
+				Key:         key,
+				Fingerprint: fingerprint,
+				Status:      rec.status,
+				Header:      w.Header().Clone(),
+				Body:        rec.body.Bytes(),
+			})
+		})
+	})
+}
+
+// requestFingerprint tells requests apart by what they ask for.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes a response through while keeping a copy.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -3,11 +3,18 @@ package service
 import (
 	"errors"
 	"net/http"
+
+	"github.com/julienschmidt/httprouter"
 )
 
+// isPrivileged reports whether r is authorized to make changes.
+func (s *Service) isPrivileged(r *http.Request) bool {
+	return s.auth == "" || r.Header.Get("Authorization") == s.auth
+}
+
 func (s *Service) privileged(next http.Handler) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if s.auth != "" && r.Header.Get("Authorization") != s.auth {
+		if !s.isPrivileged(r) {
 			s.RespondErrorJSON(w, http.StatusForbidden, errors.New("forbidden"))
 			return
 		}
@@ -15,3 +22,18 @@ func (s *Service) privileged(next http.Handler) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// exactly serves a path with a colon in it, such as /v1/facts:batch, only
+// when it was asked for exactly. httprouter reads the colon as the start
+// of a parameter, so every path that starts the same way is routed here
+// too, and is turned away before any other middleware sees it.
+func (s *Service) exactly(param string, next http.Handler) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if httprouter.ParamsFromContext(r.Context()).ByName(param) != ":"+param {
+			http.NotFound(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
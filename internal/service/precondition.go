@@ -0,0 +1,74 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ifMatchVersions returns the versions of fact id that the client is
+// willing to change according to its If-Match header. No versions means
+// any version will do. If the header only names other facts or weak
+// tags, nothing can match and ErrVersionMismatch is returned.
+func ifMatchVersions(r *http.Request, id int64) ([]int64, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil, nil
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, nil
+		}
+
+		// If-Match requires strong comparison, so weak tags and
+		// anything that isn't quoted never match.
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		tag = tag[1 : len(tag)-1]
+
+		// Any representation of the fact will do.
+		tag, _, _ = strings.Cut(tag, ".")
+
+		idPart, versionPart, ok := strings.Cut(tag, "-")
+		if !ok || idPart != strconv.FormatInt(id, 10) {
+			continue
+		}
+
+		version, err := strconv.ParseInt(versionPart, 10, 64)
+		if err != nil || version < 1 {
+			continue
+		}
+
+		versions = append(versions, version)
+	}
+
+	if len(versions) == 0 {
+		return nil, ErrVersionMismatch
+	}
+	return versions, nil
+}
+
+// writeIfMatch calls write once for each version the client is willing
+// to change until one of them is current. Each call is a single
+// compare-and-swap, so a concurrent writer can't sneak in between the
+// check and the write. With no versions, write is called once with 0,
+// meaning any version.
+func writeIfMatch(versions []int64, write func(version int64) error) error {
+	if len(versions) == 0 {
+		return write(0)
+	}
+
+	err := ErrVersionMismatch
+	for _, version := range versions {
+		err = write(version)
+		if !errors.Is(err, ErrVersionMismatch) {
+			return err
+		}
+	}
+	return err
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+const (
+	StrategyUniform     = "uniform"
+	StrategyWeighted    = "weighted"
+	StrategyFavorRecent = "favor-recent"
+	StrategyFavorUnseen = "favor-unseen"
+)
+
+const (
+	maxRandomCount = 100
+
+	// Sessions that haven't been used for this long are forgotten.
+	sessionIdleTimeout = 7 * 24 * time.Hour
+)
+
+// RandomOptions narrows down how a random fact is chosen.
+type RandomOptions struct {
+	// Rand is the source of randomness. If nil, a shared source is used.
+	Rand *mathrand.Rand
+
+	// Exclude lists the IDs of facts that must not be chosen.
+	Exclude []int64
+
+	// Session, if set, excludes every fact the session has been shown.
+	Session string
+
+	// Strategy decides how likely each fact is to be chosen. Every
+	// strategy other than StrategyUniform scales the odds by the fact's
+	// weight; StrategyFavorRecent also favors newer facts, and
+	// StrategyFavorUnseen favors facts that have been served less often.
+	Strategy string
+
+	// Now is when StrategyFavorRecent measures the age of facts from. If
+	// zero, the current time is used.
+	Now time.Time
+}
+
+// RandomHandler serves one or more random facts.
+func (s *Service) RandomHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var opts RandomOptions
+
+	if seed := query.Get("seed"); seed != "" {
+		opts.Rand = mathrand.New(mathrand.NewSource(parseSeed(seed)))
+	}
+
+	if exclude := query.Get("exclude"); exclude != "" {
+		for _, field := range strings.Split(exclude, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(field), 10, 64)
+			if err != nil {
+				s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("exclude must be a comma-separated list of integers"))
+				return
+			}
+			opts.Exclude = append(opts.Exclude, id)
+		}
+	}
+
+	switch strategy := query.Get("strategy"); strategy {
+	case "", StrategyUniform, StrategyWeighted, StrategyFavorRecent, StrategyFavorUnseen:
+		opts.Strategy = strategy
+	default:
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("strategy must be one of 'uniform', 'weighted', 'favor-recent' or 'favor-unseen'"))
+		return
+	}
+
+	count := 1
+	if c := query.Get("count"); c != "" {
+		var err error
+		count, err = strconv.Atoi(c)
+		if err != nil || count < 1 || count > maxRandomCount {
+			s.RespondErrorJSON(w, http.StatusBadRequest, fmt.Errorf("count must be an integer between 1 and %d", maxRandomCount))
+			return
+		}
+	}
+
+	ctx := r.Context()
+
+	logger := log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+	)
+
+	switch session := query.Get("session"); {
+	case session == "new":
+		token, err := newSessionToken()
+		if err != nil {
+			logger.With("err", err).Error("")
+			s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+			return
+		}
+		opts.Session = token
+
+		// Opportunistically tidy up while handing out a new session.
+		if err := s.facts.PruneSessions(ctx, s.now().Add(-sessionIdleTimeout)); err != nil {
+			logger.With("err", err).Error("")
+		}
+	case session != "":
+		if !isSessionToken(session) {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("session must be 'new' or a token issued by the server"))
+			return
+		}
+		opts.Session = session
+	}
+
+	facts, err := s.randomFacts(ctx, count, opts)
+	if err != nil {
+		status := http.StatusNotFound
+		if !errors.Is(err, ErrNotFound) {
+			logger.With("err", err).Error("")
+
+			status = http.StatusInternalServerError
+			err = errors.New("internal error")
+		}
+		s.RespondErrorJSON(w, status, err)
+		return
+	}
+
+	for _, f := range facts {
+		if err := s.facts.MarkServed(ctx, f.ID); err != nil {
+			logger.With("err", err).Error("")
+		}
+	}
+
+	rsp := Response{
+		Envelope:     map[string]any{"fact": facts[0]},
+		Facts:        facts,
+		Single:       !query.Has("count"),
+		CacheControl: cacheControlNoStore,
+	}
+	if !rsp.Single {
+		rsp.Envelope = map[string]any{"facts": facts}
+	}
+	if opts.Session != "" {
+		rsp.Envelope["session"] = opts.Session
+	}
+
+	s.RespondFacts(w, r, http.StatusOK, rsp)
+}
+
+// randomFacts returns up to count distinct random facts. A session that
+// has been shown every fact starts over, so its facts only repeat once
+// all of them have been seen.
+func (s *Service) randomFacts(ctx context.Context, count int, opts RandomOptions) ([]Fact, error) {
+	if opts.Now.IsZero() {
+		opts.Now = s.now()
+	}
+
+	var facts []Fact
+	restarted := false
+
+	for len(facts) < count {
+		f, err := s.facts.RandomFact(ctx, opts)
+		if errors.Is(err, ErrNotFound) && opts.Session != "" && !restarted {
+			if err := s.facts.ResetSession(ctx, opts.Session); err != nil {
+				return nil, err
+			}
+			restarted = true
+			continue
+		}
+		if errors.Is(err, ErrNotFound) && len(facts) > 0 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.Session != "" {
+			if err := s.facts.MarkSeen(ctx, opts.Session, f.ID); err != nil {
+				return nil, err
+			}
+		}
+
+		facts = append(facts, f)
+		opts.Exclude = append(opts.Exclude, f.ID)
+	}
+
+	return facts, nil
+}
+
+// parseSeed accepts any string as a seed, so clients can use whatever
+// they have handy, like a username or a date.
+func parseSeed(seed string) int64 {
+	if n, err := strconv.ParseInt(seed, 10, 64); err == nil {
+		return n
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(seed))
+	return int64(h.Sum64())
+}
+
+func newSessionToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func isSessionToken(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == 16
+}
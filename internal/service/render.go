@@ -0,0 +1,314 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	log "golang.org/x/exp/slog"
+	"gopkg.in/yaml.v3"
+)
+
+// Response is what a handler has to say about one or more facts.
+type Response struct {
+	// Envelope is the object a JSON response is made of, for example
+	// {"fact": ...} or {"facts": [...]}.
+	Envelope map[string]any
+
+	// Facts holds the facts found in Envelope.
+	Facts []Fact
+
+	// Single is set if the response is about exactly one fact rather
+	// than a list of them.
+	Single bool
+
+	// Version identifies the state of Facts and, together with the
+	// representation, makes up the response's ETag. Responses without a
+	// version can't be revalidated.
+	Version string
+
+	// LastModified is sent as the Last-Modified header unless it's zero.
+	LastModified time.Time
+
+	// CacheControl is sent as the Cache-Control header unless it's empty.
+	CacheControl string
+}
+
+// Renderer writes a Response in a particular representation.
+type Renderer interface {
+	Render(w io.Writer, rsp Response) error
+}
+
+type RendererFunc func(w io.Writer, rsp Response) error
+
+func (f RendererFunc) Render(w io.Writer, rsp Response) error {
+	return f(w, rsp)
+}
+
+type renderer struct {
+	format     string
+	mediaTypes []string
+	Renderer
+}
+
+// WithRenderer registers r for the given media types, the first of
+// which is used as the response's Content-Type. Clients may also ask for
+// it with ?format=format. A renderer registered for a format that's
+// already known replaces it.
+func WithRenderer(format string, r Renderer, mediaTypes ...string) optionFunc {
+	return func(s *Service) { s.registerRenderer(format, r, mediaTypes...) }
+}
+
+func (s *Service) registerRenderer(format string, r Renderer, mediaTypes ...string) {
+	for i, existing := range s.renderers {
+		if existing.format == format {
+			s.renderers[i] = renderer{format, mediaTypes, r}
+			return
+		}
+	}
+	s.renderers = append(s.renderers, renderer{format, mediaTypes, r})
+}
+
+func (s *Service) registerDefaultRenderers() {
+	s.registerRenderer("json", RendererFunc(renderJSON), "application/json")
+	s.registerRenderer("text", RendererFunc(renderText), "text/plain")
+	s.registerRenderer("html", RendererFunc(renderHTML), "text/html")
+	s.registerRenderer("csv", RendererFunc(renderCSV), "text/csv")
+	s.registerRenderer("yaml", RendererFunc(renderYAML), "application/yaml", "application/x-yaml", "text/yaml")
+	s.registerRenderer("xml", RendererFunc(renderXML), "application/xml", "text/xml")
+}
+
+// RespondFacts writes rsp in the representation the client asked for,
+// either with ?format= or the Accept header, falling back to JSON. If the
+// client already has an up to date copy, only the headers are sent.
+func (s *Service) RespondFacts(w http.ResponseWriter, r *http.Request, code int, rsp Response) {
+	rend, ok := s.negotiate(r)
+	if !ok {
+		s.RespondErrorJSON(w, http.StatusNotAcceptable, errors.New("not acceptable"))
+		return
+	}
+
+	h := w.Header()
+	h.Add("Vary", "Accept")
+	if rsp.CacheControl != "" {
+		h.Set("Cache-Control", rsp.CacheControl)
+	}
+	if !rsp.LastModified.IsZero() {
+		h.Set("Last-Modified", rsp.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if rsp.Version != "" {
+		tag := etag(rsp.Version, rend.format)
+		h.Set("ETag", tag)
+
+		if code == http.StatusOK && notModified(r, tag, rsp.LastModified) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	h.Set("Content-Type", contentType(rend.mediaTypes[0]))
+	w.WriteHeader(code)
+	if err := rend.Render(w, rsp); err != nil {
+		log.With("format", rend.format, "err", err).Error("")
+	}
+}
+
+// negotiate picks the renderer to use for r.
+func (s *Service) negotiate(r *http.Request) (renderer, bool) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		for _, rend := range s.renderers {
+			if rend.format == format {
+				return rend, true
+			}
+		}
+		return renderer{}, false
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return s.renderers[0], true
+	}
+
+	for _, mediaRange := range parseAccept(accept) {
+		for _, rend := range s.renderers {
+			for _, mediaType := range rend.mediaTypes {
+				if mediaRange.matches(mediaType) {
+					return rend, true
+				}
+			}
+		}
+	}
+
+	return renderer{}, false
+}
+
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+func (m mediaRange) matches(mediaType string) bool {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+	return (m.typ == "*" || m.typ == typ) && (m.subtype == "*" || m.subtype == subtype)
+}
+
+// parseAccept returns the acceptable media ranges in an Accept header,
+// most preferred first.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, field := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(field))
+		if err != nil {
+			continue
+		}
+
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		if q > 0 {
+			ranges = append(ranges, mediaRange{typ, subtype, q})
+		}
+	}
+
+	// More specific ranges win ties, so text/* beats */*.
+	specificity := func(m mediaRange) int {
+		n := 0
+		if m.typ != "*" {
+			n++
+		}
+		if m.subtype != "*" {
+			n++
+		}
+		return n
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].q != ranges[j].q {
+			return ranges[i].q > ranges[j].q
+		}
+		return specificity(ranges[i]) > specificity(ranges[j])
+	})
+
+	return ranges
+}
+
+func contentType(mediaType string) string {
+	if strings.HasPrefix(mediaType, "text/") {
+		return mediaType + "; charset=utf-8"
+	}
+	return mediaType
+}
+
+func renderJSON(w io.Writer, rsp Response) error {
+	return json.NewEncoder(w).Encode(rsp.Envelope)
+}
+
+// renderText writes one fact per line as "content — source".
+func renderText(w io.Writer, rsp Response) error {
+	for _, f := range rsp.Facts {
+		line := f.Content
+		if f.Source != "" {
+			line += " — " + f.Source
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var htmlTemplate = template.Must(template.New("facts").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{if .Single}}Fact #{{(index .Facts 0).ID}}{{else}}Facts{{end}}</title>
+</head>
+<body>
+{{range .Facts}}<figure id="fact-{{.ID}}">
+<blockquote>{{.Content}}</blockquote>
+{{if .Source}}<figcaption>— {{if .Citations}}<cite><a href="{{(index .Citations 0).URL}}">{{.Source}}</a></cite>{{else}}<cite>{{.Source}}</cite>{{end}}</figcaption>
+{{end}}</figure>
+{{end}}</body>
+</html>
+`))
+
+func renderHTML(w io.Writer, rsp Response) error {
+	return htmlTemplate.Execute(w, rsp)
+}
+
+func renderCSV(w io.Writer, rsp Response) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "created_at", "updated_at", "content", "source", "weight"}); err != nil {
+		return err
+	}
+
+	for _, f := range rsp.Facts {
+		err := cw.Write([]string{
+			strconv.FormatInt(f.ID, 10),
+			f.CreatedAt.Format(time.RFC3339),
+			f.UpdatedAt.Format(time.RFC3339),
+			f.Content,
+			f.Source,
+			strconv.FormatFloat(f.Weight, 'g', -1, 64),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func renderYAML(w io.Writer, rsp Response) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(rsp.Envelope); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func renderXML(w io.Writer, rsp Response) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	var err error
+	if rsp.Single {
+		err = enc.EncodeElement(rsp.Facts[0], xml.StartElement{Name: xml.Name{Local: "fact"}})
+	} else {
+		err = enc.Encode(struct {
+			XMLName xml.Name `xml:"facts"`
+			Facts   []Fact   `xml:"fact"`
+		}{Facts: rsp.Facts})
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
@@ -6,13 +6,85 @@ import (
 	"github.com/julienschmidt/httprouter"
 )
 
+// Endpoint is a method and path pattern served by Routes, in
+// httprouter's syntax.
+type Endpoint struct {
+	Method string
+	Path   string
+}
+
+type route struct {
+	Endpoint
+	handler http.Handler
+}
+
+func (s *Service) routes() []route {
+	return []route{
+		{Endpoint{http.MethodGet, "/v1/facts"}, http.HandlerFunc(s.FactsHandler)},
+		{Endpoint{http.MethodGet, "/v1/facts.rss"}, http.HandlerFunc(s.RSSHandler)},
+		{Endpoint{http.MethodGet, "/v1/facts.atom"}, http.HandlerFunc(s.AtomHandler)},
+		{Endpoint{http.MethodGet, "/v1/facts/stream"}, http.HandlerFunc(s.StreamHandler)},
+		{Endpoint{http.MethodGet, "/v1/facts/ws"}, http.HandlerFunc(s.WebSocketHandler)},
+		{Endpoint{http.MethodGet, "/v1/fact/:id"}, http.HandlerFunc(s.FactHandler)},
+		{Endpoint{http.MethodPost, "/v1/facts"}, s.privileged(s.idempotent(http.HandlerFunc(s.FactsHandler)))},
+		{Endpoint{http.MethodPost, "/v1/facts:batch"}, s.exactly("batch", s.privileged(s.idempotent(http.HandlerFunc(s.BatchHandler))))},
+		{Endpoint{http.MethodPatch, "/v1/fact/:id"}, s.privileged(http.HandlerFunc(s.FactHandler))},
+		{Endpoint{http.MethodDelete, "/v1/fact/:id"}, s.privileged(http.HandlerFunc(s.FactHandler))},
+		{Endpoint{http.MethodPut, "/v1/today/:date"}, s.privileged(http.HandlerFunc(s.PinHandler))},
+		{Endpoint{http.MethodDelete, "/v1/today/:date"}, s.privileged(http.HandlerFunc(s.PinHandler))},
+		{Endpoint{http.MethodGet, "/v1/export"}, s.privileged(http.HandlerFunc(s.ExportHandler))},
+		{Endpoint{http.MethodPost, "/v1/import"}, s.privileged(http.HandlerFunc(s.ImportHandler))},
+		{Endpoint{http.MethodGet, "/v1/webhooks"}, s.privileged(http.HandlerFunc(s.WebhooksHandler))},
+		{Endpoint{http.MethodPost, "/v1/webhooks"}, s.privileged(http.HandlerFunc(s.WebhooksHandler))},
+		{Endpoint{http.MethodGet, "/v1/webhook/:id"}, s.privileged(http.HandlerFunc(s.WebhookHandler))},
+		{Endpoint{http.MethodPatch, "/v1/webhook/:id"}, s.privileged(http.HandlerFunc(s.WebhookHandler))},
+		{Endpoint{http.MethodDelete, "/v1/webhook/:id"}, s.privileged(http.HandlerFunc(s.WebhookHandler))},
+		{Endpoint{http.MethodGet, "/v1/webhook/:id/deliveries"}, s.privileged(http.HandlerFunc(s.WebhookDeliveriesHandler))},
+		{Endpoint{http.MethodPost, "/v1/webhook/:id/deliveries/:delivery/redeliver"}, s.privileged(http.HandlerFunc(s.RedeliverHandler))},
+		{Endpoint{http.MethodPost, "/v1/slack/command"}, http.HandlerFunc(s.SlackHandler)},
+		{Endpoint{http.MethodPost, "/v1/discord/interactions"}, http.HandlerFunc(s.DiscordHandler)},
+		{Endpoint{http.MethodGet, "/"}, http.HandlerFunc(s.HomeHandler)},
+		{Endpoint{http.MethodGet, "/fact/:id"}, http.HandlerFunc(s.FactPageHandler)},
+		{Endpoint{http.MethodGet, "/facts"}, http.HandlerFunc(s.BrowsePageHandler)},
+		{Endpoint{http.MethodGet, "/search"}, http.HandlerFunc(s.SearchPageHandler)},
+		{Endpoint{http.MethodGet, "/static/*file"}, http.HandlerFunc(s.StaticHandler)},
+		{Endpoint{http.MethodGet, "/admin/login"}, http.HandlerFunc(s.AdminLoginHandler)},
+		{Endpoint{http.MethodPost, "/admin/login"}, http.HandlerFunc(s.AdminLoginHandler)},
+		{Endpoint{http.MethodPost, "/admin/logout"}, s.adminOnly(http.HandlerFunc(s.AdminLogoutHandler))},
+		{Endpoint{http.MethodGet, "/admin"}, s.adminOnly(http.HandlerFunc(s.AdminFactsHandler))},
+		{Endpoint{http.MethodGet, "/admin/new"}, s.adminOnly(http.HandlerFunc(s.AdminNewHandler))},
+		{Endpoint{http.MethodPost, "/admin/new"}, s.adminOnly(http.HandlerFunc(s.AdminNewHandler))},
+		{Endpoint{http.MethodGet, "/admin/fact/:id"}, s.adminOnly(http.HandlerFunc(s.AdminFactHandler))},
+		{Endpoint{http.MethodPost, "/admin/fact/:id"}, s.adminOnly(http.HandlerFunc(s.AdminFactHandler))},
+		{Endpoint{http.MethodPost, "/admin/fact/:id/delete"}, s.adminOnly(http.HandlerFunc(s.AdminDeleteHandler))},
+		{Endpoint{http.MethodPost, "/admin/fact/:id/restore"}, s.adminOnly(http.HandlerFunc(s.AdminRestoreHandler))},
+		{Endpoint{http.MethodGet, "/admin/deleted"}, s.adminOnly(http.HandlerFunc(s.AdminDeletedHandler))},
+		{Endpoint{http.MethodGet, "/admin/import"}, s.adminOnly(http.HandlerFunc(s.AdminImportHandler))},
+		{Endpoint{http.MethodPost, "/admin/import"}, s.adminOnly(http.HandlerFunc(s.AdminImportHandler))},
+		{Endpoint{http.MethodGet, "/admin/activity"}, s.adminOnly(http.HandlerFunc(s.AdminActivityHandler))},
+		{Endpoint{http.MethodGet, "/openapi.json"}, http.HandlerFunc(s.OpenAPIHandler)},
+		{Endpoint{http.MethodGet, "/docs/*file"}, http.HandlerFunc(s.DocsHandler)},
+	}
+}
+
 func (s *Service) Routes() *httprouter.Router {
 	mux := httprouter.New()
 
-	mux.HandlerFunc(http.MethodGet, "/v1/facts", s.FactsHandler)
-	mux.HandlerFunc(http.MethodGet, "/v1/fact/:id", s.FactHandler)
-	mux.HandlerFunc(http.MethodPost, "/v1/facts", s.privileged(http.HandlerFunc(s.FactsHandler)))
-	mux.HandlerFunc(http.MethodDelete, "/v1/fact/:id", s.privileged(http.HandlerFunc(s.FactHandler)))
+	for _, rt := range s.routes() {
+		mux.Handler(rt.Method, rt.Path, rt.handler)
+	}
 
 	return mux
 }
+
+// Endpoints lists what Routes serves, so it can be checked against the
+// OpenAPI document.
+func (s *Service) Endpoints() []Endpoint {
+	routes := s.routes()
+
+	endpoints := make([]Endpoint, len(routes))
+	for i, rt := range routes {
+		endpoints[i] = rt.Endpoint
+	}
+	return endpoints
+}
@@ -2,15 +2,23 @@ package service
 
 import (
 	"context"
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	log "golang.org/x/exp/slog"
 )
 
+// maxPageSize is the most facts that can be asked for at once.
+const maxPageSize = 1000
+
 type Option interface {
 	Apply(s *Service)
 }
@@ -25,21 +33,88 @@ func WithAuthorizer(auth string) optionFunc {
 	return func(s *Service) { s.auth = auth }
 }
 
+// WithClock makes the service tell the time with now instead of
+// time.Now.
+func WithClock(now func() time.Time) optionFunc {
+	return func(s *Service) { s.now = now }
+}
+
 type FactRepo interface {
-	Facts(context.Context) ([]Fact, error)
+	Facts(context.Context, FactsQuery) ([]Fact, error)
+	RecentFacts(ctx context.Context, limit int) ([]Fact, error)
+	FactsLastModified(ctx context.Context) (time.Time, error)
 	Fact(ctx context.Context, id int64) (Fact, error)
-	RandomFact(context.Context) (Fact, error)
-	CreateFact(ctx context.Context, contents, source string) (Fact, error)
-	DeleteFact(ctx context.Context, id int64) error
+	RandomFact(context.Context, RandomOptions) (Fact, error)
+	CreateFact(ctx context.Context, f Fact) (Fact, error)
+	CreateFacts(ctx context.Context, facts []Fact) ([]Fact, error)
+	UpdateFact(ctx context.Context, id int64, u FactUpdate) (Fact, error)
+	DeleteFact(ctx context.Context, id, version int64) error
+	RestoreFact(ctx context.Context, id int64) (Fact, error)
+	DeletedFacts(ctx context.Context, limit int) ([]Fact, error)
+
+	ExportFacts(ctx context.Context, opts ExportOptions, fn func(FactRecord) error) error
+	ImportFact(ctx context.Context, rec FactRecord) error
+
+	MarkServed(ctx context.Context, id int64) error
+	MarkSeen(ctx context.Context, session string, id int64) error
+	ResetSession(ctx context.Context, session string) error
+	PruneSessions(ctx context.Context, idleSince time.Time) error
+
+	FactIDsCreatedBefore(ctx context.Context, t time.Time) ([]int64, error)
+	DailyFact(ctx context.Context, day string) (DailyFact, error)
+	DailyFactIDs(ctx context.Context, from, to string) ([]int64, error)
+	SaveDailyFact(ctx context.Context, day string, id int64) error
+	PinDailyFact(ctx context.Context, day string, id int64) error
+	DeleteDailyFact(ctx context.Context, day string) error
 }
 
 type Service struct {
-	facts FactRepo
-	auth  string
+	facts     FactRepo
+	auth      string
+	now       func() time.Time
+	renderers []renderer
+
+	dailyRepeatWindow int
+
+	idempotency    IdempotencyStore
+	idempotencyTTL time.Duration
+
+	events            *eventBroker
+	heartbeatInterval time.Duration
+
+	webhooks WebhookStore
+
+	slackSecret string
+	slackUsers  map[string]bool
+
+	discordKey ed25519.PublicKey
+
+	baseURL string
+
+	adminMu       sync.Mutex
+	adminSessions map[string]adminSession
+
+	maxWebSockets int
+	webSockets    atomic.Int64
+
+	// done is closed by Close.
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 func New(f FactRepo, opts ...Option) *Service {
-	s := &Service{facts: f}
+	s := &Service{
+		facts:             f,
+		now:               time.Now,
+		dailyRepeatWindow: 30,
+		idempotencyTTL:    24 * time.Hour,
+		events:            newEventBroker(NewMemoryEventLog(1000)),
+		heartbeatInterval: 15 * time.Second,
+		maxWebSockets:     100,
+		adminSessions:     make(map[string]adminSession),
+		done:              make(chan struct{}),
+	}
+	s.registerDefaultRenderers()
 	for _, opt := range opts {
 		opt.Apply(s)
 	}
@@ -49,21 +124,68 @@ func New(f FactRepo, opts ...Option) *Service {
 func (s *Service) FactsHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodGet:
-		facts, err := s.facts.Facts(context.Background())
+		var query FactsQuery
+
+		switch status := r.URL.Query().Get("source_status"); status {
+		case "", SourceStatusOK, SourceStatusBroken, SourceStatusUnchecked:
+			query.SourceStatus = status
+		default:
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("source_status must be one of 'ok', 'broken' or 'unchecked'"))
+			return
+		}
+
+		if limit := r.URL.Query().Get("limit"); limit != "" {
+			n, err := strconv.Atoi(limit)
+			if err != nil || n < 1 || n > maxPageSize {
+				s.RespondErrorJSON(w, http.StatusBadRequest, fmt.Errorf("limit must be an integer between 1 and %d", maxPageSize))
+				return
+			}
+			query.Limit = n
+		}
+
+		if after := r.URL.Query().Get("after"); after != "" {
+			id, err := strconv.ParseInt(after, 10, 64)
+			if err != nil || id < 0 {
+				s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("after must be a cursor returned as next"))
+				return
+			}
+			query.After = id
+		}
+
+		facts, err := s.facts.Facts(context.Background(), query)
 		if err != nil {
 			log.Error("", "err", err)
 			s.RespondErrorJSON(w, http.StatusInternalServerError, err)
 			return
 		}
 
-		s.RespondJSON(w, http.StatusOK, map[string]any{"facts": facts})
+		// Deleting a fact changes the list as much as adding one does,
+		// so the list is as new as the newest change to any fact.
+		modified, err := s.facts.FactsLastModified(r.Context())
+		if err != nil {
+			log.Error("", "err", err)
+			s.RespondErrorJSON(w, http.StatusInternalServerError, err)
+			return
+		}
 
-	case http.MethodPost:
-		var body struct {
-			Content string `json:"content"`
-			Source  string `json:"source"`
+		envelope := map[string]any{"facts": facts}
+
+		// A full page may be followed by another one.
+		if query.Limit > 0 && len(facts) == query.Limit {
+			envelope["next"] = strconv.FormatInt(facts[len(facts)-1].ID, 10)
 		}
 
+		s.RespondFacts(w, r, http.StatusOK, Response{
+			Envelope:     envelope,
+			Facts:        facts,
+			Version:      factsVersion(facts),
+			LastModified: modified,
+			CacheControl: cacheControlRevalidate,
+		})
+
+	case http.MethodPost:
+		var body factInput
+
 		err := json.NewDecoder(r.Body).Decode(&body)
 		if err != nil {
 			log.With("err", err).Error("")
@@ -71,22 +193,26 @@ func (s *Service) FactsHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if body.Content == "" {
-			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("content field missing or blank"))
+		fact, err := body.fact()
+		if err != nil {
+			s.RespondErrorJSON(w, http.StatusBadRequest, err)
 			return
 		}
 
-		f, err := s.facts.CreateFact(context.Background(), body.Content, body.Source)
+		f, err := s.facts.CreateFact(context.Background(), fact)
 		if err != nil {
 			log.With(
-				"create_fact_content", body.Content,
-				"create_fact_source", body.Source,
+				"create_fact_content", fact.Content,
+				"create_fact_source", fact.Source,
 				"err", err,
 			).Error("")
 			s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
 			return
 		}
 
+		s.publish(r.Context(), EventFactCreated, f.ID, &f)
+
+		w.Header().Set("ETag", etag(factVersion(f), "json"))
 		s.RespondJSON(w, http.StatusCreated, map[string]any{"fact": f})
 		return
 	}
@@ -108,15 +234,13 @@ func (s *Service) FactHandler(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
-		getFact := func(ctx context.Context) (Fact, error) {
-			return s.facts.Fact(ctx, id)
-		}
-
-		if idParam == "rand" {
-			getFact = func(ctx context.Context) (Fact, error) {
-				return s.facts.RandomFact(ctx)
-			}
-			err = nil
+		switch idParam {
+		case "today":
+			s.TodayHandler(w, r)
+			return
+		case "rand":
+			s.RandomHandler(w, r)
+			return
 		}
 
 		if err != nil {
@@ -124,7 +248,7 @@ func (s *Service) FactHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		f, err := getFact(context.Background())
+		f, err := s.facts.Fact(context.Background(), id)
 		if err != nil {
 			status := http.StatusNotFound
 			if !errors.Is(err, ErrNotFound) {
@@ -137,23 +261,75 @@ func (s *Service) FactHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		s.RespondJSON(w, http.StatusOK, map[string]any{"fact": f})
+		s.RespondFacts(w, r, http.StatusOK, Response{
+			Envelope:     map[string]any{"fact": f},
+			Facts:        []Fact{f},
+			Single:       true,
+			Version:      factVersion(f),
+			LastModified: f.UpdatedAt,
+			CacheControl: cacheControlRevalidate,
+		})
 
-	case http.MethodDelete:
+	case http.MethodPatch:
 		// This belongs to the strconv.ParseInt call above the switch statement.
 		if err != nil {
 			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("id must be an integer"))
 			return
 		}
 
-		ctx := context.Background()
+		var body struct {
+			Content   *string     `json:"content"`
+			Source    *string     `json:"source"`
+			Citations *[]Citation `json:"citations"`
+			Weight    *float64    `json:"weight"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("bad request"))
+			return
+		}
+
+		if body.Content != nil && *body.Content == "" {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("content field must not be blank"))
+			return
+		}
+
+		if body.Citations != nil {
+			if err := validateCitations(*body.Citations); err != nil {
+				s.RespondErrorJSON(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		if body.Weight != nil && *body.Weight < 0 {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("weight must not be negative"))
+			return
+		}
 
-		// Since s.facts.SoftDeleteFact doesn't return a count of
-		// rows affected.
-		_, err = s.facts.Fact(ctx, id)
+		versions, err := ifMatchVersions(r, id)
+		if err != nil {
+			s.RespondErrorJSON(w, http.StatusPreconditionFailed, err)
+			return
+		}
+
+		var f Fact
+		err = writeIfMatch(versions, func(version int64) error {
+			var err error
+			f, err = s.facts.UpdateFact(r.Context(), id, FactUpdate{
+				Content:   body.Content,
+				Source:    body.Source,
+				Citations: body.Citations,
+				Weight:    body.Weight,
+				Version:   version,
+			})
+			return err
+		})
 		if err != nil {
 			status := http.StatusNotFound
-			if !errors.Is(err, ErrNotFound) {
+			switch {
+			case errors.Is(err, ErrVersionMismatch):
+				status = http.StatusPreconditionFailed
+			case !errors.Is(err, ErrNotFound):
 				logger.With("err", err).Error("")
 
 				status = http.StatusInternalServerError
@@ -163,15 +339,44 @@ func (s *Service) FactHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		err = s.facts.DeleteFact(context.Background(), id)
+		s.publish(r.Context(), EventFactUpdated, f.ID, &f)
+
+		w.Header().Set("ETag", etag(factVersion(f), "json"))
+		s.RespondJSON(w, http.StatusOK, map[string]any{"fact": f})
+
+	case http.MethodDelete:
+		// This belongs to the strconv.ParseInt call above the switch statement.
 		if err != nil {
-			logger.With("err", err).Error("")
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("id must be an integer"))
+			return
+		}
 
-			err = errors.New("internal error")
-			s.RespondErrorJSON(w, http.StatusInternalServerError, err)
+		versions, err := ifMatchVersions(r, id)
+		if err != nil {
+			s.RespondErrorJSON(w, http.StatusPreconditionFailed, err)
 			return
 		}
 
+		err = writeIfMatch(versions, func(version int64) error {
+			return s.facts.DeleteFact(r.Context(), id, version)
+		})
+		if err != nil {
+			status := http.StatusNotFound
+			switch {
+			case errors.Is(err, ErrVersionMismatch):
+				status = http.StatusPreconditionFailed
+			case !errors.Is(err, ErrNotFound):
+				logger.With("err", err).Error("")
+
+				status = http.StatusInternalServerError
+				err = errors.New("internal error")
+			}
+			s.RespondErrorJSON(w, status, err)
+			return
+		}
+
+		s.publish(r.Context(), EventFactDeleted, id, nil)
+
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
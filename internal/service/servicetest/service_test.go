@@ -1,20 +1,37 @@
 package servicetest
 
 import (
+	"bufio"
 	"context"
+	"crypto/ed25519"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
+	"github.com/gorilla/websocket"
 	_ "modernc.org/sqlite"
 
 	sqliterepo "github.com/connorkuehl/factoid/internal/repo/sqlite"
 	"github.com/connorkuehl/factoid/internal/service"
+	"github.com/connorkuehl/factoid/internal/webhook"
 )
 
 func newTestDB(t *testing.T, facts ...service.Fact) (*sqliterepo.Repo, func()) {
@@ -31,7 +48,13 @@ func newTestDB(t *testing.T, facts ...service.Fact) (*sqliterepo.Repo, func()) {
 	repo := sqliterepo.NewRepo(db)
 
 	for _, fact := range facts {
-		_, err := repo.CreateFact(context.TODO(), fact.Content, fact.Source)
+		// Mirror the service, which gives facts a weight of 1 unless
+		// told otherwise.
+		if fact.Weight == 0 {
+			fact.Weight = 1
+		}
+
+		_, err := repo.CreateFact(context.TODO(), fact)
 		if err != nil {
 			db.Close()
 			t.Fatal(err)
@@ -41,6 +64,77 @@ func newTestDB(t *testing.T, facts ...service.Fact) (*sqliterepo.Repo, func()) {
 	return repo, func() { db.Close() }
 }
 
+// oldSchema is the schema of a database made before facts had weights.
+const oldSchema = `
+CREATE TABLE facts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	deleted_at TIMESTAMP DEFAULT NULL,
+	content TEXT NOT NULL,
+	source TEXT
+);
+
+INSERT INTO facts (content, source) VALUES ('an old fact', 'an old source');
+`
+
+func TestMigrate(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(oldSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	// Migrating is safe to repeat.
+	for i := 0; i < 2; i++ {
+		if err := sqliterepo.Migrate(context.TODO(), db); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		weight      float64
+		servedCount int64
+	)
+	err = db.QueryRow("SELECT weight, served_count FROM facts WHERE last_served_at IS NULL").Scan(&weight, &servedCount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if weight != 1 || servedCount != 0 {
+		t.Errorf("want weight 1 and served count 0, got weight %v and served count %d", weight, servedCount)
+	}
+
+	// Old facts can be changed only at their current version, like any
+	// other.
+	r := sqliterepo.NewRepo(db)
+
+	// Old facts are picked by weight like any other.
+	if f, err := r.RandomFact(context.TODO(), service.RandomOptions{Strategy: service.StrategyWeighted}); err != nil || f.ID != 1 {
+		t.Errorf("want fact 1, got fact %d and error %v", f.ID, err)
+	}
+
+	f, err := r.Fact(context.TODO(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Version != 1 {
+		t.Errorf("want version 1, got version %d", f.Version)
+	}
+
+	content := "an updated fact"
+	if _, err := r.UpdateFact(context.TODO(), 1, service.FactUpdate{Content: &content, Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.UpdateFact(context.TODO(), 1, service.FactUpdate{Content: &content, Version: 1}); !errors.Is(err, service.ErrVersionMismatch) {
+		t.Errorf("want %v, got %v", service.ErrVersionMismatch, err)
+	}
+}
+
 func makeFactTuples(facts ...service.Fact) map[string]string {
 	m := make(map[string]string)
 	for _, fact := range facts {
@@ -152,6 +246,24 @@ func TestPostFactsInputValidation(t *testing.T) {
 			wantStatus: http.StatusBadRequest,
 			wantError:  "content field missing or blank",
 		},
+		{
+			name:       "citation without a URL",
+			inputJSON:  `{"content": "a fact", "citations": [{"title": "A book"}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "citations[0].url must be an absolute http or https URL",
+		},
+		{
+			name:       "citation with a relative URL",
+			inputJSON:  `{"content": "a fact", "citations": [{"url": "https://example.com"}, {"url": "/wiki/Octopus"}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "citations[1].url must be an absolute http or https URL",
+		},
+		{
+			name:       "citation with a malformed accessed date",
+			inputJSON:  `{"content": "a fact", "citations": [{"url": "https://example.com", "accessed": "last week"}]}`,
+			wantStatus: http.StatusBadRequest,
+			wantError:  "citations[0].accessed must be a date formatted as YYYY-MM-DD",
+		},
 	}
 
 	for _, tt := range tests {
@@ -246,11 +358,90 @@ func TestPostFacts(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if response.Fact != returned {
+	if !reflect.DeepEqual(response.Fact, returned) {
 		t.Fatalf("want %+v, got %+v", returned, response.Fact)
 	}
 }
 
+func TestPostFactsWithCitations(t *testing.T) {
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	svc := service.New(r)
+
+	ts := httptest.NewServer(svc.Routes())
+	defer ts.Close()
+
+	body := `{
+		"content": "Octopuses have three hearts",
+		"citations": [
+			{
+				"url": "https://example.com/octopus",
+				"title": "All about octopuses",
+				"author": "A. Marine Biologist",
+				"publisher": "Example Press",
+				"accessed": "2023-06-01",
+				"archive_url": "https://web.archive.org/web/2023/https://example.com/octopus"
+			},
+			{"url": "https://example.org/cephalopods"}
+		]
+	}`
+	rsp, err := ts.Client().Post(ts.URL+"/v1/facts", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusCreated {
+		t.Fatalf("want http %d, got http %d", http.StatusCreated, rsp.StatusCode)
+	}
+
+	var created struct {
+		Fact service.Fact `json:"fact"`
+	}
+	if err := json.NewDecoder(rsp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	wantCitations := []service.Citation{
+		{
+			URL:        "https://example.com/octopus",
+			Title:      "All about octopuses",
+			Author:     "A. Marine Biologist",
+			Publisher:  "Example Press",
+			Accessed:   "2023-06-01",
+			ArchiveURL: "https://web.archive.org/web/2023/https://example.com/octopus",
+		},
+		{URL: "https://example.org/cephalopods"},
+	}
+
+	// The legacy source field falls back to the first citation.
+	wantSource := "https://example.com/octopus"
+
+	rsp, err = ts.Client().Get(fmt.Sprintf("%s/v1/fact/%d", ts.URL, created.Fact.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	var fetched struct {
+		Fact service.Fact `json:"fact"`
+	}
+	if err := json.NewDecoder(rsp.Body).Decode(&fetched); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, got := range []service.Fact{created.Fact, fetched.Fact} {
+		if !reflect.DeepEqual(wantCitations, got.Citations) {
+			t.Errorf("want citations %+v, got citations %+v", wantCitations, got.Citations)
+		}
+
+		if got.Source != wantSource {
+			t.Errorf("want source %q, got source %q", wantSource, got.Source)
+		}
+	}
+}
+
 func TestGetFactInputValidation(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -490,6 +681,31 @@ func TestPrivilegedRoutesRequireAuthorization(t *testing.T) {
 			method: http.MethodPost,
 			uri:    "/v1/facts",
 		},
+		{
+			name:   "post /v1/facts:batch",
+			method: http.MethodPost,
+			uri:    "/v1/facts:batch",
+		},
+		{
+			name:   "get /v1/export",
+			method: http.MethodGet,
+			uri:    "/v1/export",
+		},
+		{
+			name:   "post /v1/import",
+			method: http.MethodPost,
+			uri:    "/v1/import",
+		},
+		{
+			name:   "get /v1/webhooks",
+			method: http.MethodGet,
+			uri:    "/v1/webhooks",
+		},
+		{
+			name:   "delete /v1/webhook/1",
+			method: http.MethodDelete,
+			uri:    "/v1/webhook/1",
+		},
 	}
 
 	for _, tt := range tests {
@@ -531,3 +747,4180 @@ func TestPrivilegedRoutesRequireAuthorization(t *testing.T) {
 		})
 	}
 }
+
+func TestFactOfTheDay(t *testing.T) {
+	r, cleanup := newTestDB(t,
+		service.Fact{Content: "1", Source: "Source 1"},
+		service.Fact{Content: "2", Source: "Source 2"},
+		service.Fact{Content: "3", Source: "Source 3"},
+	)
+	defer cleanup()
+
+	svc := service.New(r)
+
+	ts := httptest.NewServer(svc.Routes())
+	defer ts.Close()
+
+	getToday := func(t *testing.T, query string) service.Fact {
+		t.Helper()
+
+		rsp, err := ts.Client().Get(ts.URL + "/v1/fact/today" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("want http %d, got http %d", http.StatusOK, rsp.StatusCode)
+		}
+
+		var response struct {
+			Fact service.Fact `json:"fact"`
+		}
+		if err := json.NewDecoder(rsp.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Fact
+	}
+
+	do := func(t *testing.T, method, uri, body string, wantStatus int) {
+		t.Helper()
+
+		req, err := http.NewRequest(method, ts.URL+uri, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != wantStatus {
+			t.Fatalf("want http %d, got http %d", wantStatus, rsp.StatusCode)
+		}
+	}
+
+	t.Run("stable", func(t *testing.T) {
+		first := getToday(t, "")
+
+		if _, err := r.CreateFact(context.TODO(), service.Fact{Content: "4", Source: "Source 4"}); err != nil {
+			t.Fatal(err)
+		}
+
+		for i := 0; i < 3; i++ {
+			if got := getToday(t, ""); got.ID != first.ID {
+				t.Fatalf("want fact %d, got fact %d", first.ID, got.ID)
+			}
+		}
+	})
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format("2006-01-02")
+	dayAfter := time.Now().UTC().AddDate(0, 0, 2).Format("2006-01-02")
+
+	t.Run("pinned", func(t *testing.T) {
+		do(t, http.MethodPut, "/v1/today/"+tomorrow, `{"id": 2}`, http.StatusOK)
+
+		if got := getToday(t, "?date="+tomorrow); got.ID != 2 {
+			t.Fatalf("want pinned fact 2, got fact %d", got.ID)
+		}
+
+		do(t, http.MethodDelete, "/v1/today/"+tomorrow, "", http.StatusNoContent)
+	})
+
+	t.Run("no repeats within window", func(t *testing.T) {
+		natural := getToday(t, "?date="+dayAfter)
+
+		do(t, http.MethodPut, "/v1/today/"+tomorrow, fmt.Sprintf(`{"id": %d}`, natural.ID), http.StatusOK)
+		defer do(t, http.MethodDelete, "/v1/today/"+tomorrow, "", http.StatusNoContent)
+
+		if got := getToday(t, "?date="+dayAfter); got.ID == natural.ID {
+			t.Fatalf("fact %d repeated on consecutive days", got.ID)
+		}
+	})
+
+	t.Run("bad input", func(t *testing.T) {
+		do(t, http.MethodGet, "/v1/fact/today?date=tomorrow", "", http.StatusBadRequest)
+		do(t, http.MethodGet, "/v1/fact/today?tz=Mars/Olympus_Mons", "", http.StatusBadRequest)
+		do(t, http.MethodPut, "/v1/today/"+tomorrow, `{"id": 404}`, http.StatusNotFound)
+	})
+}
+
+func TestFactOfTheDayPastDates(t *testing.T) {
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	// The facts have to be older than the days asked about.
+	longAgo := time.Now().UTC().AddDate(0, -1, 0)
+	for i := int64(1); i <= 2; i++ {
+		if err := r.ImportFact(context.TODO(), service.FactRecord{Fact: service.Fact{
+			ID:        i,
+			Content:   fmt.Sprint(i),
+			Weight:    1,
+			Version:   1,
+			CreatedAt: longAgo,
+			UpdatedAt: longAgo,
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := httptest.NewServer(service.New(r, service.WithAuthorizer("secret")).Routes())
+	defer ts.Close()
+
+	get := func(t *testing.T, date, auth string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/fact/today?date="+date, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+
+		rsp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("want http %d, got http %d", http.StatusOK, rsp.StatusCode)
+		}
+	}
+
+	settled := func(t *testing.T, date string) bool {
+		t.Helper()
+
+		_, err := r.DailyFact(context.TODO(), date)
+		if err != nil && !errors.Is(err, service.ErrNotFound) {
+			t.Fatal(err)
+		}
+		return err == nil
+	}
+
+	today := time.Now().UTC().Format("2006-01-02")
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	lastWeek := time.Now().UTC().AddDate(0, 0, -7).Format("2006-01-02")
+
+	tests := []struct {
+		name        string
+		date        string
+		auth        string
+		wantSettled bool
+	}{
+		{name: "today", date: today, wantSettled: true},
+		{name: "past day", date: yesterday, wantSettled: false},
+		{name: "past day as admin", date: lastWeek, auth: "secret", wantSettled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			get(t, tt.date, tt.auth)
+
+			if got := settled(t, tt.date); got != tt.wantSettled {
+				t.Errorf("want settled %v, got %v", tt.wantSettled, got)
+			}
+		})
+	}
+}
+
+func TestFactOfTheDayTimeZones(t *testing.T) {
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	// At this moment it's still February 28th in Chicago. Fact 1 is from
+	// February 28th everywhere, and the rest are from February 28th in
+	// Chicago but March 1st in UTC.
+	now := time.Date(2023, time.March, 1, 2, 0, 0, 0, time.UTC)
+	created := []time.Time{time.Date(2023, time.February, 28, 12, 0, 0, 0, time.UTC)}
+	for i := 0; i < 5; i++ {
+		created = append(created, time.Date(2023, time.March, 1, 1, i, 0, 0, time.UTC))
+	}
+	for i, c := range created {
+		if err := r.ImportFact(context.TODO(), service.FactRecord{Fact: service.Fact{
+			ID:        int64(i + 1),
+			Content:   fmt.Sprint(i + 1),
+			Weight:    1,
+			Version:   1,
+			CreatedAt: c,
+			UpdatedAt: c,
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// With an authorizer, asking about a past date doesn't settle it.
+	ts := httptest.NewServer(service.New(r,
+		service.WithClock(func() time.Time { return now }),
+		service.WithAuthorizer("secret"),
+	).Routes())
+	defer ts.Close()
+
+	get := func(t *testing.T, query string) (string, service.Fact) {
+		t.Helper()
+
+		rsp, err := ts.Client().Get(ts.URL + "/v1/fact/today" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("want http %d, got http %d", http.StatusOK, rsp.StatusCode)
+		}
+
+		var response struct {
+			Date string       `json:"date"`
+			Fact service.Fact `json:"fact"`
+		}
+		if err := json.NewDecoder(rsp.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Date, response.Fact
+	}
+
+	_, inUTC := get(t, "?date=2023-02-28")
+	date, inChicago := get(t, "?tz=America/Chicago")
+
+	if date != "2023-02-28" {
+		t.Fatalf("want date 2023-02-28, got %s", date)
+	}
+	if inChicago.ID != inUTC.ID {
+		t.Errorf("want fact %d in Chicago, got fact %d", inUTC.ID, inChicago.ID)
+	}
+	if inChicago.ID != 1 {
+		t.Errorf("want fact 1, the only one from February 28th in UTC, got fact %d", inChicago.ID)
+	}
+}
+
+func TestRandomFacts(t *testing.T) {
+	var preexisting []service.Fact
+	for i := 1; i <= 5; i++ {
+		preexisting = append(preexisting, service.Fact{Content: fmt.Sprint(i), Source: "a unit test"})
+	}
+
+	r, cleanup := newTestDB(t, preexisting...)
+	defer cleanup()
+
+	svc := service.New(r)
+
+	ts := httptest.NewServer(svc.Routes())
+	defer ts.Close()
+
+	type response struct {
+		Fact    service.Fact   `json:"fact"`
+		Facts   []service.Fact `json:"facts"`
+		Session string         `json:"session"`
+		Error   string         `json:"error"`
+	}
+
+	get := func(t *testing.T, query string, wantStatus int) response {
+		t.Helper()
+
+		rsp, err := ts.Client().Get(ts.URL + "/v1/fact/rand" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != wantStatus {
+			t.Fatalf("want http %d, got http %d", wantStatus, rsp.StatusCode)
+		}
+
+		var got response
+		if err := json.NewDecoder(rsp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	ids := func(facts []service.Fact) []int64 {
+		var ids []int64
+		for _, f := range facts {
+			ids = append(ids, f.ID)
+		}
+		return ids
+	}
+
+	t.Run("seeded", func(t *testing.T) {
+		first := get(t, "?seed=octopus&count=3", http.StatusOK)
+		second := get(t, "?seed=octopus&count=3", http.StatusOK)
+
+		if !reflect.DeepEqual(ids(first.Facts), ids(second.Facts)) {
+			t.Fatalf("want the same facts for the same seed, got %v and %v",
+				ids(first.Facts), ids(second.Facts))
+		}
+	})
+
+	t.Run("exclude", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			got := get(t, "?exclude=1,2,3,4", http.StatusOK)
+			if got.Fact.ID != 5 {
+				t.Fatalf("want fact 5, got fact %d", got.Fact.ID)
+			}
+		}
+
+		get(t, "?exclude=1,2,3,4,5", http.StatusNotFound)
+		get(t, "?exclude=one", http.StatusBadRequest)
+	})
+
+	t.Run("count", func(t *testing.T) {
+		got := get(t, "?count=10", http.StatusOK)
+		if len(got.Facts) != 5 {
+			t.Fatalf("want all 5 facts, got %d", len(got.Facts))
+		}
+
+		seen := make(map[int64]bool)
+		for _, f := range got.Facts {
+			if seen[f.ID] {
+				t.Fatalf("fact %d returned twice", f.ID)
+			}
+			seen[f.ID] = true
+		}
+
+		get(t, "?count=0", http.StatusBadRequest)
+	})
+
+	t.Run("session", func(t *testing.T) {
+		got := get(t, "?session=new", http.StatusOK)
+		if got.Session == "" {
+			t.Fatal("want a session token, got none")
+		}
+
+		seen := map[int64]bool{got.Fact.ID: true}
+		for i := 0; i < 4; i++ {
+			got := get(t, "?session="+got.Session, http.StatusOK)
+			if seen[got.Fact.ID] {
+				t.Fatalf("fact %d repeated before every fact was seen", got.Fact.ID)
+			}
+			seen[got.Fact.ID] = true
+		}
+
+		// Every fact has been seen, so the session starts over.
+		get(t, "?session="+got.Session, http.StatusOK)
+
+		get(t, "?session=not-a-token", http.StatusBadRequest)
+	})
+}
+
+func TestWeightedRandomFacts(t *testing.T) {
+	r, cleanup := newTestDB(t,
+		service.Fact{Content: "best", Weight: 9},
+		service.Fact{Content: "fine", Weight: 1},
+		service.Fact{Content: "retired", Weight: 1},
+	)
+	defer cleanup()
+
+	svc := service.New(r)
+
+	ts := httptest.NewServer(svc.Routes())
+	defer ts.Close()
+
+	get := func(t *testing.T, query string, wantStatus int) service.Fact {
+		t.Helper()
+
+		rsp, err := ts.Client().Get(ts.URL + "/v1/fact/rand" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != wantStatus {
+			t.Fatalf("want http %d, got http %d", wantStatus, rsp.StatusCode)
+		}
+
+		var response struct {
+			Fact service.Fact `json:"fact"`
+		}
+		if err := json.NewDecoder(rsp.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Fact
+	}
+
+	// A weight of zero keeps a fact out of every strategy but uniform.
+	req, err := http.NewRequest(http.MethodPatch, ts.URL+"/v1/fact/3", strings.NewReader(`{"weight": 0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("want http %d, got http %d", http.StatusOK, rsp.StatusCode)
+	}
+
+	for _, strategy := range []string{
+		service.StrategyWeighted,
+		service.StrategyFavorRecent,
+		service.StrategyFavorUnseen,
+	} {
+		t.Run(strategy, func(t *testing.T) {
+			counts := make(map[string]int)
+			for i := 0; i < 200; i++ {
+				f := get(t, fmt.Sprintf("?strategy=%s&seed=%d", strategy, i), http.StatusOK)
+				counts[f.Content]++
+			}
+
+			if counts["retired"] != 0 {
+				t.Errorf("fact with zero weight was picked %d times", counts["retired"])
+			}
+
+			if strategy == service.StrategyWeighted && counts["best"] < 3*counts["fine"] {
+				t.Errorf("want the heavier fact picked far more often, got %v", counts)
+			}
+		})
+	}
+
+	t.Run("seeded", func(t *testing.T) {
+		first := get(t, "?strategy=weighted&seed=42", http.StatusOK)
+		for i := 0; i < 5; i++ {
+			if got := get(t, "?strategy=weighted&seed=42", http.StatusOK); got.ID != first.ID {
+				t.Fatalf("want fact %d for the same seed, got fact %d", first.ID, got.ID)
+			}
+		}
+	})
+
+	t.Run("exclude", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			if got := get(t, fmt.Sprintf("?strategy=weighted&exclude=1&seed=%d", i), http.StatusOK); got.Content != "fine" {
+				t.Fatalf("want the only fact left, got %q", got.Content)
+			}
+		}
+
+		get(t, "?strategy=weighted&exclude=1,2", http.StatusNotFound)
+	})
+
+	t.Run("unknown strategy", func(t *testing.T) {
+		get(t, "?strategy=best", http.StatusBadRequest)
+	})
+}
+
+func TestFavorRecentUsesClock(t *testing.T) {
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	now := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i, created := range []time.Time{now, now.AddDate(-100, 0, 0)} {
+		if err := r.ImportFact(context.TODO(), service.FactRecord{Fact: service.Fact{
+			ID:        int64(i + 1),
+			Content:   created.Format("2006"),
+			Weight:    1,
+			Version:   1,
+			CreatedAt: created,
+			UpdatedAt: created,
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// With an authorizer, asking about a past date doesn't settle it.
+	ts := httptest.NewServer(service.New(r,
+		service.WithClock(func() time.Time { return now }),
+		service.WithAuthorizer("secret"),
+	).Routes())
+	defer ts.Close()
+
+	// As of the service's clock one fact is brand new and the other a
+	// century old, so the old one hardly ever comes up.
+	counts := make(map[string]int)
+	for i := 0; i < 50; i++ {
+		rsp, err := ts.Client().Get(fmt.Sprintf("%s/v1/fact/rand?strategy=favor-recent&seed=%d", ts.URL, i))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var response struct {
+			Fact service.Fact `json:"fact"`
+		}
+		err = json.NewDecoder(rsp.Body).Decode(&response)
+		rsp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[response.Fact.Content]++
+	}
+
+	if counts["2000"] < 48 {
+		t.Errorf("want the new fact nearly every time, got %v", counts)
+	}
+}
+
+func TestContentNegotiation(t *testing.T) {
+	r, cleanup := newTestDB(t,
+		service.Fact{Content: "a fact", Source: "a source"},
+		service.Fact{Content: "another fact"},
+	)
+	defer cleanup()
+
+	ts := httptest.NewServer(service.New(r).Routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name            string
+		path            string
+		accept          string
+		wantStatus      int
+		wantContentType string
+		wantBody        []string
+	}{
+		{
+			name:            "default",
+			path:            "/v1/fact/1",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/json",
+			wantBody:        []string{`"content":"a fact"`},
+		},
+		{
+			name:            "plain text",
+			path:            "/v1/fact/1",
+			accept:          "text/plain",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        []string{"a fact — a source\n"},
+		},
+		{
+			name:            "plain text list",
+			path:            "/v1/facts",
+			accept:          "text/plain",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        []string{"a fact — a source\nanother fact\n"},
+		},
+		{
+			name:            "browser",
+			path:            "/v1/fact/1",
+			accept:          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBody:        []string{"<blockquote>a fact</blockquote>"},
+		},
+		{
+			name:            "csv",
+			path:            "/v1/facts",
+			accept:          "text/csv",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/csv; charset=utf-8",
+			wantBody:        []string{"id,created_at,updated_at,content,source,weight\n", ",a fact,a source,1\n", ",another fact,,1\n"},
+		},
+		{
+			name:            "yaml",
+			path:            "/v1/fact/1",
+			accept:          "application/yaml",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/yaml",
+			wantBody:        []string{"fact:\n", "  content: a fact\n"},
+		},
+		{
+			name:            "xml",
+			path:            "/v1/facts",
+			accept:          "application/xml",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/xml",
+			wantBody:        []string{"<facts>", "<content>a fact</content>", "<content>another fact</content>"},
+		},
+		{
+			name:            "format overrides accept",
+			path:            "/v1/fact/1?format=text",
+			accept:          "application/json",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        []string{"a fact — a source\n"},
+		},
+		{
+			name:            "quality values",
+			path:            "/v1/fact/1",
+			accept:          "application/json;q=0.5, text/plain",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        []string{"a fact — a source\n"},
+		},
+		{
+			name:            "not acceptable",
+			path:            "/v1/fact/1",
+			accept:          "image/png",
+			wantStatus:      http.StatusNotAcceptable,
+			wantContentType: "application/json",
+			wantBody:        []string{`"error":"not acceptable"`},
+		},
+		{
+			name:            "unknown format",
+			path:            "/v1/facts?format=pdf",
+			wantStatus:      http.StatusNotAcceptable,
+			wantContentType: "application/json",
+			wantBody:        []string{`"error":"not acceptable"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tt.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+
+			rsp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rsp.Body.Close()
+
+			if rsp.StatusCode != tt.wantStatus {
+				t.Fatalf("want http %d, got http %d", tt.wantStatus, rsp.StatusCode)
+			}
+
+			if got := rsp.Header.Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("want content type %q, got %q", tt.wantContentType, got)
+			}
+
+			body, err := io.ReadAll(rsp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range tt.wantBody {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("want body containing %q, got %q", want, body)
+				}
+			}
+		})
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	r, cleanup := newTestDB(t, service.Fact{Content: "a fact", Source: "a source"})
+	defer cleanup()
+
+	ts := httptest.NewServer(service.New(r).Routes())
+	defer ts.Close()
+
+	get := func(t *testing.T, path string, header http.Header, wantStatus int) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		rsp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+
+		if rsp.StatusCode != wantStatus {
+			t.Fatalf("want http %d, got http %d", wantStatus, rsp.StatusCode)
+		}
+		return rsp
+	}
+
+	for _, path := range []string{"/v1/fact/1", "/v1/facts"} {
+		t.Run(path, func(t *testing.T) {
+			rsp := get(t, path, nil, http.StatusOK)
+
+			tag := rsp.Header.Get("ETag")
+			if !strings.HasPrefix(tag, `"`) {
+				t.Fatalf("want a strong ETag, got %q", tag)
+			}
+
+			if got := rsp.Header.Get("Cache-Control"); got != "no-cache" {
+				t.Errorf("want Cache-Control %q, got %q", "no-cache", got)
+			}
+
+			modified := rsp.Header.Get("Last-Modified")
+			if modified == "" {
+				t.Fatal("want Last-Modified to be set")
+			}
+
+			rsp = get(t, path, http.Header{"If-None-Match": {tag}}, http.StatusNotModified)
+			if got := rsp.Header.Get("ETag"); got != tag {
+				t.Errorf("want ETag %q on 304, got %q", tag, got)
+			}
+
+			get(t, path, http.Header{"If-None-Match": {`"stale", W/` + tag}}, http.StatusNotModified)
+			get(t, path, http.Header{"If-None-Match": {`"stale"`}}, http.StatusOK)
+			get(t, path, http.Header{"If-Modified-Since": {modified}}, http.StatusNotModified)
+			get(t, path, http.Header{"If-Modified-Since": {"Mon, 02 Jan 2006 15:04:05 GMT"}}, http.StatusOK)
+
+			// If-None-Match takes precedence over If-Modified-Since.
+			get(t, path, http.Header{"If-None-Match": {`"stale"`}, "If-Modified-Since": {modified}}, http.StatusOK)
+
+			// Other representations have their own tags.
+			rsp = get(t, path, http.Header{"Accept": {"text/plain"}}, http.StatusOK)
+			if got := rsp.Header.Get("ETag"); got == tag {
+				t.Errorf("want a different ETag for text, got %q", got)
+			}
+		})
+	}
+
+	t.Run("list changes", func(t *testing.T) {
+		before := get(t, "/v1/facts", nil, http.StatusOK).Header.Get("ETag")
+
+		if _, err := r.CreateFact(context.TODO(), service.Fact{Content: "another fact", Weight: 1}); err != nil {
+			t.Fatal(err)
+		}
+
+		after := get(t, "/v1/facts", http.Header{"If-None-Match": {before}}, http.StatusOK).Header.Get("ETag")
+		if after == before {
+			t.Errorf("want ETag to change after adding a fact, got %q both times", after)
+		}
+	})
+
+	t.Run("rand", func(t *testing.T) {
+		rsp := get(t, "/v1/fact/rand", nil, http.StatusOK)
+		if got := rsp.Header.Get("Cache-Control"); got != "no-store" {
+			t.Errorf("want Cache-Control %q, got %q", "no-store", got)
+		}
+		if got := rsp.Header.Get("ETag"); got != "" {
+			t.Errorf("want no ETag, got %q", got)
+		}
+	})
+}
+
+func TestConditionalGetAfterDelete(t *testing.T) {
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	// The facts are older than the deletion, so the deletion is the
+	// newest change.
+	longAgo := time.Now().UTC().AddDate(0, -1, 0)
+	for i := int64(1); i <= 2; i++ {
+		if err := r.ImportFact(context.TODO(), service.FactRecord{Fact: service.Fact{
+			ID:        i,
+			Content:   fmt.Sprint(i),
+			Weight:    1,
+			Version:   1,
+			CreatedAt: longAgo,
+			UpdatedAt: longAgo,
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ts := httptest.NewServer(service.New(r).Routes())
+	defer ts.Close()
+
+	get := func(t *testing.T, path, modifiedSince string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if modifiedSince != "" {
+			req.Header.Set("If-Modified-Since", modifiedSince)
+		}
+
+		rsp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+		return rsp
+	}
+
+	paths := []string{"/v1/facts", "/v1/facts.rss", "/v1/facts.atom"}
+
+	modified := make(map[string]string)
+	for _, path := range paths {
+		modified[path] = get(t, path, "").Header.Get("Last-Modified")
+	}
+
+	if err := r.DeleteFact(context.TODO(), 2, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		t.Run(path, func(t *testing.T) {
+			if rsp := get(t, path, modified[path]); rsp.StatusCode != http.StatusOK {
+				t.Errorf("want http %d, got http %d", http.StatusOK, rsp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	r, cleanup := newTestDB(t,
+		service.Fact{Content: "a fact", Source: "a source"},
+		service.Fact{Content: "another fact", Source: "another source"},
+	)
+	defer cleanup()
+
+	ts := httptest.NewServer(service.New(r).Routes())
+	defer ts.Close()
+
+	do := func(t *testing.T, method, path, ifMatch, body string, wantStatus int) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+
+		rsp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+
+		if rsp.StatusCode != wantStatus {
+			t.Fatalf("want http %d, got http %d", wantStatus, rsp.StatusCode)
+		}
+		return rsp
+	}
+
+	original := do(t, http.MethodGet, "/v1/fact/1", "", "", http.StatusOK).Header.Get("ETag")
+	text := do(t, http.MethodGet, "/v1/fact/1?format=text", "", "", http.StatusOK).Header.Get("ETag")
+
+	updated := do(t, http.MethodPatch, "/v1/fact/1", original, `{"content": "an edited fact"}`, http.StatusOK).Header.Get("ETag")
+	if updated == original {
+		t.Fatalf("want ETag to change after an update, got %q both times", updated)
+	}
+
+	// Somebody else's edit based on the original is rejected.
+	do(t, http.MethodPatch, "/v1/fact/1", original, `{"content": "a clobbered fact"}`, http.StatusPreconditionFailed)
+	do(t, http.MethodPatch, "/v1/fact/1", text, `{"content": "a clobbered fact"}`, http.StatusPreconditionFailed)
+	do(t, http.MethodDelete, "/v1/fact/1", original, "", http.StatusPreconditionFailed)
+
+	f, err := r.Fact(context.TODO(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "an edited fact"; f.Content != want {
+		t.Errorf("want content %q, got content %q", want, f.Content)
+	}
+
+	// Tags are compared strongly, and must belong to the fact.
+	do(t, http.MethodPatch, "/v1/fact/1", "W/"+updated, `{"weight": 2}`, http.StatusPreconditionFailed)
+	do(t, http.MethodDelete, "/v1/fact/2", updated, "", http.StatusPreconditionFailed)
+
+	// Any of the listed tags will do.
+	do(t, http.MethodPatch, "/v1/fact/1", original+", "+updated, `{"weight": 2}`, http.StatusOK)
+	do(t, http.MethodPatch, "/v1/fact/2", "*", `{"weight": 2}`, http.StatusOK)
+
+	current := do(t, http.MethodGet, "/v1/fact/1", "", "", http.StatusOK).Header.Get("ETag")
+	do(t, http.MethodDelete, "/v1/fact/1", current, "", http.StatusNoContent)
+	do(t, http.MethodDelete, "/v1/fact/1", current, "", http.StatusNotFound)
+	do(t, http.MethodPatch, "/v1/fact/1", current, `{"weight": 2}`, http.StatusNotFound)
+}
+
+func TestBatchCreate(t *testing.T) {
+	type result struct {
+		Index  int           `json:"index"`
+		Status int           `json:"status"`
+		Fact   *service.Fact `json:"fact"`
+		Error  string        `json:"error"`
+	}
+
+	type response struct {
+		Created int      `json:"created"`
+		Failed  int      `json:"failed"`
+		Results []result `json:"results"`
+		Error   string   `json:"error"`
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		body         string
+		wantStatus   int
+		wantCreated  int
+		wantStatuses []int
+		wantContent  []string
+	}{
+		{
+			name:         "json array",
+			path:         "/v1/facts:batch",
+			body:         `[{"content": "one", "source": "a"}, {"content": "two", "weight": 2}]`,
+			wantStatus:   http.StatusCreated,
+			wantCreated:  2,
+			wantStatuses: []int{http.StatusCreated, http.StatusCreated},
+			wantContent:  []string{"one", "two"},
+		},
+		{
+			name:         "ndjson",
+			path:         "/v1/facts:batch",
+			body:         "{\"content\": \"one\"}\n{\"content\": \"two\"}\n\n{\"content\": \"three\"}\n",
+			wantStatus:   http.StatusCreated,
+			wantCreated:  3,
+			wantStatuses: []int{http.StatusCreated, http.StatusCreated, http.StatusCreated},
+			wantContent:  []string{"one", "two", "three"},
+		},
+		{
+			name:         "atomic with an invalid item",
+			path:         "/v1/facts:batch",
+			body:         `[{"content": "one"}, {"content": ""}, {"content": "three", "weight": "heavy"}]`,
+			wantStatus:   http.StatusBadRequest,
+			wantStatuses: []int{http.StatusFailedDependency, http.StatusBadRequest, http.StatusBadRequest},
+		},
+		{
+			name:         "best effort with an invalid item",
+			path:         "/v1/facts:batch?mode=best-effort",
+			body:         `[{"content": "one"}, {"content": "two", "weight": -1}, {"content": "three"}]`,
+			wantStatus:   http.StatusOK,
+			wantCreated:  2,
+			wantStatuses: []int{http.StatusCreated, http.StatusBadRequest, http.StatusCreated},
+			wantContent:  []string{"one", "three"},
+		},
+		{
+			name:       "malformed",
+			path:       "/v1/facts:batch",
+			body:       `[{"content": "one"}, {"content": `,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "empty",
+			path:       "/v1/facts:batch",
+			body:       `[]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown mode",
+			path:       "/v1/facts:batch?mode=yolo",
+			body:       `[{"content": "one"}]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "not a batch",
+			path:       "/v1/facts:bulk",
+			body:       `[{"content": "one"}]`,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, cleanup := newTestDB(t)
+			defer cleanup()
+
+			ts := httptest.NewServer(service.New(r).Routes())
+			defer ts.Close()
+
+			rsp, err := ts.Client().Post(ts.URL+tt.path, "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rsp.Body.Close()
+
+			if rsp.StatusCode != tt.wantStatus {
+				t.Fatalf("want http %d, got http %d", tt.wantStatus, rsp.StatusCode)
+			}
+
+			if rsp.StatusCode == http.StatusNotFound {
+				return
+			}
+
+			var response response
+			if err := json.NewDecoder(rsp.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+
+			if response.Created != tt.wantCreated {
+				t.Errorf("want %d created, got %d", tt.wantCreated, response.Created)
+			}
+
+			var gotStatuses []int
+			for i, result := range response.Results {
+				if result.Index != i {
+					t.Errorf("want result %d to have index %d, got %d", i, i, result.Index)
+				}
+				if result.Status == http.StatusCreated && result.Fact == nil {
+					t.Errorf("want result %d to hold the created fact", i)
+				}
+				gotStatuses = append(gotStatuses, result.Status)
+			}
+
+			if !reflect.DeepEqual(tt.wantStatuses, gotStatuses) {
+				t.Errorf("want statuses %v, got %v", tt.wantStatuses, gotStatuses)
+			}
+
+			facts, err := r.Facts(context.TODO(), service.FactsQuery{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var gotContent []string
+			for _, f := range facts {
+				gotContent = append(gotContent, f.Content)
+			}
+
+			if !reflect.DeepEqual(tt.wantContent, gotContent) {
+				t.Errorf("want facts %q, got facts %q", tt.wantContent, gotContent)
+			}
+		})
+	}
+}
+
+func TestBatchCreateUnknownPath(t *testing.T) {
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(service.New(r,
+		service.WithAuthorizer("secret"),
+		service.WithIdempotencyStore(r, time.Hour),
+	).Routes())
+	defer ts.Close()
+
+	// Paths that merely start with /v1/facts don't exist, whoever asks,
+	// and don't use up an Idempotency-Key.
+	for _, path := range []string{"/v1/facts:bulk", "/v1/factsbatch", "/v1/facts/batch"} {
+		for _, auth := range []string{"", "secret"} {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+path, strings.NewReader(`[{"content": "one"}]`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", auth)
+			req.Header.Set("Idempotency-Key", "key-1")
+
+			rsp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rsp.Body.Close()
+
+			if rsp.StatusCode != http.StatusNotFound {
+				t.Errorf("%s with authorization %q: want http %d, got http %d", path, auth, http.StatusNotFound, rsp.StatusCode)
+			}
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/facts:batch", strings.NewReader(`[{"content": "one"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "secret")
+	req.Header.Set("Idempotency-Key", "key-1")
+
+	rsp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusCreated {
+		t.Fatalf("want http %d, got http %d", http.StatusCreated, rsp.StatusCode)
+	}
+	if rsp.Header.Get("Idempotent-Replayed") != "" {
+		t.Error("want the key to be unused")
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	src, cleanup := newTestDB(t,
+		service.Fact{Content: "a fact", Source: "a source", Weight: 2},
+		service.Fact{Content: "a cited fact", Citations: []service.Citation{
+			{URL: "https://example.com/a", Title: "A"},
+			{URL: "https://example.com/b"},
+		}},
+		service.Fact{Content: "a deleted fact"},
+	)
+	defer cleanup()
+
+	if err := src.DeleteFact(context.TODO(), 3, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.MarkServed(context.TODO(), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	srcServer := httptest.NewServer(service.New(src).Routes())
+	defer srcServer.Close()
+
+	export := func(t *testing.T, ts *httptest.Server, query string) string {
+		t.Helper()
+
+		rsp, err := ts.Client().Get(ts.URL + "/v1/export" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("want http %d, got http %d", http.StatusOK, rsp.StatusCode)
+		}
+
+		if want, got := "application/x-ndjson", rsp.Header.Get("Content-Type"); want != got {
+			t.Errorf("want content type %q, got %q", want, got)
+		}
+
+		body, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+
+	type importResponse struct {
+		Imported int `json:"imported"`
+		Skipped  int `json:"skipped"`
+		Rejected []struct {
+			Line  int    `json:"line"`
+			ID    int64  `json:"id"`
+			Error string `json:"error"`
+		} `json:"rejected"`
+	}
+
+	importInto := func(t *testing.T, ts *httptest.Server, query, body string) importResponse {
+		t.Helper()
+
+		rsp, err := ts.Client().Post(ts.URL+"/v1/import"+query, "application/x-ndjson", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusOK {
+			t.Fatalf("want http %d, got http %d", http.StatusOK, rsp.StatusCode)
+		}
+
+		var response importResponse
+		if err := json.NewDecoder(rsp.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	t.Run("live facts only", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSpace(export(t, srcServer, "")), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("want 2 lines, got %q", lines)
+		}
+		if strings.Contains(lines[0], "served_count") {
+			t.Errorf("want no metadata, got %s", lines[0])
+		}
+	})
+
+	t.Run("preserve", func(t *testing.T) {
+		dump := export(t, srcServer, "?deleted=true&metadata=true")
+		if lines := strings.Split(strings.TrimSpace(dump), "\n"); len(lines) != 3 {
+			t.Fatalf("want 3 lines, got %q", lines)
+		}
+
+		dst, cleanup := newTestDB(t)
+		defer cleanup()
+
+		dstServer := httptest.NewServer(service.New(dst).Routes())
+		defer dstServer.Close()
+
+		response := importInto(t, dstServer, "?preserve=true", dump)
+		if response.Imported != 3 || len(response.Rejected) != 0 {
+			t.Fatalf("want 3 facts imported, got %+v", response)
+		}
+
+		if got := export(t, dstServer, "?deleted=true&metadata=true"); got != dump {
+			t.Errorf("want export\n%s\ngot\n%s", dump, got)
+		}
+
+		// Importing again conflicts on every ID.
+		response = importInto(t, dstServer, "?preserve=true", dump)
+		if response.Imported != 0 || len(response.Rejected) != 3 {
+			t.Fatalf("want every fact rejected, got %+v", response)
+		}
+		for i, rejected := range response.Rejected {
+			if rejected.Line != i+1 || rejected.ID != int64(i+1) {
+				t.Errorf("want line %d and id %d rejected, got %+v", i+1, i+1, rejected)
+			}
+		}
+
+		// New facts don't collide with imported IDs.
+		f, err := dst.CreateFact(context.TODO(), service.Fact{Content: "a new fact", Weight: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.ID != 4 {
+			t.Errorf("want id 4, got id %d", f.ID)
+		}
+	})
+
+	t.Run("without preserve", func(t *testing.T) {
+		dst, cleanup := newTestDB(t, service.Fact{Content: "already here"})
+		defer cleanup()
+
+		dstServer := httptest.NewServer(service.New(dst).Routes())
+		defer dstServer.Close()
+
+		dump := export(t, srcServer, "?deleted=true")
+		response := importInto(t, dstServer, "", dump+"not json\n"+`{"content": ""}`+"\n")
+		if response.Imported != 2 || response.Skipped != 1 || len(response.Rejected) != 2 {
+			t.Fatalf("want 2 imported, 1 skipped and 2 rejected, got %+v", response)
+		}
+
+		facts, err := dst.Facts(context.TODO(), service.FactsQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got []string
+		for _, f := range facts {
+			got = append(got, fmt.Sprintf("%d %s %d", f.ID, f.Content, len(f.Citations)))
+		}
+
+		want := []string{"1 already here 0", "2 a fact 0", "3 a cited fact 2"}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("want facts %q, got facts %q", want, got)
+		}
+	})
+	t.Run("default weight", func(t *testing.T) {
+		for _, query := range []string{"", "?preserve=true"} {
+			dst, cleanup := newTestDB(t)
+			defer cleanup()
+
+			dstServer := httptest.NewServer(service.New(dst).Routes())
+			defer dstServer.Close()
+
+			response := importInto(t, dstServer, query, `{"id": 1, "content": "a fact"}`+"\n")
+			if response.Imported != 1 || len(response.Rejected) != 0 {
+				t.Fatalf("%s: want 1 fact imported, got %+v", query, response)
+			}
+
+			f, err := dst.Fact(context.TODO(), 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Weight != 1 {
+				t.Errorf("%s: want weight 1, got %v", query, f.Weight)
+			}
+		}
+	})
+}
+
+func TestExportReleasesConnection(t *testing.T) {
+	// Like the server with an in-memory database, there is only one
+	// connection to go around.
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliterepo.Schema()); err != nil {
+		t.Fatal(err)
+	}
+
+	r := sqliterepo.NewRepo(db)
+	for i := 1; i <= 250; i++ {
+		if _, err := r.CreateFact(context.TODO(), service.Fact{Content: fmt.Sprint(i), Weight: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []int64
+	err = r.ExportFacts(context.TODO(), service.ExportOptions{}, func(rec service.FactRecord) error {
+		ids = append(ids, rec.ID)
+
+		// A slow client mustn't keep others waiting.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := r.Fact(ctx, 1)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 250 {
+		t.Fatalf("want 250 facts, got %d", len(ids))
+	}
+	for i, id := range ids {
+		if id != int64(i+1) {
+			t.Fatalf("want fact %d at %d, got fact %d", i+1, i, id)
+		}
+	}
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(service.New(r, service.WithIdempotencyStore(r, time.Hour)).Routes())
+	defer ts.Close()
+
+	post := func(t *testing.T, path, key, body string, wantStatus int) (*http.Response, string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+
+		rsp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != wantStatus {
+			t.Fatalf("want http %d, got http %d", wantStatus, rsp.StatusCode)
+		}
+
+		b, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rsp, string(b)
+	}
+
+	count := func(t *testing.T) int {
+		t.Helper()
+
+		facts, err := r.Facts(context.TODO(), service.FactsQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(facts)
+	}
+
+	first, firstBody := post(t, "/v1/facts", "key-1", `{"content": "a fact"}`, http.StatusCreated)
+	if first.Header.Get("Idempotent-Replayed") != "" {
+		t.Error("want the first response not to be a replay")
+	}
+
+	retry, retryBody := post(t, "/v1/facts", "key-1", `{"content": "a fact"}`, http.StatusCreated)
+	if retry.Header.Get("Idempotent-Replayed") != "true" {
+		t.Error("want the retry to be a replay")
+	}
+	if retryBody != firstBody {
+		t.Errorf("want body %q, got %q", firstBody, retryBody)
+	}
+	if want, got := first.Header.Get("ETag"), retry.Header.Get("ETag"); want != got {
+		t.Errorf("want ETag %q, got %q", want, got)
+	}
+
+	if got := count(t); got != 1 {
+		t.Fatalf("want 1 fact, got %d", got)
+	}
+
+	// Same key, different request.
+	post(t, "/v1/facts", "key-1", `{"content": "another fact"}`, http.StatusUnprocessableEntity)
+	post(t, "/v1/facts:batch", "key-1", `{"content": "a fact"}`, http.StatusUnprocessableEntity)
+
+	// Client errors are replayed too.
+	post(t, "/v1/facts", "key-2", `{"content": ""}`, http.StatusBadRequest)
+	post(t, "/v1/facts", "key-2", `{"content": ""}`, http.StatusBadRequest)
+
+	// Other keys, or none at all, create new facts.
+	post(t, "/v1/facts", "key-3", `{"content": "a fact"}`, http.StatusCreated)
+	post(t, "/v1/facts", "", `{"content": "a fact"}`, http.StatusCreated)
+	post(t, "/v1/facts", "", `{"content": "a fact"}`, http.StatusCreated)
+
+	post(t, "/v1/facts:batch", "key-4", `[{"content": "one"}, {"content": "two"}]`, http.StatusCreated)
+	post(t, "/v1/facts:batch", "key-4", `[{"content": "one"}, {"content": "two"}]`, http.StatusCreated)
+
+	if got := count(t); got != 6 {
+		t.Fatalf("want 6 facts, got %d", got)
+	}
+
+	post(t, "/v1/facts", strings.Repeat("k", 256), `{"content": "a fact"}`, http.StatusBadRequest)
+
+	// Bodies are only read up to a limit, with or without a key.
+	huge := `[{"content": "` + strings.Repeat("a", 32<<20) + `"}]`
+	post(t, "/v1/facts", "key-5", huge, http.StatusRequestEntityTooLarge)
+	post(t, "/v1/facts:batch", "key-6", huge, http.StatusRequestEntityTooLarge)
+	post(t, "/v1/facts:batch", "", huge, http.StatusRequestEntityTooLarge)
+
+	if got := count(t); got != 6 {
+		t.Fatalf("want 6 facts, got %d", got)
+	}
+}
+
+// stallingStore holds up the first request it reserves a key for until
+// the client gives up on it.
+type stallingStore struct {
+	*sqliterepo.Repo
+
+	once     sync.Once
+	reserved chan struct{}
+	finished chan struct{}
+}
+
+func (st *stallingStore) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, expiredBefore time.Time) (service.IdempotentResponse, bool, error) {
+	rsp, reserved, err := st.Repo.ReserveIdempotencyKey(ctx, key, fingerprint, expiredBefore)
+	if reserved {
+		st.once.Do(func() {
+			close(st.reserved)
+			<-ctx.Done()
+		})
+	}
+	return rsp, reserved, err
+}
+
+func (st *stallingStore) CompleteIdempotencyKey(ctx context.Context, rsp service.IdempotentResponse) error {
+	defer close(st.finished)
+	return st.Repo.CompleteIdempotencyKey(ctx, rsp)
+}
+
+func (st *stallingStore) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	defer close(st.finished)
+	return st.Repo.ReleaseIdempotencyKey(ctx, key)
+}
+
+func TestIdempotentCreateAfterClientGivesUp(t *testing.T) {
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	store := &stallingStore{Repo: r, reserved: make(chan struct{}), finished: make(chan struct{})}
+	ts := httptest.NewServer(service.New(r, service.WithIdempotencyStore(store, time.Hour)).Routes())
+	defer ts.Close()
+
+	newRequest := func(ctx context.Context) *http.Request {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.URL+"/v1/facts", strings.NewReader(`{"content": "a fact"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Idempotency-Key", "key-1")
+		return req
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-store.reserved
+		cancel()
+	}()
+	if _, err := ts.Client().Do(newRequest(ctx)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("want %v, got %v", context.Canceled, err)
+	}
+
+	select {
+	case <-store.finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want the key stored or released, but the request is still being handled")
+	}
+
+	rsp, err := ts.Client().Do(newRequest(context.Background()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusCreated {
+		t.Fatalf("want http %d, got http %d", http.StatusCreated, rsp.StatusCode)
+	}
+	if rsp.Header.Get("Idempotent-Replayed") != "true" {
+		t.Error("want the retry to be a replay of the abandoned request")
+	}
+
+	facts, err := r.Facts(context.TODO(), service.FactsQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(facts) != 1 {
+		t.Errorf("want 1 fact, got %d", len(facts))
+	}
+}
+
+func TestGetFactsPaginated(t *testing.T) {
+	r, cleanup := newTestDB(t,
+		service.Fact{Content: "1"},
+		service.Fact{Content: "2"},
+		service.Fact{Content: "3"},
+	)
+	defer cleanup()
+
+	ts := httptest.NewServer(service.New(r).Routes())
+	defer ts.Close()
+
+	tests := []struct {
+		query       string
+		wantStatus  int
+		wantContent []string
+		wantNext    string
+	}{
+		{query: "?limit=2", wantStatus: http.StatusOK, wantContent: []string{"1", "2"}, wantNext: "2"},
+		{query: "?limit=2&after=2", wantStatus: http.StatusOK, wantContent: []string{"3"}},
+		{query: "?limit=3", wantStatus: http.StatusOK, wantContent: []string{"1", "2", "3"}, wantNext: "3"},
+		{query: "?limit=3&after=3", wantStatus: http.StatusOK},
+		{query: "?after=1", wantStatus: http.StatusOK, wantContent: []string{"2", "3"}},
+		{query: "?limit=0", wantStatus: http.StatusBadRequest},
+		{query: "?limit=1001", wantStatus: http.StatusBadRequest},
+		{query: "?after=soon", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rsp, err := ts.Client().Get(ts.URL + "/v1/facts" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rsp.Body.Close()
+
+			if rsp.StatusCode != tt.wantStatus {
+				t.Fatalf("want http %d, got http %d", tt.wantStatus, rsp.StatusCode)
+			}
+
+			var response struct {
+				Facts []service.Fact `json:"facts"`
+				Next  string         `json:"next"`
+			}
+			if err := json.NewDecoder(rsp.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, f := range response.Facts {
+				got = append(got, f.Content)
+			}
+
+			if !reflect.DeepEqual(tt.wantContent, got) {
+				t.Errorf("want facts %q, got facts %q", tt.wantContent, got)
+			}
+
+			if response.Next != tt.wantNext {
+				t.Errorf("want next %q, got %q", tt.wantNext, response.Next)
+			}
+		})
+	}
+}
+
+// openAPI is the part of the OpenAPI document the tests check.
+type openAPI struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+			Required   []string                   `json:"required"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func getOpenAPI(t *testing.T, ts *httptest.Server) openAPI {
+	t.Helper()
+
+	rsp, err := http.Get(ts.URL + "/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK {
+		t.Fatalf("want status %d, got %d", http.StatusOK, rsp.StatusCode)
+	}
+
+	var doc openAPI
+	if err := json.NewDecoder(rsp.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// operation returns the responses documented for a request, matching
+// concrete paths like /v1/fact/rand before templates like /v1/fact/{id}.
+func (doc openAPI) operation(t *testing.T, method, path string) (map[string]json.RawMessage, bool) {
+	t.Helper()
+
+	templates := make([]string, 0, len(doc.Paths))
+	for template := range doc.Paths {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.Count(templates[i], "{") < strings.Count(templates[j], "{")
+	})
+
+	for _, template := range templates {
+		if !pathMatches(template, path) {
+			continue
+		}
+
+		raw, ok := doc.Paths[template][strings.ToLower(method)]
+		if !ok {
+			continue
+		}
+
+		var op struct {
+			Responses map[string]json.RawMessage `json:"responses"`
+		}
+		if err := json.Unmarshal(raw, &op); err != nil {
+			t.Fatal(err)
+		}
+		return op.Responses, true
+	}
+	return nil, false
+}
+
+func pathMatches(template, path string) bool {
+	want := strings.Split(template, "/")
+	got := strings.Split(path, "/")
+	if len(want) != len(got) {
+		return false
+	}
+
+	for i := range want {
+		isParam := strings.HasPrefix(want[i], "{") && strings.HasSuffix(want[i], "}")
+		if want[i] != got[i] && !isParam {
+			return false
+		}
+	}
+	return true
+}
+
+// openAPIPath turns an httprouter path into an OpenAPI template, so
+// /v1/fact/:id becomes /v1/fact/{id}.
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func TestOpenAPIDescribesRoutes(t *testing.T) {
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	svc := service.New(r)
+	mux := svc.Routes()
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	doc := getOpenAPI(t, ts)
+
+	for _, e := range svc.Endpoints() {
+		path := openAPIPath(e.Path)
+		if _, ok := doc.Paths[path][strings.ToLower(e.Method)]; !ok {
+			t.Errorf("want %s %s documented as %s, got nothing", e.Method, e.Path, path)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+
+			// Fill the template in to see where the router sends it.
+			segments := strings.Split(path, "/")
+			for i, segment := range segments {
+				if strings.HasPrefix(segment, "{") {
+					segments[i] = "1"
+				}
+			}
+			concrete := strings.Join(segments, "/")
+
+			if h, _, _ := mux.Lookup(strings.ToUpper(method), concrete); h == nil {
+				t.Errorf("want %s %s to be routed, got nothing", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestOpenAPIDescribesStatuses(t *testing.T) {
+	r, cleanup := newTestDB(t,
+		service.Fact{Content: "a fun fact", Source: "a unit test"},
+		service.Fact{Content: "another fun fact", Source: "a unit test"},
+		service.Fact{Content: "yet another fun fact", Source: "a unit test"},
+	)
+	defer cleanup()
+
+	ts := httptest.NewServer(service.New(r,
+		service.WithAuthorizer("secret"),
+		service.WithIdempotencyStore(r, time.Hour),
+		service.WithWebhookStore(r),
+	).Routes())
+	defer ts.Close()
+
+	doc := getOpenAPI(t, ts)
+
+	// The requests run in order, and some depend on earlier ones.
+	tests := []struct {
+		method     string
+		path       string
+		header     map[string]string
+		body       string
+		wantStatus int
+	}{
+		{method: http.MethodGet, path: "/v1/facts", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/facts?limit=0", wantStatus: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/v1/facts?format=pdf", wantStatus: http.StatusNotAcceptable},
+		{method: http.MethodGet, path: "/v1/facts", header: map[string]string{"If-None-Match": "*"}, wantStatus: http.StatusNotModified},
+		{method: http.MethodGet, path: "/v1/facts.rss", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/facts.rss?limit=0", wantStatus: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/v1/facts.rss", header: map[string]string{"If-None-Match": "*"}, wantStatus: http.StatusNotModified},
+		{method: http.MethodGet, path: "/v1/facts.atom", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/facts.atom?limit=101", wantStatus: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/v1/facts.atom", header: map[string]string{"If-None-Match": "*"}, wantStatus: http.StatusNotModified},
+		{method: http.MethodGet, path: "/v1/facts/stream", header: map[string]string{"Last-Event-ID": "latest"}, wantStatus: http.StatusBadRequest},
+
+		{method: http.MethodGet, path: "/", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/fact/1", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/fact/1", header: map[string]string{"If-None-Match": "*"}, wantStatus: http.StatusNotModified},
+		{method: http.MethodGet, path: "/fact/999", wantStatus: http.StatusNotFound},
+		{method: http.MethodGet, path: "/facts", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/facts?after=x", wantStatus: http.StatusNotFound},
+		{method: http.MethodGet, path: "/search?q=fun", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/search?q=fun&after=x", wantStatus: http.StatusNotFound},
+		{method: http.MethodGet, path: "/static/site.css", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/static/missing.css", wantStatus: http.StatusNotFound},
+		{method: http.MethodGet, path: "/v1/facts/ws", wantStatus: http.StatusBadRequest},
+
+		{method: http.MethodPost, path: "/v1/facts", body: `{"content": "a new fact"}`, wantStatus: http.StatusCreated},
+		{method: http.MethodPost, path: "/v1/facts", body: `{"content": ""}`, wantStatus: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/v1/facts", header: map[string]string{"Authorization": ""}, body: `{"content": "a new fact"}`, wantStatus: http.StatusForbidden},
+		{method: http.MethodPost, path: "/v1/facts", header: map[string]string{"Idempotency-Key": "k"}, body: `{"content": "a new fact"}`, wantStatus: http.StatusCreated},
+		{method: http.MethodPost, path: "/v1/facts", header: map[string]string{"Idempotency-Key": "k"}, body: `{"content": "a different fact"}`, wantStatus: http.StatusUnprocessableEntity},
+
+		{method: http.MethodPost, path: "/v1/facts:batch", body: `[{"content": "a batched fact"}]`, wantStatus: http.StatusCreated},
+		{method: http.MethodPost, path: "/v1/facts:batch?mode=best-effort", body: `[{"content": "a batched fact"}, {"content": ""}]`, wantStatus: http.StatusOK},
+		{method: http.MethodPost, path: "/v1/facts:batch", body: `[{"content": "a batched fact"}, {"content": ""}]`, wantStatus: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/v1/facts:batch", header: map[string]string{"Authorization": ""}, body: `[{"content": "a batched fact"}]`, wantStatus: http.StatusForbidden},
+
+		{method: http.MethodGet, path: "/v1/fact/1", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/fact/1", header: map[string]string{"If-None-Match": "*"}, wantStatus: http.StatusNotModified},
+		{method: http.MethodGet, path: "/v1/fact/one", wantStatus: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/v1/fact/999", wantStatus: http.StatusNotFound},
+		{method: http.MethodGet, path: "/v1/fact/1?format=pdf", wantStatus: http.StatusNotAcceptable},
+
+		{method: http.MethodPatch, path: "/v1/fact/2", body: `{"weight": 2}`, wantStatus: http.StatusOK},
+		{method: http.MethodPatch, path: "/v1/fact/2", body: `{"content": ""}`, wantStatus: http.StatusBadRequest},
+		{method: http.MethodPatch, path: "/v1/fact/2", header: map[string]string{"Authorization": ""}, body: `{"weight": 2}`, wantStatus: http.StatusForbidden},
+		{method: http.MethodPatch, path: "/v1/fact/999", body: `{"weight": 2}`, wantStatus: http.StatusNotFound},
+		{method: http.MethodPatch, path: "/v1/fact/2", header: map[string]string{"If-Match": `"2-1"`}, body: `{"weight": 3}`, wantStatus: http.StatusPreconditionFailed},
+
+		{method: http.MethodDelete, path: "/v1/fact/3", header: map[string]string{"If-Match": `"3-9"`}, wantStatus: http.StatusPreconditionFailed},
+		{method: http.MethodDelete, path: "/v1/fact/3", wantStatus: http.StatusNoContent},
+		{method: http.MethodDelete, path: "/v1/fact/3", wantStatus: http.StatusNotFound},
+		{method: http.MethodDelete, path: "/v1/fact/three", wantStatus: http.StatusBadRequest},
+		{method: http.MethodDelete, path: "/v1/fact/1", header: map[string]string{"Authorization": ""}, wantStatus: http.StatusForbidden},
+
+		{method: http.MethodGet, path: "/v1/fact/rand", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/fact/rand?count=0", wantStatus: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/v1/fact/rand?strategy=weighted&exclude=1,2,4,5,6,7,8", wantStatus: http.StatusNotFound},
+		{method: http.MethodGet, path: "/v1/fact/rand?format=pdf", wantStatus: http.StatusNotAcceptable},
+
+		{method: http.MethodGet, path: "/v1/fact/today", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/fact/today", header: map[string]string{"If-None-Match": "*"}, wantStatus: http.StatusNotModified},
+		{method: http.MethodGet, path: "/v1/fact/today?date=tomorrow", wantStatus: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/v1/fact/today?format=pdf", wantStatus: http.StatusNotAcceptable},
+
+		{method: http.MethodPut, path: "/v1/today/2023-02-27", body: `{"id": 1}`, wantStatus: http.StatusOK},
+		{method: http.MethodPut, path: "/v1/today/tomorrow", body: `{"id": 1}`, wantStatus: http.StatusBadRequest},
+		{method: http.MethodPut, path: "/v1/today/2023-02-27", body: `{"id": 999}`, wantStatus: http.StatusNotFound},
+		{method: http.MethodPut, path: "/v1/today/2023-02-27", header: map[string]string{"Authorization": ""}, body: `{"id": 1}`, wantStatus: http.StatusForbidden},
+		{method: http.MethodDelete, path: "/v1/today/2023-02-27", wantStatus: http.StatusNoContent},
+		{method: http.MethodDelete, path: "/v1/today/tomorrow", wantStatus: http.StatusBadRequest},
+		{method: http.MethodDelete, path: "/v1/today/2023-02-27", header: map[string]string{"Authorization": ""}, wantStatus: http.StatusForbidden},
+
+		{method: http.MethodGet, path: "/v1/export", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/export?deleted=maybe", wantStatus: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/v1/export", header: map[string]string{"Authorization": ""}, wantStatus: http.StatusForbidden},
+
+		{method: http.MethodPost, path: "/v1/import", body: `{"content": "an imported fact"}`, wantStatus: http.StatusOK},
+		{method: http.MethodPost, path: "/v1/import?preserve=maybe", wantStatus: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/v1/import", header: map[string]string{"Authorization": ""}, wantStatus: http.StatusForbidden},
+
+		{method: http.MethodGet, path: "/v1/webhooks", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/webhooks", header: map[string]string{"Authorization": ""}, wantStatus: http.StatusForbidden},
+		{method: http.MethodPost, path: "/v1/webhooks", body: `{"url": "http://example.com/hook", "events": ["fact.created"]}`, wantStatus: http.StatusCreated},
+		{method: http.MethodPost, path: "/v1/webhooks", body: `{"url": "example.com", "events": ["fact.created"]}`, wantStatus: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/v1/webhooks", header: map[string]string{"Authorization": ""}, body: `{"url": "http://example.com/hook", "events": ["fact.created"]}`, wantStatus: http.StatusForbidden},
+		{method: http.MethodPost, path: "/v1/facts", body: `{"content": "a fact for the webhook"}`, wantStatus: http.StatusCreated},
+		{method: http.MethodGet, path: "/v1/webhook/1", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/webhook/one", wantStatus: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/v1/webhook/999", wantStatus: http.StatusNotFound},
+		{method: http.MethodPatch, path: "/v1/webhook/1", body: `{"events": ["fact.created", "fact.deleted"]}`, wantStatus: http.StatusOK},
+		{method: http.MethodPatch, path: "/v1/webhook/1", body: `{"events": []}`, wantStatus: http.StatusBadRequest},
+		{method: http.MethodPatch, path: "/v1/webhook/999", body: `{"events": ["fact.created"]}`, wantStatus: http.StatusNotFound},
+		{method: http.MethodGet, path: "/v1/webhook/1/deliveries", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/v1/webhook/1/deliveries?limit=0", wantStatus: http.StatusBadRequest},
+		{method: http.MethodGet, path: "/v1/webhook/999/deliveries", wantStatus: http.StatusNotFound},
+		{method: http.MethodPost, path: "/v1/webhook/1/deliveries/1/redeliver", wantStatus: http.StatusAccepted},
+		{method: http.MethodPost, path: "/v1/webhook/1/deliveries/one/redeliver", wantStatus: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/v1/webhook/1/deliveries/999/redeliver", wantStatus: http.StatusNotFound},
+		{method: http.MethodDelete, path: "/v1/webhook/1", wantStatus: http.StatusNoContent},
+		{method: http.MethodDelete, path: "/v1/webhook/1", wantStatus: http.StatusNotFound},
+
+		{method: http.MethodPost, path: "/v1/slack/command", body: "command=%2Ffact&text=", wantStatus: http.StatusNotImplemented},
+		{method: http.MethodPost, path: "/v1/discord/interactions", body: `{"type": 1}`, wantStatus: http.StatusNotImplemented},
+
+		{method: http.MethodGet, path: "/admin/login", wantStatus: http.StatusOK},
+		{method: http.MethodPost, path: "/admin/login", header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, body: "secret=wrong", wantStatus: http.StatusUnauthorized},
+		{method: http.MethodPost, path: "/admin/new", header: map[string]string{"Content-Type": "application/x-www-form-urlencoded"}, body: "content=a+forged+fact", wantStatus: http.StatusForbidden},
+
+		{method: http.MethodGet, path: "/openapi.json", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/openapi.json", header: map[string]string{"If-None-Match": "*"}, wantStatus: http.StatusNotModified},
+		{method: http.MethodGet, path: "/docs/", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/docs/docs.js", wantStatus: http.StatusOK},
+		{method: http.MethodGet, path: "/docs/nothing.html", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		var name string
+		for k, v := range tt.header {
+			name += fmt.Sprintf(" %s=%q", k, v)
+		}
+
+		t.Run(tt.method+" "+tt.path+name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.path, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "secret")
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+
+			rsp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rsp.Body.Close()
+
+			if rsp.StatusCode != tt.wantStatus {
+				t.Fatalf("want status %d, got %d", tt.wantStatus, rsp.StatusCode)
+			}
+
+			responses, ok := doc.operation(t, tt.method, req.URL.Path)
+			if !ok {
+				t.Fatalf("want %s %s documented, got nothing", tt.method, req.URL.Path)
+			}
+			if _, ok := responses[strconv.Itoa(rsp.StatusCode)]; !ok {
+				t.Errorf("want status %d documented, got %v", rsp.StatusCode, keys(responses))
+			}
+
+			// Errors are wrapped in the documented envelope.
+			if rsp.StatusCode >= 400 && rsp.Header.Get("Content-Type") == "application/json" {
+				var envelope map[string]any
+				if err := json.NewDecoder(rsp.Body).Decode(&envelope); err != nil {
+					t.Fatal(err)
+				}
+				if msg, _ := envelope["error"].(string); msg == "" {
+					t.Errorf("want an error message, got %v", envelope)
+				}
+			}
+		})
+	}
+}
+
+func keys(m map[string]json.RawMessage) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(service.New(r).Routes())
+	defer ts.Close()
+
+	doc := getOpenAPI(t, ts)
+
+	tests := []struct {
+		schema string
+		typ    reflect.Type
+	}{
+		{schema: "Fact", typ: reflect.TypeOf(service.Fact{})},
+		{schema: "Citation", typ: reflect.TypeOf(service.Citation{})},
+		{schema: "BatchResult", typ: reflect.TypeOf(service.BatchResult{})},
+		{schema: "ImportRejection", typ: reflect.TypeOf(service.ImportRejection{})},
+		{schema: "Event", typ: reflect.TypeOf(service.Event{})},
+		{schema: "WSMessage", typ: reflect.TypeOf(service.WSMessage{})},
+		{schema: "Webhook", typ: reflect.TypeOf(service.Webhook{})},
+		{schema: "WebhookDelivery", typ: reflect.TypeOf(service.WebhookDelivery{})},
+		{schema: "WebhookAttempt", typ: reflect.TypeOf(service.WebhookAttempt{})},
+		{schema: "SlackMessage", typ: reflect.TypeOf(service.SlackMessage{})},
+		{schema: "SlackBlock", typ: reflect.TypeOf(service.SlackBlock{})},
+		{schema: "SlackText", typ: reflect.TypeOf(service.SlackText{})},
+		{schema: "DiscordResponse", typ: reflect.TypeOf(service.DiscordResponse{})},
+		{schema: "DiscordMessage", typ: reflect.TypeOf(service.DiscordMessage{})},
+		{schema: "DiscordEmbed", typ: reflect.TypeOf(service.DiscordEmbed{})},
+		{schema: "DiscordEmbedField", typ: reflect.TypeOf(service.DiscordEmbedField{})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.schema, func(t *testing.T) {
+			schema, ok := doc.Components.Schemas[tt.schema]
+			if !ok {
+				t.Fatalf("want schema %s, got nothing", tt.schema)
+			}
+
+			// Fields that are always present are required.
+			var wantProperties, wantRequired []string
+			for i := 0; i < tt.typ.NumField(); i++ {
+				name, opts, _ := strings.Cut(tt.typ.Field(i).Tag.Get("json"), ",")
+				if name == "-" {
+					continue
+				}
+
+				wantProperties = append(wantProperties, name)
+				if opts != "omitempty" {
+					wantRequired = append(wantRequired, name)
+				}
+			}
+
+			gotProperties := keys(schema.Properties)
+			gotRequired := append([]string(nil), schema.Required...)
+			sort.Strings(wantProperties)
+			sort.Strings(wantRequired)
+			sort.Strings(gotRequired)
+
+			if !reflect.DeepEqual(wantProperties, gotProperties) {
+				t.Errorf("want properties %v, got %v", wantProperties, gotProperties)
+			}
+			if !reflect.DeepEqual(wantRequired, gotRequired) {
+				t.Errorf("want required %v, got %v", wantRequired, gotRequired)
+			}
+		})
+	}
+}
+
+type sseEvent struct {
+	id, event, data, comment string
+}
+
+// readEvents parses Server-Sent Events from r until it ends.
+func readEvents(r io.Reader) <-chan sseEvent {
+	events := make(chan sseEvent)
+
+	go func() {
+		defer close(events)
+
+		var e sseEvent
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			field, value, _ := strings.Cut(scanner.Text(), ":")
+			value = strings.TrimPrefix(value, " ")
+
+			switch field {
+			case "":
+				if scanner.Text() == "" {
+					if e != (sseEvent{}) {
+						events <- e
+					}
+					e = sseEvent{}
+				} else {
+					e.comment = value
+				}
+			case "id":
+				e.id = value
+			case "event":
+				e.event = value
+			case "data":
+				e.data = value
+			}
+		}
+	}()
+
+	return events
+}
+
+// nextEvent returns the next event that isn't a comment.
+func nextEvent(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatal("want an event, got the end of the stream")
+			}
+			if e.comment == "" {
+				return e
+			}
+		case <-timeout:
+			t.Fatal("want an event, got nothing")
+		}
+	}
+}
+
+func TestFactStream(t *testing.T) {
+	r, cleanup := newTestDB(t, service.Fact{Content: "a fun fact", Source: "a unit test"})
+	defer cleanup()
+
+	svc := service.New(r,
+		service.WithEventLog(service.NewMemoryEventLog(3)),
+		service.WithHeartbeatInterval(10*time.Millisecond),
+	)
+
+	ts := httptest.NewServer(svc.Routes())
+	defer ts.Close()
+
+	stream := func(t *testing.T, lastEventID string) (<-chan sseEvent, func()) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/facts/stream", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if rsp.StatusCode != http.StatusOK {
+			rsp.Body.Close()
+			t.Fatalf("want status %d, got %d", http.StatusOK, rsp.StatusCode)
+		}
+		if got := rsp.Header.Get("Content-Type"); got != "text/event-stream" {
+			t.Errorf("want Content-Type text/event-stream, got %q", got)
+		}
+
+		return readEvents(rsp.Body), func() { rsp.Body.Close() }
+	}
+
+	send := func(t *testing.T, method, path, body string) {
+		t.Helper()
+
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+
+		if rsp.StatusCode >= 300 {
+			t.Fatalf("%s %s: want success, got status %d", method, path, rsp.StatusCode)
+		}
+	}
+
+	type wantEvent struct {
+		id, event string
+		factID    int64
+		content   string
+	}
+
+	check := func(t *testing.T, events <-chan sseEvent, want []wantEvent) {
+		t.Helper()
+
+		for _, w := range want {
+			got := nextEvent(t, events)
+			if got.id != w.id || got.event != w.event {
+				t.Fatalf("want event %s %s, got %s %s", w.id, w.event, got.id, got.event)
+			}
+			if w.event == "reset" {
+				continue
+			}
+
+			var e service.Event
+			if err := json.Unmarshal([]byte(got.data), &e); err != nil {
+				t.Fatal(err)
+			}
+			if strconv.FormatInt(e.ID, 10) != w.id || e.Type != w.event || e.FactID != w.factID {
+				t.Errorf("want data for event %s %s about fact %d, got %s", w.id, w.event, w.factID, got.data)
+			}
+			if (e.Fact == nil && w.content != "") || (e.Fact != nil && e.Fact.Content != w.content) {
+				t.Errorf("want fact %q, got %s", w.content, got.data)
+			}
+		}
+	}
+
+	t.Run("live", func(t *testing.T) {
+		events, done := stream(t, "")
+		defer done()
+
+		send(t, http.MethodPost, "/v1/facts", `{"content": "a new fact"}`)
+		send(t, http.MethodPatch, "/v1/fact/2", `{"content": "a changed fact"}`)
+		send(t, http.MethodDelete, "/v1/fact/2", "")
+
+		check(t, events, []wantEvent{
+			{id: "1", event: service.EventFactCreated, factID: 2, content: "a new fact"},
+			{id: "2", event: service.EventFactUpdated, factID: 2, content: "a changed fact"},
+			{id: "3", event: service.EventFactDeleted, factID: 2},
+		})
+
+		timeout := time.After(5 * time.Second)
+		for heartbeat := false; !heartbeat; {
+			select {
+			case e := <-events:
+				heartbeat = e.comment == "heartbeat"
+			case <-timeout:
+				t.Fatal("want a heartbeat, got nothing")
+			}
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		events, done := stream(t, "1")
+		defer done()
+
+		check(t, events, []wantEvent{
+			{id: "2", event: service.EventFactUpdated, factID: 2, content: "a changed fact"},
+			{id: "3", event: service.EventFactDeleted, factID: 2},
+		})
+
+		send(t, http.MethodPost, "/v1/facts:batch", `[{"content": "a batched fact"}]`)
+
+		check(t, events, []wantEvent{
+			{id: "4", event: service.EventFactCreated, factID: 3, content: "a batched fact"},
+		})
+	})
+
+	t.Run("forgotten", func(t *testing.T) {
+		send(t, http.MethodPost, "/v1/import", `{"content": "an imported fact"}`)
+
+		// Only events 3 to 5 are kept.
+		events, done := stream(t, "1")
+		defer done()
+
+		check(t, events, []wantEvent{
+			{event: "reset"},
+			{id: "3", event: service.EventFactDeleted, factID: 2},
+			{id: "4", event: service.EventFactCreated, factID: 3, content: "a batched fact"},
+			{id: "5", event: service.EventFactCreated, factID: 4, content: "an imported fact"},
+		})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		rsp, err := http.Get(ts.URL + "/v1/facts/stream?last_event_id=latest")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusBadRequest {
+			t.Errorf("want status %d, got %d", http.StatusBadRequest, rsp.StatusCode)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		events, done := stream(t, "")
+		defer done()
+
+		svc.Close()
+
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case _, ok := <-events:
+				if !ok {
+					return
+				}
+			case <-timeout:
+				t.Fatal("want the stream to end, got more")
+			}
+		}
+	})
+}
+
+func TestWebSocket(t *testing.T) {
+	r, cleanup := newTestDB(t, service.Fact{Content: "a fun fact", Source: "a unit test"})
+	defer cleanup()
+
+	svc := service.New(r,
+		service.WithMaxWebSockets(2),
+		service.WithHeartbeatInterval(20*time.Millisecond),
+	)
+
+	ts := httptest.NewServer(svc.Routes())
+	defer ts.Close()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/v1/facts/ws"
+
+	// dial waits for connections from earlier tests to go away if
+	// there are too many.
+	dial := func(t *testing.T) *websocket.Conn {
+		t.Helper()
+
+		for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+			conn, rsp, err := websocket.DefaultDialer.Dial(url, nil)
+			if rsp != nil {
+				rsp.Body.Close()
+			}
+			if err == nil {
+				return conn
+			}
+			if rsp == nil || rsp.StatusCode != http.StatusServiceUnavailable || time.Now().After(deadline) {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// read returns the next message, answering pings along the way.
+	read := func(t *testing.T, conn *websocket.Conn) service.WSMessage {
+		t.Helper()
+
+		if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+
+		var msg service.WSMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatal(err)
+		}
+		return msg
+	}
+
+	write := func(t *testing.T, conn *websocket.Conn, msg string) {
+		t.Helper()
+
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	post := func(t *testing.T, content string) {
+		t.Helper()
+
+		rsp, err := http.Post(ts.URL+"/v1/facts", "application/json", strings.NewReader(`{"content": "`+content+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusCreated {
+			t.Fatalf("want status %d, got %d", http.StatusCreated, rsp.StatusCode)
+		}
+	}
+
+	t.Run("changes", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		write(t, conn, `{"type": "subscribe", "topic": "changes"}`)
+		if got := read(t, conn); got.Type != "subscribed" || got.Topic != service.TopicChanges {
+			t.Fatalf("want subscribed to changes, got %+v", got)
+		}
+
+		post(t, "a new fact")
+
+		got := read(t, conn)
+		if got.Type != "event" || got.Event == nil || got.Event.Type != service.EventFactCreated || got.Event.Fact == nil || got.Event.Fact.Content != "a new fact" {
+			t.Fatalf("want an event about the new fact, got %+v", got)
+		}
+
+		write(t, conn, `{"type": "unsubscribe", "topic": "changes"}`)
+		if got := read(t, conn); got.Type != "unsubscribed" {
+			t.Fatalf("want unsubscribed, got %+v", got)
+		}
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		write(t, conn, `{"type": "subscribe", "topic": "changes", "after": 0}`)
+		if got := read(t, conn); got.Type != "subscribed" {
+			t.Fatalf("want subscribed, got %+v", got)
+		}
+
+		got := read(t, conn)
+		if got.Type != "event" || got.Event == nil || got.Event.ID != 1 {
+			t.Fatalf("want event 1, got %+v", got)
+		}
+	})
+
+	t.Run("random", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		write(t, conn, `{"type": "subscribe", "topic": "random", "interval": 1}`)
+		if got := read(t, conn); got.Type != "subscribed" || got.Topic != service.TopicRandom {
+			t.Fatalf("want subscribed to random, got %+v", got)
+		}
+
+		// Both facts are sent before any repeats.
+		seen := make(map[int64]bool)
+		for i := 0; i < 2; i++ {
+			got := read(t, conn)
+			if got.Type != "fact" || got.Fact == nil {
+				t.Fatalf("want a fact, got %+v", got)
+			}
+			seen[got.Fact.ID] = true
+		}
+		if len(seen) != 2 {
+			t.Errorf("want 2 different facts, got %v", seen)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		tests := []struct {
+			msg     string
+			wantErr string
+		}{
+			{msg: `{"type": "subscribe", "topic": "tag:science"}`, wantErr: "topic must be 'changes' or 'random'"},
+			{msg: `{"type": "subscribe", "topic": "random"}`, wantErr: "interval must be a number of seconds between 1 and 86400"},
+			{msg: `{"type": "subscribe", "topic": "random", "interval": 5, "strategy": "best"}`, wantErr: "strategy must be one of 'uniform', 'weighted', 'favor-recent' or 'favor-unseen'"},
+			{msg: `{"type": "subscribe", "topic": "changes", "after": -1}`, wantErr: "after must be the id of an event"},
+			{msg: `{"type": "unsubscribe", "topic": "changes"}`, wantErr: "not subscribed to this topic"},
+			{msg: `{"type": "publish"}`, wantErr: "type must be 'subscribe' or 'unsubscribe'"},
+			{msg: `subscribe`, wantErr: "messages must be JSON objects"},
+		}
+
+		for _, tt := range tests {
+			write(t, conn, tt.msg)
+			if got := read(t, conn); got.Type != "error" || got.Error != tt.wantErr {
+				t.Errorf("%s: want error %q, got %+v", tt.msg, tt.wantErr, got)
+			}
+		}
+	})
+
+	t.Run("ping", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		pinged := make(chan struct{}, 1)
+		conn.SetPingHandler(func(data string) error {
+			select {
+			case pinged <- struct{}{}:
+			default:
+			}
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+
+		// Control messages are handled while reading.
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		select {
+		case <-pinged:
+		case <-time.After(5 * time.Second):
+			t.Fatal("want a ping, got nothing")
+		}
+	})
+
+	t.Run("too many", func(t *testing.T) {
+		first, second := dial(t), dial(t)
+		defer first.Close()
+		defer second.Close()
+
+		_, rsp, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != websocket.ErrBadHandshake {
+			t.Fatalf("want %v, got %v", websocket.ErrBadHandshake, err)
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("want status %d, got %d", http.StatusServiceUnavailable, rsp.StatusCode)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		conn := dial(t)
+		defer conn.Close()
+
+		svc.Close()
+
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for {
+			_, _, err := conn.ReadMessage()
+			if err == nil {
+				continue
+			}
+			if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+				t.Errorf("want close code %d, got %v", websocket.CloseGoingAway, err)
+			}
+			return
+		}
+	})
+}
+
+// webhookReceiver records what it's sent, failing the first attempt at
+// each delivery of the event types in fail.
+type webhookReceiver struct {
+	mu   sync.Mutex
+	fail map[string]bool
+	got  []*http.Request
+	body [][]byte
+}
+
+func (rc *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.got = append(rc.got, r)
+	rc.body = append(rc.body, body)
+
+	if event := r.Header.Get(webhook.HeaderEvent); rc.fail[event] {
+		rc.fail[event] = false
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	rc := &webhookReceiver{fail: map[string]bool{service.EventFactCreated: true}}
+	receiver := httptest.NewServer(rc)
+	defer receiver.Close()
+
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	ts := httptest.NewServer(service.New(r, service.WithWebhookStore(r)).Routes())
+	defer ts.Close()
+
+	dispatcher := webhook.New(r,
+		webhook.WithHTTPClient(receiver.Client()),
+		webhook.WithBackoff(0, 0),
+	)
+
+	do := func(t *testing.T, method, path, body string, wantStatus int, v any) {
+		t.Helper()
+
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rsp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != wantStatus {
+			b, _ := io.ReadAll(rsp.Body)
+			t.Fatalf("want http %d, got http %d: %s", wantStatus, rsp.StatusCode, b)
+		}
+		if v != nil {
+			if err := json.NewDecoder(rsp.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	deliver := func(t *testing.T) {
+		t.Helper()
+
+		if err := dispatcher.DeliverOnce(context.TODO()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("validation", func(t *testing.T) {
+		for _, body := range []string{
+			`{"url": "", "events": ["fact.created"]}`,
+			`{"url": "ftp://example.com", "events": ["fact.created"]}`,
+			`{"url": "/hook", "events": ["fact.created"]}`,
+			`{"url": "http://example.com"}`,
+			`{"url": "http://example.com", "events": ["fact.exploded"]}`,
+			`{"url": "http://example.com", "events": "fact.created"}`,
+		} {
+			do(t, http.MethodPost, "/v1/webhooks", body, http.StatusBadRequest, nil)
+		}
+	})
+
+	var created struct {
+		Webhook service.Webhook `json:"webhook"`
+	}
+	do(t, http.MethodPost, "/v1/webhooks", fmt.Sprintf(`{"url": %q, "events": ["fact.created", "fact.deleted", "fact.created"]}`, receiver.URL), http.StatusCreated, &created)
+
+	hook := created.Webhook
+	if hook.Secret == "" {
+		t.Fatal("want a secret to be generated")
+	}
+	if want := []string{service.EventFactCreated, service.EventFactDeleted}; !reflect.DeepEqual(want, hook.Events) {
+		t.Errorf("want events %v, got %v", want, hook.Events)
+	}
+
+	t.Run("secret is hidden", func(t *testing.T) {
+		var got struct {
+			Webhook service.Webhook `json:"webhook"`
+		}
+		do(t, http.MethodGet, fmt.Sprintf("/v1/webhook/%d", hook.ID), "", http.StatusOK, &got)
+		if got.Webhook.Secret != "" {
+			t.Errorf("want no secret, got %q", got.Webhook.Secret)
+		}
+
+		var list struct {
+			Webhooks []service.Webhook `json:"webhooks"`
+		}
+		do(t, http.MethodGet, "/v1/webhooks", "", http.StatusOK, &list)
+		if len(list.Webhooks) != 1 || list.Webhooks[0].Secret != "" {
+			t.Errorf("want 1 webhook without a secret, got %+v", list.Webhooks)
+		}
+	})
+
+	var fact struct {
+		Fact service.Fact `json:"fact"`
+	}
+	do(t, http.MethodPost, "/v1/facts", `{"content": "a fact"}`, http.StatusCreated, &fact)
+	do(t, http.MethodPatch, fmt.Sprintf("/v1/fact/%d", fact.Fact.ID), `{"weight": 2}`, http.StatusOK, nil)
+	do(t, http.MethodDelete, fmt.Sprintf("/v1/fact/%d", fact.Fact.ID), "", http.StatusNoContent, nil)
+
+	// The created event fails once and is retried.
+	deliver(t)
+	deliver(t)
+	deliver(t)
+
+	t.Run("signed deliveries", func(t *testing.T) {
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+
+		if len(rc.got) != 3 {
+			t.Fatalf("want 3 requests, got %d", len(rc.got))
+		}
+
+		for i, req := range rc.got {
+			if want, got := webhook.Sign(hook.Secret, rc.body[i]), req.Header.Get(webhook.HeaderSignature); want != got {
+				t.Errorf("want signature %q, got %q", want, got)
+			}
+
+			var e service.Event
+			if err := json.Unmarshal(rc.body[i], &e); err != nil {
+				t.Fatal(err)
+			}
+			if e.Type != req.Header.Get(webhook.HeaderEvent) || e.FactID != fact.Fact.ID {
+				t.Errorf("want an event about fact %d, got %+v", fact.Fact.ID, e)
+			}
+			if e.Type == service.EventFactUpdated {
+				t.Errorf("want only subscribed events, got %q", e.Type)
+			}
+		}
+	})
+
+	var deliveries struct {
+		Deliveries []service.WebhookDelivery `json:"deliveries"`
+	}
+	do(t, http.MethodGet, fmt.Sprintf("/v1/webhook/%d/deliveries", hook.ID), "", http.StatusOK, &deliveries)
+
+	if len(deliveries.Deliveries) != 2 {
+		t.Fatalf("want 2 deliveries, got %d", len(deliveries.Deliveries))
+	}
+
+	// Newest first.
+	deleted, createdDelivery := deliveries.Deliveries[0], deliveries.Deliveries[1]
+	if deleted.EventType != service.EventFactDeleted || createdDelivery.EventType != service.EventFactCreated {
+		t.Fatalf("want deleted then created, got %q then %q", deleted.EventType, createdDelivery.EventType)
+	}
+
+	t.Run("attempts are recorded", func(t *testing.T) {
+		var statuses []int
+		for _, a := range createdDelivery.Attempts {
+			statuses = append(statuses, a.StatusCode)
+		}
+		if want := []int{http.StatusServiceUnavailable, http.StatusOK}; !reflect.DeepEqual(want, statuses) {
+			t.Errorf("want attempts %v, got %v", want, statuses)
+		}
+		if createdDelivery.Status != service.DeliveryDelivered {
+			t.Errorf("want status %q, got %q", service.DeliveryDelivered, createdDelivery.Status)
+		}
+		if createdDelivery.NextAttemptAt != nil {
+			t.Errorf("want no next attempt, got %v", createdDelivery.NextAttemptAt)
+		}
+	})
+
+	t.Run("redeliver", func(t *testing.T) {
+		var redelivered struct {
+			Delivery service.WebhookDelivery `json:"delivery"`
+		}
+		do(t, http.MethodPost, fmt.Sprintf("/v1/webhook/%d/deliveries/%d/redeliver", hook.ID, deleted.ID), "", http.StatusAccepted, &redelivered)
+		if redelivered.Delivery.Status != service.DeliveryPending {
+			t.Errorf("want status %q, got %q", service.DeliveryPending, redelivered.Delivery.Status)
+		}
+
+		deliver(t)
+
+		rc.mu.Lock()
+		last := rc.got[len(rc.got)-1]
+		n := len(rc.got)
+		rc.mu.Unlock()
+
+		if n != 4 {
+			t.Fatalf("want 4 requests, got %d", n)
+		}
+		if want, got := strconv.FormatInt(deleted.ID, 10), last.Header.Get(webhook.HeaderDelivery); want != got {
+			t.Errorf("want delivery %q, got %q", want, got)
+		}
+
+		// Deliveries belong to their webhook.
+		do(t, http.MethodPost, fmt.Sprintf("/v1/webhook/%d/deliveries/%d/redeliver", hook.ID+1, deleted.ID), "", http.StatusNotFound, nil)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		var updated struct {
+			Webhook service.Webhook `json:"webhook"`
+		}
+		do(t, http.MethodPatch, fmt.Sprintf("/v1/webhook/%d", hook.ID), `{"events": ["fact.updated"]}`, http.StatusOK, &updated)
+		if want := []string{service.EventFactUpdated}; !reflect.DeepEqual(want, updated.Webhook.Events) {
+			t.Errorf("want events %v, got %v", want, updated.Webhook.Events)
+		}
+		if updated.Webhook.Secret != "" || updated.Webhook.URL != receiver.URL {
+			t.Errorf("want only events changed, got %+v", updated.Webhook)
+		}
+
+		do(t, http.MethodPatch, fmt.Sprintf("/v1/webhook/%d", hook.ID), `{"secret": "rotated"}`, http.StatusOK, &updated)
+		if updated.Webhook.Secret != "rotated" {
+			t.Errorf("want secret %q, got %q", "rotated", updated.Webhook.Secret)
+		}
+
+		do(t, http.MethodPatch, fmt.Sprintf("/v1/webhook/%d", hook.ID), `{"secret": ""}`, http.StatusBadRequest, nil)
+		do(t, http.MethodPatch, fmt.Sprintf("/v1/webhook/%d", hook.ID), `{"url": "nowhere"}`, http.StatusBadRequest, nil)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		do(t, http.MethodDelete, fmt.Sprintf("/v1/webhook/%d", hook.ID), "", http.StatusNoContent, nil)
+		do(t, http.MethodGet, fmt.Sprintf("/v1/webhook/%d", hook.ID), "", http.StatusNotFound, nil)
+		do(t, http.MethodGet, fmt.Sprintf("/v1/webhook/%d/deliveries", hook.ID), "", http.StatusNotFound, nil)
+		do(t, http.MethodPost, fmt.Sprintf("/v1/webhook/%d/deliveries/%d/redeliver", hook.ID, deleted.ID), "", http.StatusNotFound, nil)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		ts := httptest.NewServer(service.New(r).Routes())
+		defer ts.Close()
+
+		rsp, err := ts.Client().Get(ts.URL + "/v1/webhooks")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		if rsp.StatusCode != http.StatusNotImplemented {
+			t.Errorf("want http %d, got http %d", http.StatusNotImplemented, rsp.StatusCode)
+		}
+	})
+}
+
+// slackPayload is a slash command as Slack sends it, recorded from a test
+// workspace, with the user and text swapped out.
+func slackPayload(userID, text string) string {
+	form := url.Values{
+		"token":                 {"gIkuvaNzQIHg97ATvDxqgjtO"},
+		"team_id":               {"T0001"},
+		"team_domain":           {"example"},
+		"enterprise_id":         {"E0001"},
+		"enterprise_name":       {"Globular Construct Inc"},
+		"channel_id":            {"C2147483705"},
+		"channel_name":          {"test"},
+		"user_id":               {userID},
+		"user_name":             {"Steve"},
+		"command":               {"/fact"},
+		"text":                  {text},
+		"response_url":          {"https://hooks.slack.com/commands/1234/5678"},
+		"trigger_id":            {"13345224609.738474920.8088930838d88f008e0"},
+		"api_app_id":            {"A123456"},
+		"is_enterprise_install": {"false"},
+	}
+	return form.Encode()
+}
+
+func TestSlack(t *testing.T) {
+	r, cleanup := newTestDB(t,
+		service.Fact{Content: "Octopuses have three hearts.", Source: "a unit test"},
+		service.Fact{Content: "An octopus can taste with its arms.", Citations: []service.Citation{{URL: "https://example.com/octopus", Title: "Octopus <arms>"}}},
+		service.Fact{Content: "Honey never spoils, it's 100% sugar & water.", Source: "a unit test"},
+		service.Fact{Content: "Snake_case is named for snakes.", Source: "a unit test"},
+	)
+	defer cleanup()
+
+	const secret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+	ts := httptest.NewServer(service.New(r, service.WithSlack(secret, "U0ADMIN")).Routes())
+	defer ts.Close()
+
+	send := func(t *testing.T, header map[string]string, body string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/slack/command", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+
+		rsp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rsp
+	}
+
+	signed := func(timestamp time.Time, body string) map[string]string {
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+		return map[string]string{
+			"X-Slack-Request-Timestamp": ts,
+			"X-Slack-Signature":         service.SlackSignature(secret, ts, []byte(body)),
+		}
+	}
+
+	t.Run("commands", func(t *testing.T) {
+		tests := []struct {
+			name             string
+			user             string
+			text             string
+			wantResponseType string
+			wantTexts        []string
+			wantNotTexts     []string
+		}{
+			{
+				name:             "random",
+				text:             "",
+				wantResponseType: service.SlackInChannel,
+				wantTexts:        []string{"Fact "},
+			},
+			{
+				name:             "by id",
+				text:             "1",
+				wantResponseType: service.SlackInChannel,
+				wantTexts:        []string{"Octopuses have three hearts.", "Fact 1", "Source: a unit test"},
+			},
+			{
+				name:             "citations are links",
+				text:             " 2 ",
+				wantResponseType: service.SlackInChannel,
+				wantTexts:        []string{"<https://example.com/octopus|Octopus &lt;arms&gt;>"},
+			},
+			{
+				name:             "content is escaped",
+				text:             "3",
+				wantResponseType: service.SlackInChannel,
+				wantTexts:        []string{"100% sugar &amp; water."},
+			},
+			{
+				name:             "unknown id",
+				text:             "999",
+				wantResponseType: service.SlackEphemeral,
+				wantTexts:        []string{"There's no fact 999."},
+			},
+			{
+				name:             "search",
+				text:             "search OCTOPUS",
+				wantResponseType: service.SlackInChannel,
+				wantTexts:        []string{"Facts that mention", "Octopuses have three hearts.", "An octopus can taste with its arms."},
+				wantNotTexts:     []string{"Honey"},
+			},
+			{
+				name:             "search is literal",
+				text:             "search %",
+				wantResponseType: service.SlackInChannel,
+				wantTexts:        []string{"Honey never spoils"},
+				wantNotTexts:     []string{"Octopus"},
+			},
+			{
+				name:             "search underscores are literal",
+				text:             "search e_c",
+				wantResponseType: service.SlackInChannel,
+				wantTexts:        []string{"Snake_case"},
+				wantNotTexts:     []string{"Octopus", "Honey"},
+			},
+			{
+				name:             "search without results",
+				text:             "search giraffe",
+				wantResponseType: service.SlackEphemeral,
+				wantTexts:        []string{"No facts mention “giraffe”."},
+			},
+			{
+				name:             "search without terms",
+				text:             "search",
+				wantResponseType: service.SlackEphemeral,
+				wantTexts:        []string{"What should I look for?"},
+			},
+			{
+				name:             "add unauthorized",
+				user:             "U0SOMEONE",
+				text:             "add Giraffes hum at night.",
+				wantResponseType: service.SlackEphemeral,
+				wantTexts:        []string{"you aren't allowed to add facts"},
+			},
+			{
+				name:             "add without content",
+				user:             "U0ADMIN",
+				text:             "add",
+				wantResponseType: service.SlackEphemeral,
+				wantTexts:        []string{"Say what the fact is"},
+			},
+			{
+				name:             "add",
+				user:             "U0ADMIN",
+				text:             "add Giraffes hum at night.",
+				wantResponseType: service.SlackEphemeral,
+				wantTexts:        []string{"Added fact 5:", "Giraffes hum at night."},
+			},
+			{
+				name:             "added facts can be found",
+				text:             "search giraffe",
+				wantResponseType: service.SlackInChannel,
+				wantTexts:        []string{"Giraffes hum at night."},
+			},
+			{
+				name:             "help",
+				text:             "help",
+				wantResponseType: service.SlackEphemeral,
+				wantTexts:        []string{"`/fact 42`"},
+			},
+			{
+				name:             "nonsense",
+				text:             "42 octopus",
+				wantResponseType: service.SlackEphemeral,
+				wantTexts:        []string{"`/fact search octopus`"},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				user := tt.user
+				if user == "" {
+					user = "U0SOMEONE"
+				}
+				body := slackPayload(user, tt.text)
+
+				rsp := send(t, signed(time.Now(), body), body)
+				defer rsp.Body.Close()
+
+				if rsp.StatusCode != http.StatusOK {
+					t.Fatalf("want http %d, got http %d", http.StatusOK, rsp.StatusCode)
+				}
+
+				var msg service.SlackMessage
+				if err := json.NewDecoder(rsp.Body).Decode(&msg); err != nil {
+					t.Fatal(err)
+				}
+
+				if msg.ResponseType != tt.wantResponseType {
+					t.Errorf("want response type %q, got %q", tt.wantResponseType, msg.ResponseType)
+				}
+				if msg.Text == "" {
+					t.Errorf("want text for notifications, got nothing")
+				}
+
+				var texts []string
+				for _, b := range msg.Blocks {
+					if b.Text != nil {
+						texts = append(texts, b.Text.Text)
+					}
+					for _, e := range b.Elements {
+						texts = append(texts, e.Text)
+					}
+				}
+				all := strings.Join(texts, "\n")
+
+				for _, want := range tt.wantTexts {
+					if !strings.Contains(all, want) {
+						t.Errorf("want blocks containing %q, got %q", want, all)
+					}
+				}
+				for _, notWant := range tt.wantNotTexts {
+					if strings.Contains(all, notWant) {
+						t.Errorf("want blocks without %q, got %q", notWant, all)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("verification", func(t *testing.T) {
+		body := slackPayload("U0ADMIN", "add Forged facts are not facts.")
+
+		tests := []struct {
+			name   string
+			header map[string]string
+		}{
+			{
+				name: "unsigned",
+			},
+			{
+				name:   "stale",
+				header: signed(time.Now().Add(-10*time.Minute), body),
+			},
+			{
+				name:   "from the future",
+				header: signed(time.Now().Add(10*time.Minute), body),
+			},
+			{
+				name: "wrong secret",
+				header: map[string]string{
+					"X-Slack-Request-Timestamp": strconv.FormatInt(time.Now().Unix(), 10),
+					"X-Slack-Signature":         service.SlackSignature("not the secret", strconv.FormatInt(time.Now().Unix(), 10), []byte(body)),
+				},
+			},
+			{
+				name: "bad timestamp",
+				header: map[string]string{
+					"X-Slack-Request-Timestamp": "yesterday",
+					"X-Slack-Signature":         service.SlackSignature(secret, "yesterday", []byte(body)),
+				},
+			},
+			{
+				name:   "tampered",
+				header: signed(time.Now(), slackPayload("U0ADMIN", "help")),
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rsp := send(t, tt.header, body)
+				defer rsp.Body.Close()
+
+				if rsp.StatusCode != http.StatusUnauthorized {
+					t.Fatalf("want http %d, got http %d", http.StatusUnauthorized, rsp.StatusCode)
+				}
+			})
+		}
+
+		facts, err := r.Facts(context.TODO(), service.FactsQuery{Search: "forged", Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(facts) != 0 {
+			t.Errorf("want no forged facts, got %+v", facts)
+		}
+	})
+}
+
+// TestSlackTestVector checks the signature verification against the
+// example in Slack's documentation, rather than against SlackSignature.
+func TestSlackTestVector(t *testing.T) {
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	const (
+		secret    = "8f742231b10e8888abcd99yyyzzz85a5"
+		timestamp = "1531420618"
+		body      = "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+		signature = "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+	)
+
+	if got := service.SlackSignature(secret, timestamp, []byte(body)); got != signature {
+		t.Errorf("want signature %s, got %s", signature, got)
+	}
+
+	ts := httptest.NewServer(service.New(r,
+		service.WithSlack(secret),
+		service.WithClock(func() time.Time { return time.Unix(1531420618, 0) }),
+	).Routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		signature  string
+		wantStatus int
+	}{
+		{name: "documented signature", signature: signature, wantStatus: http.StatusOK},
+		{name: "altered signature", signature: "v0=b2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/slack/command", strings.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+			req.Header.Set("X-Slack-Signature", tt.signature)
+
+			rsp, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rsp.Body.Close()
+
+			if rsp.StatusCode != tt.wantStatus {
+				t.Fatalf("want http %d, got http %d", tt.wantStatus, rsp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestDiscord(t *testing.T) {
+	var longCitations []service.Citation
+	for i := 0; i < 16; i++ {
+		longCitations = append(longCitations, service.Citation{
+			URL:   fmt.Sprintf("https://example.com/octopus/%d", i),
+			Title: strings.Repeat("ö", 100),
+		})
+	}
+
+	r, cleanup := newTestDB(t,
+		service.Fact{Content: "Octopuses have three hearts.", Source: "a unit test"},
+		service.Fact{Content: "An octopus can taste with its arms.", Citations: []service.Citation{{URL: "https://example.com/octopus", Title: "Octopus arms"}}},
+		service.Fact{Content: strings.Repeat("ö", 5000), Citations: longCitations},
+		service.Fact{Content: "Octopuses are clever.", Source: strings.Repeat("ö", 2000)},
+	)
+	defer cleanup()
+
+	public, private, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts := httptest.NewServer(service.New(r, service.WithDiscord(public)).Routes())
+	defer ts.Close()
+
+	send := func(t *testing.T, header map[string]string, body string) *http.Response {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/discord/interactions", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+
+		rsp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return rsp
+	}
+
+	signed := func(key ed25519.PrivateKey, body string) map[string]string {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		return map[string]string{
+			"X-Signature-Timestamp": timestamp,
+			"X-Signature-Ed25519":   hex.EncodeToString(ed25519.Sign(key, []byte(timestamp+body))),
+		}
+	}
+
+	t.Run("interactions", func(t *testing.T) {
+		tests := []struct {
+			name          string
+			body          string
+			wantStatus    int
+			wantType      int
+			wantEphemeral bool
+			wantContent   string
+			wantEmbed     *service.DiscordEmbed
+		}{
+			{
+				name:       "ping",
+				body:       `{"id": "1", "application_id": "2", "type": 1, "token": "t", "version": 1}`,
+				wantStatus: http.StatusOK,
+				wantType:   service.DiscordPong,
+			},
+			{
+				name:       "random",
+				body:       `{"id": "1", "application_id": "2", "type": 2, "data": {"id": "3", "name": "fact", "type": 1}, "token": "t", "version": 1}`,
+				wantStatus: http.StatusOK,
+				wantType:   service.DiscordChannelMessageWithSource,
+			},
+			{
+				name:       "by id",
+				body:       `{"id": "1", "application_id": "2", "type": 2, "data": {"id": "3", "name": "fact", "type": 1, "options": [{"name": "id", "type": 4, "value": 1}]}, "token": "t", "version": 1}`,
+				wantStatus: http.StatusOK,
+				wantType:   service.DiscordChannelMessageWithSource,
+				wantEmbed: &service.DiscordEmbed{
+					Title:       "Fact 1",
+					Description: "Octopuses have three hearts.",
+					Fields:      []service.DiscordEmbedField{{Name: "Source", Value: "a unit test"}},
+				},
+			},
+			{
+				name:       "citations",
+				body:       `{"id": "1", "application_id": "2", "type": 2, "data": {"id": "3", "name": "fact", "type": 1, "options": [{"name": "id", "type": 4, "value": 2}]}, "token": "t", "version": 1}`,
+				wantStatus: http.StatusOK,
+				wantType:   service.DiscordChannelMessageWithSource,
+				wantEmbed: &service.DiscordEmbed{
+					Title:       "Fact 2",
+					Description: "An octopus can taste with its arms.",
+					URL:         "https://example.com/octopus",
+					Fields:      []service.DiscordEmbedField{{Name: "Sources", Value: "[Octopus arms](https://example.com/octopus)"}},
+				},
+			},
+			{
+				name:          "unknown id",
+				body:          `{"id": "1", "application_id": "2", "type": 2, "data": {"id": "3", "name": "fact", "type": 1, "options": [{"name": "id", "type": 4, "value": 999}]}, "token": "t", "version": 1}`,
+				wantStatus:    http.StatusOK,
+				wantType:      service.DiscordChannelMessageWithSource,
+				wantEphemeral: true,
+				wantContent:   "There's no fact 999.",
+			},
+			{
+				name:          "unknown option",
+				body:          `{"id": "1", "application_id": "2", "type": 2, "data": {"id": "3", "name": "fact", "type": 1, "options": [{"name": "colour", "type": 3, "value": "blue"}]}, "token": "t", "version": 1}`,
+				wantStatus:    http.StatusOK,
+				wantType:      service.DiscordChannelMessageWithSource,
+				wantEphemeral: true,
+				wantContent:   `/fact doesn't have a "colour" option.`,
+			},
+			{
+				name:       "unknown command",
+				body:       `{"id": "1", "application_id": "2", "type": 2, "data": {"id": "3", "name": "joke", "type": 1}, "token": "t", "version": 1}`,
+				wantStatus: http.StatusBadRequest,
+			},
+			{
+				name:       "unsupported interaction",
+				body:       `{"id": "1", "application_id": "2", "type": 3, "data": {"custom_id": "button"}, "token": "t", "version": 1}`,
+				wantStatus: http.StatusBadRequest,
+			},
+			{
+				name:       "malformed",
+				body:       `{"type": `,
+				wantStatus: http.StatusBadRequest,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rsp := send(t, signed(private, tt.body), tt.body)
+				defer rsp.Body.Close()
+
+				if rsp.StatusCode != tt.wantStatus {
+					t.Fatalf("want http %d, got http %d", tt.wantStatus, rsp.StatusCode)
+				}
+				if tt.wantStatus != http.StatusOK {
+					return
+				}
+
+				var got service.DiscordResponse
+				if err := json.NewDecoder(rsp.Body).Decode(&got); err != nil {
+					t.Fatal(err)
+				}
+
+				if got.Type != tt.wantType {
+					t.Errorf("want type %d, got %d", tt.wantType, got.Type)
+				}
+				if tt.wantType == service.DiscordPong {
+					if got.Data != nil {
+						t.Errorf("want no data, got %+v", got.Data)
+					}
+					return
+				}
+				if got.Data == nil {
+					t.Fatal("want data, got nothing")
+				}
+
+				if ephemeral := got.Data.Flags&service.DiscordEphemeral != 0; ephemeral != tt.wantEphemeral {
+					t.Errorf("want ephemeral %v, got %v", tt.wantEphemeral, ephemeral)
+				}
+				if tt.wantEphemeral {
+					if got.Data.Content != tt.wantContent {
+						t.Errorf("want content %q, got %q", tt.wantContent, got.Data.Content)
+					}
+					return
+				}
+
+				if len(got.Data.Embeds) != 1 {
+					t.Fatalf("want 1 embed, got %d", len(got.Data.Embeds))
+				}
+				embed := got.Data.Embeds[0]
+				if embed.Timestamp == nil {
+					t.Errorf("want a timestamp, got nothing")
+				}
+				embed.Timestamp = nil
+
+				if tt.wantEmbed != nil && !reflect.DeepEqual(embed, *tt.wantEmbed) {
+					t.Errorf("want embed %+v, got %+v", *tt.wantEmbed, embed)
+				}
+				if tt.wantEmbed == nil && !strings.HasPrefix(embed.Title, "Fact ") {
+					t.Errorf("want a fact, got %+v", embed)
+				}
+			})
+		}
+	})
+
+	t.Run("oversized", func(t *testing.T) {
+		tests := []struct {
+			id        int
+			field     string
+			wantLines int
+		}{
+			{id: 3, field: "Sources", wantLines: 8},
+			{id: 4, field: "Source", wantLines: 1},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.field, func(t *testing.T) {
+				body := fmt.Sprintf(`{"id": "1", "application_id": "2", "type": 2, "data": {"id": "3", "name": "fact", "type": 1, "options": [{"name": "id", "type": 4, "value": %d}]}, "token": "t", "version": 1}`, tt.id)
+				rsp := send(t, signed(private, body), body)
+				defer rsp.Body.Close()
+
+				if rsp.StatusCode != http.StatusOK {
+					t.Fatalf("want http %d, got http %d", http.StatusOK, rsp.StatusCode)
+				}
+
+				var got service.DiscordResponse
+				if err := json.NewDecoder(rsp.Body).Decode(&got); err != nil {
+					t.Fatal(err)
+				}
+				if got.Data == nil || len(got.Data.Embeds) != 1 {
+					t.Fatalf("want 1 embed, got %+v", got.Data)
+				}
+				embed := got.Data.Embeds[0]
+
+				if n := utf8.RuneCountInString(embed.Description); n > 4096 {
+					t.Errorf("want a description of at most 4096 characters, got %d", n)
+				}
+				if !utf8.ValidString(embed.Description) {
+					t.Errorf("want valid UTF-8, got %q", embed.Description)
+				}
+				if tt.id == 3 && !strings.HasSuffix(embed.Description, "ö…") {
+					t.Errorf("want the description to end with an ellipsis, got %q", embed.Description)
+				}
+
+				if len(embed.Fields) != 1 || embed.Fields[0].Name != tt.field {
+					t.Fatalf("want a %q field, got %+v", tt.field, embed.Fields)
+				}
+				value := embed.Fields[0].Value
+				if n := utf8.RuneCountInString(value); n > 1024 {
+					t.Errorf("want a field value of at most 1024 characters, got %d", n)
+				}
+				if !utf8.ValidString(value) {
+					t.Errorf("want valid UTF-8, got %q", value)
+				}
+				if !strings.HasSuffix(value, "…") {
+					t.Errorf("want the field value to end with an ellipsis, got %q", value)
+				}
+				if lines := strings.Count(value, "\n") + 1; lines != tt.wantLines {
+					t.Errorf("want %d lines, got %d", tt.wantLines, lines)
+				}
+			})
+		}
+	})
+
+	t.Run("verification", func(t *testing.T) {
+		body := `{"id": "1", "application_id": "2", "type": 1, "token": "t", "version": 1}`
+
+		_, otherKey, err := ed25519.GenerateKey(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name   string
+			header map[string]string
+		}{
+			{
+				name: "unsigned",
+			},
+			{
+				name:   "wrong key",
+				header: signed(otherKey, body),
+			},
+			{
+				name:   "tampered",
+				header: signed(private, `{"type": 2}`),
+			},
+			{
+				name: "not hex",
+				header: map[string]string{
+					"X-Signature-Timestamp": "1",
+					"X-Signature-Ed25519":   "not a signature",
+				},
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rsp := send(t, tt.header, body)
+				defer rsp.Body.Close()
+
+				if rsp.StatusCode != http.StatusUnauthorized {
+					t.Fatalf("want http %d, got http %d", http.StatusUnauthorized, rsp.StatusCode)
+				}
+			})
+		}
+	})
+
+	t.Run("register", func(t *testing.T) {
+		var (
+			gotMethod, gotPath, gotAuth string
+			gotCommands                 []service.DiscordCommand
+		)
+		discord := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotMethod, gotPath, gotAuth = r.Method, r.URL.Path, r.Header.Get("Authorization")
+			if err := json.NewDecoder(r.Body).Decode(&gotCommands); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if gotAuth != "Bot token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				fmt.Fprint(w, `{"message": "401: Unauthorized", "code": 0}`)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `[]`)
+		}))
+		defer discord.Close()
+
+		err := service.RegisterDiscordCommands(context.TODO(), discord.Client(), discord.URL, "1234", "token", service.DiscordFactCommand)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if gotMethod != http.MethodPut {
+			t.Errorf("want method %s, got %s", http.MethodPut, gotMethod)
+		}
+		if want := "/applications/1234/commands"; gotPath != want {
+			t.Errorf("want path %s, got %s", want, gotPath)
+		}
+		if !reflect.DeepEqual(gotCommands, []service.DiscordCommand{service.DiscordFactCommand}) {
+			t.Errorf("want commands %+v, got %+v", []service.DiscordCommand{service.DiscordFactCommand}, gotCommands)
+		}
+
+		err = service.RegisterDiscordCommands(context.TODO(), discord.Client(), discord.URL, "1234", "wrong", service.DiscordFactCommand)
+		if err == nil || !strings.Contains(err.Error(), "401") {
+			t.Errorf("want an http 401 error, got %v", err)
+		}
+	})
+}
+
+// rss is the part of RSS 2.0 that feeds must get right.
+type rss struct {
+	XMLName xml.Name `xml:"rss"`
+	Version string   `xml:"version,attr"`
+	Channel struct {
+		Title string `xml:"title"`
+
+		// Links include atom:link, since names match in any namespace.
+		Links []struct {
+			XMLName xml.Name
+			Value   string `xml:",chardata"`
+		} `xml:"link"`
+
+		Description string `xml:"description"`
+		Items       []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			Description string `xml:"description"`
+			GUID        struct {
+				IsPermaLink string `xml:"isPermaLink,attr"`
+				Value       string `xml:",chardata"`
+			} `xml:"guid"`
+			PubDate string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+// validate checks the feed has what the RSS 2.0 specification requires.
+func (feed rss) validate() error {
+	if feed.Version != "2.0" {
+		return fmt.Errorf("version %q", feed.Version)
+	}
+	if feed.Channel.Title == "" || feed.Channel.Description == "" {
+		return errors.New("channel without a title or description")
+	}
+	var link string
+	for _, l := range feed.Channel.Links {
+		if l.XMLName.Space == "" {
+			link = l.Value
+		}
+	}
+	if _, err := url.ParseRequestURI(link); err != nil {
+		return fmt.Errorf("channel link: %w", err)
+	}
+
+	guids := make(map[string]bool)
+	for _, item := range feed.Channel.Items {
+		if item.Title == "" && item.Description == "" {
+			return errors.New("item without a title or description")
+		}
+		if _, err := url.ParseRequestURI(item.Link); err != nil {
+			return fmt.Errorf("item link: %w", err)
+		}
+		if _, err := time.Parse(time.RFC1123Z, item.PubDate); err != nil {
+			return fmt.Errorf("item pubDate: %w", err)
+		}
+		if item.GUID.Value == "" || guids[item.GUID.Value] {
+			return fmt.Errorf("item guid %q is blank or repeated", item.GUID.Value)
+		}
+		guids[item.GUID.Value] = true
+	}
+	return nil
+}
+
+// atom is the part of Atom that feeds must get right.
+type atom struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Authors []struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Links []struct {
+		Rel  string `xml:"rel,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+	Entries []struct {
+		ID        string `xml:"id"`
+		Title     string `xml:"title"`
+		Updated   string `xml:"updated"`
+		Published string `xml:"published"`
+		Link      struct {
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+		Content struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"content"`
+	} `xml:"entry"`
+}
+
+// validate checks the feed has what RFC 4287 requires.
+func (feed atom) validate() error {
+	if feed.ID == "" || feed.Title == "" {
+		return errors.New("feed without an id or title")
+	}
+	if _, err := url.Parse(feed.ID); err != nil {
+		return fmt.Errorf("feed id: %w", err)
+	}
+	if _, err := time.Parse(time.RFC3339, feed.Updated); err != nil {
+		return fmt.Errorf("feed updated: %w", err)
+	}
+	if len(feed.Authors) == 0 || feed.Authors[0].Name == "" {
+		return errors.New("feed without an author")
+	}
+
+	var self bool
+	for _, l := range feed.Links {
+		self = self || l.Rel == "self"
+	}
+	if !self {
+		return errors.New("feed without a self link")
+	}
+
+	ids := make(map[string]bool)
+	for _, e := range feed.Entries {
+		if e.ID == "" || ids[e.ID] {
+			return fmt.Errorf("entry id %q is blank or repeated", e.ID)
+		}
+		ids[e.ID] = true
+
+		if e.Title == "" {
+			return errors.New("entry without a title")
+		}
+		for _, t := range []string{e.Updated, e.Published} {
+			if _, err := time.Parse(time.RFC3339, t); err != nil {
+				return fmt.Errorf("entry date: %w", err)
+			}
+		}
+		if e.Content.Type != "text" {
+			return fmt.Errorf("entry content type %q", e.Content.Type)
+		}
+	}
+	return nil
+}
+
+func TestFeeds(t *testing.T) {
+	r, cleanup := newTestDB(t,
+		service.Fact{Content: "Octopuses have three hearts.", Source: "a unit test"},
+		service.Fact{Content: "Honey <never> spoils & keeps.", Source: "a unit test"},
+		service.Fact{Content: "Bananas are berries.", Source: "a unit test"},
+	)
+	defer cleanup()
+
+	ts := httptest.NewServer(service.New(r, service.WithBaseURL("https://factoid.example.com/")).Routes())
+	defer ts.Close()
+
+	get := func(t *testing.T, path string, header http.Header, wantStatus int) (*http.Response, []byte) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		rsp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		body, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rsp.StatusCode != wantStatus {
+			t.Fatalf("want http %d, got http %d: %s", wantStatus, rsp.StatusCode, body)
+		}
+		return rsp, body
+	}
+
+	t.Run("rss", func(t *testing.T) {
+		rsp, body := get(t, "/v1/facts.rss?limit=2", nil, http.StatusOK)
+		if got := rsp.Header.Get("Content-Type"); got != "application/rss+xml" {
+			t.Errorf("want Content-Type %q, got %q", "application/rss+xml", got)
+		}
+
+		var feed rss
+		if err := xml.Unmarshal(body, &feed); err != nil {
+			t.Fatal(err)
+		}
+		if err := feed.validate(); err != nil {
+			t.Fatalf("want a valid feed, got %v:\n%s", err, body)
+		}
+
+		items := feed.Channel.Items
+		if len(items) != 2 {
+			t.Fatalf("want 2 items, got %d", len(items))
+		}
+
+		// Newest first.
+		if items[0].GUID.Value != "urn:factoid:fact:3" || items[1].GUID.Value != "urn:factoid:fact:2" {
+			t.Errorf("want facts 3 and 2, got %s and %s", items[0].GUID.Value, items[1].GUID.Value)
+		}
+		if items[0].GUID.IsPermaLink != "false" {
+			t.Errorf("want a guid that isn't a permalink, got isPermaLink=%q", items[0].GUID.IsPermaLink)
+		}
+		if want := "https://factoid.example.com/fact/3"; items[0].Link != want {
+			t.Errorf("want link %q, got %q", want, items[0].Link)
+		}
+		if want := "Honey <never> spoils & keeps."; items[1].Description != want {
+			t.Errorf("want description %q, got %q", want, items[1].Description)
+		}
+	})
+
+	t.Run("atom", func(t *testing.T) {
+		rsp, body := get(t, "/v1/facts.atom", nil, http.StatusOK)
+		if got := rsp.Header.Get("Content-Type"); got != "application/atom+xml" {
+			t.Errorf("want Content-Type %q, got %q", "application/atom+xml", got)
+		}
+
+		var feed atom
+		if err := xml.Unmarshal(body, &feed); err != nil {
+			t.Fatal(err)
+		}
+		if err := feed.validate(); err != nil {
+			t.Fatalf("want a valid feed, got %v:\n%s", err, body)
+		}
+
+		if len(feed.Entries) != 3 {
+			t.Fatalf("want 3 entries, got %d", len(feed.Entries))
+		}
+		for i, want := range []string{"urn:factoid:fact:3", "urn:factoid:fact:2", "urn:factoid:fact:1"} {
+			if feed.Entries[i].ID != want {
+				t.Errorf("want entry %d to be %s, got %s", i, want, feed.Entries[i].ID)
+			}
+		}
+		if want := "https://factoid.example.com/fact/3"; feed.Entries[0].Link.Href != want {
+			t.Errorf("want link %q, got %q", want, feed.Entries[0].Link.Href)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		r, cleanup := newTestDB(t)
+		defer cleanup()
+
+		ts := httptest.NewServer(service.New(r).Routes())
+		defer ts.Close()
+
+		for _, tt := range []struct {
+			path string
+			feed interface{ validate() error }
+		}{
+			{path: "/v1/facts.rss", feed: &rss{}},
+			{path: "/v1/facts.atom", feed: &atom{}},
+		} {
+			rsp, err := ts.Client().Get(ts.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rsp.Body.Close()
+
+			if err := xml.NewDecoder(rsp.Body).Decode(tt.feed); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.feed.validate(); err != nil {
+				t.Errorf("%s: want a valid feed, got %v", tt.path, err)
+			}
+		}
+	})
+
+	for _, path := range []string{"/v1/facts.rss", "/v1/facts.atom"} {
+		t.Run("conditional "+path, func(t *testing.T) {
+			rsp, _ := get(t, path, nil, http.StatusOK)
+
+			tag := rsp.Header.Get("ETag")
+			modified := rsp.Header.Get("Last-Modified")
+			if tag == "" || modified == "" {
+				t.Fatalf("want ETag and Last-Modified, got %q and %q", tag, modified)
+			}
+			if got := rsp.Header.Get("Cache-Control"); got != "no-cache" {
+				t.Errorf("want Cache-Control %q, got %q", "no-cache", got)
+			}
+
+			get(t, path, http.Header{"If-None-Match": {tag}}, http.StatusNotModified)
+			get(t, path, http.Header{"If-Modified-Since": {modified}}, http.StatusNotModified)
+			get(t, path, http.Header{"If-None-Match": {`"stale"`}}, http.StatusOK)
+		})
+	}
+
+	t.Run("guids survive edits", func(t *testing.T) {
+		rsp, before := get(t, "/v1/facts.rss", nil, http.StatusOK)
+		tag := rsp.Header.Get("ETag")
+
+		content := "Octopuses have three hearts and blue blood."
+		if _, err := r.UpdateFact(context.TODO(), 1, service.FactUpdate{Content: &content}); err != nil {
+			t.Fatal(err)
+		}
+
+		rsp, after := get(t, "/v1/facts.rss", http.Header{"If-None-Match": {tag}}, http.StatusOK)
+		if rsp.Header.Get("ETag") == tag {
+			t.Errorf("want the ETag to change after an edit, got %q both times", tag)
+		}
+
+		var feedBefore, feedAfter rss
+		if err := xml.Unmarshal(before, &feedBefore); err != nil {
+			t.Fatal(err)
+		}
+		if err := xml.Unmarshal(after, &feedAfter); err != nil {
+			t.Fatal(err)
+		}
+
+		last := len(feedAfter.Channel.Items) - 1
+		if got, want := feedAfter.Channel.Items[last].GUID.Value, feedBefore.Channel.Items[last].GUID.Value; got != want {
+			t.Errorf("want guid %q, got %q", want, got)
+		}
+		if got := feedAfter.Channel.Items[last].Description; got != content {
+			t.Errorf("want description %q, got %q", content, got)
+		}
+	})
+}
+
+func TestPages(t *testing.T) {
+	facts := []service.Fact{
+		{Content: "Octopuses have three hearts.", Citations: []service.Citation{{URL: "https://example.com/octopus", Title: "Octopus facts"}}},
+		{Content: `Honey <script>alert("never")</script> spoils.`, Source: "a unit test"},
+	}
+	for i := len(facts); i < 25; i++ {
+		facts = append(facts, service.Fact{Content: fmt.Sprintf("Filler fact %d.", i+1)})
+	}
+
+	r, cleanup := newTestDB(t, facts...)
+	defer cleanup()
+
+	ts := httptest.NewServer(service.New(r, service.WithBaseURL("https://factoid.example.com/")).Routes())
+	defer ts.Close()
+
+	get := func(t *testing.T, path string, header http.Header, wantStatus int) (*http.Response, string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		rsp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		body, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rsp.StatusCode != wantStatus {
+			t.Fatalf("want http %d, got http %d: %s", wantStatus, rsp.StatusCode, body)
+		}
+		return rsp, string(body)
+	}
+
+	page := func(t *testing.T, path string, wantStatus int) string {
+		t.Helper()
+
+		rsp, body := get(t, path, nil, wantStatus)
+		if got := rsp.Header.Get("Content-Type"); got != "text/html; charset=utf-8" {
+			t.Errorf("want Content-Type %q, got %q", "text/html; charset=utf-8", got)
+		}
+		if strings.Contains(body, "<script") {
+			t.Errorf("want no scripts, got %s", body)
+		}
+		return body
+	}
+
+	// contains checks body has each of want.
+	contains := func(t *testing.T, body string, want ...string) {
+		t.Helper()
+
+		for _, w := range want {
+			if !strings.Contains(body, w) {
+				t.Errorf("want %q in the page, got %s", w, body)
+			}
+		}
+	}
+
+	t.Run("home", func(t *testing.T) {
+		body := page(t, "/?not=1", http.StatusOK)
+		contains(t, body,
+			`<meta property="og:type" content="website">`,
+			`<link rel="canonical" href="https://factoid.example.com/">`,
+			`<a class="button" href="/?not=`,
+		)
+		if strings.Contains(body, "Octopuses have three hearts.") {
+			t.Errorf("want a fact other than 1, got %s", body)
+		}
+	})
+
+	t.Run("fact", func(t *testing.T) {
+		body := page(t, "/fact/1", http.StatusOK)
+		contains(t, body,
+			"<title>Fact #1 · factoid</title>",
+			`<link rel="canonical" href="https://factoid.example.com/fact/1">`,
+			`<meta property="og:type" content="article">`,
+			`<meta property="og:title" content="Fact #1">`,
+			`<meta property="og:description" content="Octopuses have three hearts.">`,
+			`<meta property="og:url" content="https://factoid.example.com/fact/1">`,
+			`<meta name="twitter:card" content="summary">`,
+			`<meta name="twitter:description" content="Octopuses have three hearts.">`,
+			`<a href="https://example.com/octopus" rel="nofollow">Octopus facts</a>`,
+		)
+	})
+
+	t.Run("content is escaped", func(t *testing.T) {
+		body := page(t, "/fact/2", http.StatusOK)
+		contains(t, body,
+			`Honey &lt;script&gt;alert(&#34;never&#34;)&lt;/script&gt; spoils.`,
+			"a unit test",
+		)
+	})
+
+	t.Run("unknown fact", func(t *testing.T) {
+		for _, path := range []string{"/fact/999", "/fact/one"} {
+			body := page(t, path, http.StatusNotFound)
+			contains(t, body, "There&#39;s no such fact.")
+		}
+	})
+
+	t.Run("conditional fact", func(t *testing.T) {
+		rsp, _ := get(t, "/fact/1", nil, http.StatusOK)
+
+		tag := rsp.Header.Get("ETag")
+		modified := rsp.Header.Get("Last-Modified")
+		if tag == "" || modified == "" {
+			t.Fatalf("want ETag and Last-Modified, got %q and %q", tag, modified)
+		}
+
+		get(t, "/fact/1", http.Header{"If-None-Match": {tag}}, http.StatusNotModified)
+		get(t, "/fact/1", http.Header{"If-Modified-Since": {modified}}, http.StatusNotModified)
+
+		// The page's ETag differs from the API's, since the bodies do.
+		rsp, _ = get(t, "/v1/fact/1", nil, http.StatusOK)
+		if rsp.Header.Get("ETag") == tag {
+			t.Errorf("want different ETags for the page and the API, got %q for both", tag)
+		}
+	})
+
+	t.Run("browse", func(t *testing.T) {
+		body := page(t, "/facts", http.StatusOK)
+		if got := strings.Count(body, `<figure class="fact"`); got != 20 {
+			t.Errorf("want 20 facts, got %d", got)
+		}
+		contains(t, body, `<a href="/facts?after=20" rel="next">`)
+		if strings.Contains(body, "First page") {
+			t.Errorf("want no link to the first page, got %s", body)
+		}
+
+		body = page(t, "/facts?after=20", http.StatusOK)
+		if got := strings.Count(body, `<figure class="fact"`); got != 5 {
+			t.Errorf("want 5 facts, got %d", got)
+		}
+		contains(t, body, `<a href="/facts">First page</a>`, "Filler fact 25.")
+		if strings.Contains(body, `rel="next"`) {
+			t.Errorf("want no link to a next page, got %s", body)
+		}
+
+		page(t, "/facts?after=-1", http.StatusNotFound)
+	})
+
+	t.Run("search", func(t *testing.T) {
+		body := page(t, "/search?q=HEARTS", http.StatusOK)
+		if got := strings.Count(body, `<figure class="fact"`); got != 1 {
+			t.Errorf("want 1 fact, got %d", got)
+		}
+		contains(t, body, "Octopuses have three hearts.", `value="HEARTS"`)
+
+		body = page(t, "/search?q=filler", http.StatusOK)
+		contains(t, body, `<a href="/search?after=22&amp;q=filler" rel="next">`)
+
+		body = page(t, "/search?q=filler&after=22", http.StatusOK)
+		if got := strings.Count(body, `<figure class="fact"`); got != 3 {
+			t.Errorf("want 3 facts, got %d", got)
+		}
+		contains(t, body, `<a href="/search?q=filler">First page</a>`)
+
+		body = page(t, "/search?q=giraffe", http.StatusOK)
+		contains(t, body, "No facts mention")
+
+		body = page(t, "/search", http.StatusOK)
+		contains(t, body, "Type a word or two")
+		if strings.Contains(body, `<figure class="fact"`) {
+			t.Errorf("want no facts without a query, got %s", body)
+		}
+	})
+
+	t.Run("static", func(t *testing.T) {
+		rsp, _ := get(t, "/static/site.css", nil, http.StatusOK)
+		if got := rsp.Header.Get("Content-Type"); !strings.HasPrefix(got, "text/css") {
+			t.Errorf("want Content-Type text/css, got %q", got)
+		}
+
+		get(t, "/static/missing.css", nil, http.StatusNotFound)
+	})
+}
+
+func TestAdmin(t *testing.T) {
+	r, cleanup := newTestDB(t, service.Fact{
+		Content:   "Octopuses have three hearts.",
+		Citations: []service.Citation{{URL: "https://example.com/octopus", Title: "Octopuses", Author: "A. Marine Biologist"}},
+	})
+	defer cleanup()
+
+	ts := httptest.NewServer(service.New(r, service.WithAuthorizer("secret")).Routes())
+	defer ts.Close()
+
+	doc := getOpenAPI(t, ts)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	send := func(t *testing.T, req *http.Request, wantStatus int) (*http.Response, string) {
+		t.Helper()
+
+		rsp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rsp.Body.Close()
+
+		body, err := io.ReadAll(rsp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rsp.StatusCode != wantStatus {
+			t.Fatalf("want http %d, got http %d: %s", wantStatus, rsp.StatusCode, body)
+		}
+
+		responses, ok := doc.operation(t, req.Method, req.URL.Path)
+		if !ok {
+			t.Fatalf("want %s %s documented, got nothing", req.Method, req.URL.Path)
+		}
+		if _, ok := responses[strconv.Itoa(rsp.StatusCode)]; !ok {
+			t.Errorf("want status %d documented, got %v", rsp.StatusCode, keys(responses))
+		}
+		return rsp, string(body)
+	}
+
+	get := func(t *testing.T, path string, wantStatus int) (*http.Response, string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return send(t, req, wantStatus)
+	}
+
+	post := func(t *testing.T, path string, form url.Values, wantStatus int) (*http.Response, string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, ts.URL+path, strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return send(t, req, wantStatus)
+	}
+
+	wantLocation := func(t *testing.T, rsp *http.Response, want string) {
+		t.Helper()
+
+		if got := rsp.Header.Get("Location"); got != want {
+			t.Errorf("want Location %q, got %q", want, got)
+		}
+	}
+
+	contains := func(t *testing.T, body string, want ...string) {
+		t.Helper()
+
+		for _, w := range want {
+			if !strings.Contains(body, w) {
+				t.Errorf("want %q in the page, got %s", w, body)
+			}
+		}
+	}
+
+	factCount := func(t *testing.T) int {
+		t.Helper()
+
+		facts, err := r.Facts(context.TODO(), service.FactsQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(facts)
+	}
+
+	csrfField := regexp.MustCompile(`name="csrf" value="([0-9a-f]+)"`)
+	var csrf, session string
+
+	t.Run("signed out", func(t *testing.T) {
+		rsp, _ := get(t, "/admin/fact/1", http.StatusSeeOther)
+		wantLocation(t, rsp, "/admin/login?return=%2Fadmin%2Ffact%2F1")
+
+		post(t, "/admin/new", url.Values{"content": {"a forged fact"}}, http.StatusForbidden)
+		if got := factCount(t); got != 1 {
+			t.Errorf("want 1 fact, got %d", got)
+		}
+	})
+
+	t.Run("sign in", func(t *testing.T) {
+		_, body := post(t, "/admin/login", url.Values{"secret": {"wrong"}}, http.StatusUnauthorized)
+		contains(t, body, "That&#39;s not the secret.")
+
+		rsp, _ := post(t, "/admin/login", url.Values{"secret": {"secret"}, "return": {"/admin/fact/1"}}, http.StatusSeeOther)
+		wantLocation(t, rsp, "/admin/fact/1")
+
+		cookies := rsp.Cookies()
+		if len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].SameSite != http.SameSiteLaxMode || cookies[0].Path != "/admin" {
+			t.Fatalf("want an HttpOnly, SameSite=Lax cookie for /admin, got %v", rsp.Header.Values("Set-Cookie"))
+		}
+		session = cookies[0].Value
+
+		// Only admin pages are returned to.
+		rsp, _ = post(t, "/admin/login", url.Values{"secret": {"secret"}, "return": {"https://evil.example.com/"}}, http.StatusSeeOther)
+		wantLocation(t, rsp, "/admin")
+	})
+
+	t.Run("edit page", func(t *testing.T) {
+		rsp, body := get(t, "/admin/fact/1", http.StatusOK)
+		if got := rsp.Header.Get("Cache-Control"); got != "no-store" {
+			t.Errorf("want Cache-Control %q, got %q", "no-store", got)
+		}
+		if got := rsp.Header.Get("X-Frame-Options"); got != "DENY" {
+			t.Errorf("want X-Frame-Options %q, got %q", "DENY", got)
+		}
+		contains(t, body,
+			"Octopuses have three hearts.</textarea>",
+			"https://example.com/octopus Octopuses\n</textarea>",
+			`name="version" value="1"`,
+		)
+
+		m := csrfField.FindStringSubmatch(body)
+		if m == nil {
+			t.Fatalf("want a CSRF token, got %s", body)
+		}
+		csrf = m[1]
+	})
+
+	t.Run("forms need the csrf token", func(t *testing.T) {
+		post(t, "/admin/new", url.Values{"content": {"a forged fact"}}, http.StatusForbidden)
+		post(t, "/admin/new", url.Values{"csrf": {strings.Repeat("0", len(csrf))}, "content": {"a forged fact"}}, http.StatusForbidden)
+		if got := factCount(t); got != 1 {
+			t.Errorf("want 1 fact, got %d", got)
+		}
+	})
+
+	t.Run("create", func(t *testing.T) {
+		_, body := post(t, "/admin/new", url.Values{"csrf": {csrf}, "content": {" "}}, http.StatusBadRequest)
+		contains(t, body, "content field missing or blank")
+
+		_, body = post(t, "/admin/new", url.Values{"csrf": {csrf}, "content": {"Honey <never> spoils."}, "weight": {"-1"}}, http.StatusBadRequest)
+		contains(t, body, "weight must not be negative", "Honey &lt;never&gt; spoils.</textarea>")
+
+		rsp, _ := post(t, "/admin/new", url.Values{
+			"csrf":      {csrf},
+			"content":   {"Honey <never> spoils."},
+			"citations": {"https://example.com/honey Honey facts\n\n"},
+			"weight":    {"2"},
+		}, http.StatusSeeOther)
+		wantLocation(t, rsp, "/admin/fact/2?done=created")
+
+		f, err := r.Fact(context.TODO(), 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []service.Citation{{URL: "https://example.com/honey", Title: "Honey facts"}}
+		if f.Content != "Honey <never> spoils." || f.Weight != 2 || !reflect.DeepEqual(f.Citations, want) {
+			t.Errorf("want the fact that was sent, got %+v", f)
+		}
+
+		_, body = get(t, "/admin/fact/2?done=created", http.StatusOK)
+		contains(t, body, "Fact created.")
+	})
+
+	t.Run("edit", func(t *testing.T) {
+		form := url.Values{
+			"csrf":      {csrf},
+			"version":   {"1"},
+			"content":   {"Octopuses have three hearts and blue blood."},
+			"citations": {"https://example.com/octopus Octopus anatomy"},
+			"weight":    {"1"},
+		}
+		rsp, _ := post(t, "/admin/fact/1", form, http.StatusSeeOther)
+		wantLocation(t, rsp, "/admin/fact/1?done=saved")
+
+		f, err := r.Fact(context.TODO(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []service.Citation{{URL: "https://example.com/octopus", Title: "Octopus anatomy", Author: "A. Marine Biologist"}}
+		if f.Content != form.Get("content") || f.Version != 2 || !reflect.DeepEqual(f.Citations, want) {
+			t.Errorf("want the changes saved and the citation's author kept, got %+v", f)
+		}
+
+		// The form was shown before the change above.
+		form.Set("content", "Octopuses have two hearts.")
+		_, body := post(t, "/admin/fact/1", form, http.StatusConflict)
+		contains(t, body, "Someone else changed this fact")
+
+		post(t, "/admin/fact/999", form, http.StatusNotFound)
+		get(t, "/admin/fact/999", http.StatusNotFound)
+
+		for _, version := range []string{"", "two", "0", "-1"} {
+			form.Set("version", version)
+			_, body := post(t, "/admin/fact/1", form, http.StatusBadRequest)
+			contains(t, body, "weren&#39;t saved")
+		}
+	})
+
+	t.Run("delete and restore", func(t *testing.T) {
+		for _, version := range []string{"", "two", "0", "-1"} {
+			post(t, "/admin/fact/1/delete", url.Values{"csrf": {csrf}, "version": {version}}, http.StatusBadRequest)
+		}
+		post(t, "/admin/fact/1/delete", url.Values{"csrf": {csrf}}, http.StatusBadRequest)
+		post(t, "/admin/fact/1/delete", url.Values{"csrf": {csrf}, "version": {"1"}}, http.StatusConflict)
+
+		rsp, _ := post(t, "/admin/fact/1/delete", url.Values{"csrf": {csrf}, "version": {"2"}}, http.StatusSeeOther)
+		wantLocation(t, rsp, "/admin/deleted?done=deleted")
+		if _, err := r.Fact(context.TODO(), 1); !errors.Is(err, service.ErrNotFound) {
+			t.Fatalf("want %v, got %v", service.ErrNotFound, err)
+		}
+
+		_, body := get(t, "/admin/deleted", http.StatusOK)
+		contains(t, body, "Octopuses have three hearts and blue blood.", `action="/admin/fact/1/restore"`)
+		if strings.Contains(body, "Honey") {
+			t.Errorf("want only deleted facts, got %s", body)
+		}
+
+		rsp, _ = post(t, "/admin/fact/1/restore", url.Values{"csrf": {csrf}}, http.StatusSeeOther)
+		wantLocation(t, rsp, "/admin/fact/1?done=restored")
+
+		f, err := r.Fact(context.TODO(), 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Citations) != 1 {
+			t.Errorf("want the citation restored too, got %+v", f.Citations)
+		}
+
+		post(t, "/admin/fact/1/restore", url.Values{"csrf": {csrf}}, http.StatusNotFound)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		_, body := post(t, "/admin/import", url.Values{"csrf": {csrf}, "facts": {`[{"content": "Bananas are berries."}, {"content": ""}]`}}, http.StatusBadRequest)
+		contains(t, body, "1 of 2 facts are invalid", "Fact 2: content field missing or blank")
+		if got := factCount(t); got != 2 {
+			t.Errorf("want 2 facts, got %d", got)
+		}
+
+		post(t, "/admin/import", url.Values{"csrf": {csrf}, "facts": {"  "}}, http.StatusBadRequest)
+
+		// Files are uploaded as multipart forms.
+		var buf strings.Builder
+		mw := multipart.NewWriter(&buf)
+		mw.WriteField("csrf", csrf)
+		mw.WriteField("facts", "")
+		fw, err := mw.CreateFormFile("file", "facts.ndjson")
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(fw, "{\"content\": \"Bananas are berries.\"}\n{\"content\": \"Sloths can hold their breath longer than dolphins.\"}\n")
+		mw.Close()
+
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/admin/import", strings.NewReader(buf.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		rsp, _ := send(t, req, http.StatusSeeOther)
+		wantLocation(t, rsp, "/admin/import?imported=2")
+		if got := factCount(t); got != 4 {
+			t.Errorf("want 4 facts, got %d", got)
+		}
+
+		_, body = get(t, "/admin/import?imported=2", http.StatusOK)
+		contains(t, body, "Imported 2 facts.")
+	})
+
+	t.Run("list", func(t *testing.T) {
+		_, body := get(t, "/admin", http.StatusOK)
+		if got := strings.Count(body, `<a href="/admin/fact/`); got != 4 {
+			t.Errorf("want 4 facts, got %d", got)
+		}
+
+		_, body = get(t, "/admin?q=HONEY", http.StatusOK)
+		contains(t, body, "Honey &lt;never&gt; spoils.")
+		if strings.Contains(body, "Octopuses") {
+			t.Errorf("want only facts that mention honey, got %s", body)
+		}
+
+		get(t, "/admin?after=x", http.StatusNotFound)
+	})
+
+	t.Run("activity", func(t *testing.T) {
+		_, body := get(t, "/admin/activity", http.StatusOK)
+
+		// Newest first.
+		var order []int
+		for _, want := range []string{"Sloths", "fact.deleted", "fact.updated", "Honey"} {
+			i := strings.Index(body, want)
+			if i < 0 {
+				t.Fatalf("want %q in the page, got %s", want, body)
+			}
+			order = append(order, i)
+		}
+		if !sort.IntsAreSorted(order) {
+			t.Errorf("want the newest changes first, got %s", body)
+		}
+	})
+
+	t.Run("sign out", func(t *testing.T) {
+		rsp, _ := post(t, "/admin/logout", url.Values{"csrf": {csrf}}, http.StatusSeeOther)
+		wantLocation(t, rsp, "/admin/login")
+
+		rsp, _ = get(t, "/admin", http.StatusSeeOther)
+		wantLocation(t, rsp, "/admin/login?return=%2Fadmin")
+
+		// The session is over, even for a client that kept the cookie.
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/admin/new", strings.NewReader(url.Values{"csrf": {csrf}, "content": {"a late fact"}}.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "factoid_admin", Value: session})
+		send(t, req, http.StatusForbidden)
+	})
+}
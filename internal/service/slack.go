@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+const (
+	// maxSlackRequestSize is far more than Slack ever sends for a
+	// slash command.
+	maxSlackRequestSize = 64 << 10
+
+	// slackMaxRequestAge is how far a request's timestamp may be from
+	// the server's clock, which keeps recorded requests from being
+	// replayed later.
+	slackMaxRequestAge = 5 * time.Minute
+
+	// slackSearchLimit is the most facts shown for a search.
+	slackSearchLimit = 5
+)
+
+// Who sees a response to a slash command.
+const (
+	SlackInChannel = "in_channel"
+	SlackEphemeral = "ephemeral"
+)
+
+// WithSlack answers Slack slash commands signed with signingSecret, the
+// Slack app's signing secret. Only the Slack users with the given IDs may
+// add facts. Without a signing secret, the endpoint responds with HTTP
+// 501.
+func WithSlack(signingSecret string, authorizedUsers ...string) optionFunc {
+	return func(s *Service) {
+		s.slackSecret = signingSecret
+		s.slackUsers = make(map[string]bool)
+		for _, id := range authorizedUsers {
+			s.slackUsers[id] = true
+		}
+	}
+}
+
+// SlackMessage is a response to a slash command, laid out with Block Kit.
+// Text is shown where blocks can't be, such as in notifications.
+type SlackMessage struct {
+	ResponseType string       `json:"response_type"`
+	Text         string       `json:"text"`
+	Blocks       []SlackBlock `json:"blocks,omitempty"`
+}
+
+// SlackBlock is a Block Kit block. Section blocks have Text, and context
+// blocks have Elements.
+type SlackBlock struct {
+	Type     string      `json:"type"`
+	Text     *SlackText  `json:"text,omitempty"`
+	Elements []SlackText `json:"elements,omitempty"`
+}
+
+// SlackText is a Block Kit text object.
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// SlackHandler answers Slack slash commands:
+//
+//	/fact                 a random fact
+//	/fact 42              fact 42
+//	/fact search octopus  facts that mention octopuses
+//	/fact add ...         adds a fact, for authorized users only
+//
+// Requests must carry a valid signature. Problems with the command itself
+// are answered with a message only its sender sees, since Slack shows
+// anything other than HTTP 200 as a failure.
+func (s *Service) SlackHandler(w http.ResponseWriter, r *http.Request) {
+	if s.slackSecret == "" {
+		s.unimplemented(w, r)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSlackRequestSize))
+	if err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("bad request"))
+		return
+	}
+
+	if err := verifySlackRequest(s.slackSecret, r.Header, body, s.now()); err != nil {
+		s.RespondErrorJSON(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("bad request"))
+		return
+	}
+
+	logger := log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+		"slack_team_id", form.Get("team_id"),
+		"slack_user_id", form.Get("user_id"),
+		"slack_text", form.Get("text"),
+	)
+
+	msg, err := s.slackCommand(r.Context(), form)
+	if err != nil {
+		logger.With("err", err).Error("")
+		msg = slackNotice("Sorry, something went wrong. Please try again later.")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(msg); err != nil {
+		logger.With("err", err).Error("")
+	}
+}
+
+// verifySlackRequest checks the signature Slack makes of every request
+// with the app's signing secret.
+func verifySlackRequest(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	signature := header.Get("X-Slack-Signature")
+	if timestamp == "" || signature == "" {
+		return errors.New("request is not signed")
+	}
+
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("request timestamp is invalid")
+	}
+	if age := now.Sub(time.Unix(sec, 0)); age > slackMaxRequestAge || age < -slackMaxRequestAge {
+		return errors.New("request timestamp is too far from the current time")
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(SlackSignature(secret, timestamp, body))) {
+		return errors.New("request signature is invalid")
+	}
+	return nil
+}
+
+// SlackSignature returns the X-Slack-Signature header Slack sends with a
+// request with the given timestamp and body.
+func SlackSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// slackCommand carries out the command in form. Only failures of the
+// server are returned as errors.
+func (s *Service) slackCommand(ctx context.Context, form url.Values) (SlackMessage, error) {
+	command := form.Get("command")
+	if command == "" {
+		command = "/fact"
+	}
+	text := strings.TrimSpace(form.Get("text"))
+
+	var verb string
+	if fields := strings.Fields(text); len(fields) > 0 {
+		verb = fields[0]
+	}
+	rest := strings.TrimSpace(strings.TrimPrefix(text, verb))
+
+	usage := slackNotice(fmt.Sprintf("Try `%[1]s` for a random fact, `%[1]s 42` for fact 42, or `%[1]s search octopus` to find facts.", command))
+
+	switch verb {
+	case "":
+		facts, err := s.randomFacts(ctx, 1, RandomOptions{})
+		if errors.Is(err, ErrNotFound) {
+			return slackNotice("There are no facts yet."), nil
+		}
+		if err != nil {
+			return SlackMessage{}, err
+		}
+
+		if err := s.facts.MarkServed(ctx, facts[0].ID); err != nil {
+			return SlackMessage{}, err
+		}
+		return slackFacts(SlackInChannel, "", facts), nil
+
+	case "help":
+		return usage, nil
+
+	case "search":
+		if rest == "" {
+			return slackNotice(fmt.Sprintf("What should I look for? Try `%s search octopus`.", command)), nil
+		}
+
+		facts, err := s.facts.Facts(ctx, FactsQuery{Search: rest, Limit: slackSearchLimit})
+		if err != nil {
+			return SlackMessage{}, err
+		}
+		if len(facts) == 0 {
+			return slackNotice(fmt.Sprintf("No facts mention “%s”.", slackEscape(rest))), nil
+		}
+
+		heading := fmt.Sprintf("Facts that mention “%s”:", slackEscape(rest))
+		if len(facts) == slackSearchLimit {
+			heading = fmt.Sprintf("The first %d facts that mention “%s”:", slackSearchLimit, slackEscape(rest))
+		}
+		return slackFacts(SlackInChannel, heading, facts), nil
+
+	case "add":
+		if !s.slackUsers[form.Get("user_id")] {
+			return slackNotice("Sorry, you aren't allowed to add facts."), nil
+		}
+
+		fact, err := factInput{Content: rest}.fact()
+		if err != nil {
+			return slackNotice(fmt.Sprintf("Say what the fact is, like `%s add Octopuses have three hearts.`", command)), nil
+		}
+
+		f, err := s.facts.CreateFact(ctx, fact)
+		if err != nil {
+			return SlackMessage{}, err
+		}
+		s.publish(ctx, EventFactCreated, f.ID, &f)
+
+		return slackFacts(SlackEphemeral, fmt.Sprintf("Added fact %d:", f.ID), []Fact{f}), nil
+	}
+
+	id, err := strconv.ParseInt(verb, 10, 64)
+	if err != nil || rest != "" {
+		return usage, nil
+	}
+
+	f, err := s.facts.Fact(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return slackNotice(fmt.Sprintf("There's no fact %d.", id)), nil
+	}
+	if err != nil {
+		return SlackMessage{}, err
+	}
+	return slackFacts(SlackInChannel, "", []Fact{f}), nil
+}
+
+// slackNotice is a message only the sender of a command sees.
+func slackNotice(text string) SlackMessage {
+	return SlackMessage{
+		ResponseType: SlackEphemeral,
+		Text:         text,
+		Blocks: []SlackBlock{
+			{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: text}},
+		},
+	}
+}
+
+// slackFacts lays out facts under an optional heading.
+func slackFacts(responseType, heading string, facts []Fact) SlackMessage {
+	msg := SlackMessage{ResponseType: responseType, Text: heading}
+
+	if heading != "" {
+		msg.Blocks = append(msg.Blocks, SlackBlock{
+			Type: "section",
+			Text: &SlackText{Type: "mrkdwn", Text: heading},
+		})
+	}
+
+	for i, f := range facts {
+		if i > 0 || heading != "" {
+			msg.Blocks = append(msg.Blocks, SlackBlock{Type: "divider"})
+		}
+		msg.Blocks = append(msg.Blocks, slackFactBlocks(f)...)
+	}
+
+	// Notifications only show the text, so it should say the fact.
+	if len(facts) == 1 && msg.Text == "" {
+		msg.Text = facts[0].Content
+	}
+	return msg
+}
+
+// slackFactBlocks shows a fact's content, followed by its ID and where
+// it comes from.
+func slackFactBlocks(f Fact) []SlackBlock {
+	details := []SlackText{{Type: "mrkdwn", Text: fmt.Sprintf("Fact %d", f.ID)}}
+
+	if len(f.Citations) > 0 {
+		for _, c := range f.Citations {
+			title := c.Title
+			if title == "" {
+				title = c.URL
+			}
+			details = append(details, SlackText{
+				Type: "mrkdwn",
+				Text: fmt.Sprintf("<%s|%s>", slackEscape(c.URL), slackEscape(title)),
+			})
+		}
+	} else if f.Source != "" {
+		details = append(details, SlackText{Type: "mrkdwn", Text: "Source: " + slackEscape(f.Source)})
+	}
+
+	return []SlackBlock{
+		{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: slackEscape(f.Content)}},
+		{Type: "context", Elements: details},
+	}
+}
+
+// slackEscaper escapes the characters Slack treats as markup in text.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackEscape(s string) string {
+	return slackEscaper.Replace(s)
+}
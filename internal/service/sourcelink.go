@@ -0,0 +1,22 @@
+package service
+
+import (
+	"time"
+)
+
+const (
+	SourceStatusOK        = "ok"
+	SourceStatusBroken    = "broken"
+	SourceStatusUnchecked = "unchecked"
+)
+
+// SourceLink is the outcome of the most recent attempt to fetch a
+// citation's URL.
+type SourceLink struct {
+	URL        string
+	Status     string
+	StatusCode int
+	FinalURL   string
+	Error      string
+	CheckedAt  time.Time
+}
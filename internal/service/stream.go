@@ -0,0 +1,110 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "golang.org/x/exp/slog"
+)
+
+// WithHeartbeatInterval sets how often an idle stream of events sends a
+// comment, which keeps proxies from timing it out.
+func WithHeartbeatInterval(d time.Duration) optionFunc {
+	return func(s *Service) { s.heartbeatInterval = d }
+}
+
+// StreamHandler sends events about facts as Server-Sent Events until the
+// client goes away. A client that reconnects with the Last-Event-ID
+// header, or the last_event_id query parameter, first gets the events it
+// missed. If some of them are no longer kept, it gets a reset event
+// instead and should fetch the facts again.
+func (s *Service) StreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+
+	after := int64(-1)
+	if lastID != "" {
+		id, err := strconv.ParseInt(lastID, 10, 64)
+		if err != nil || id < 0 {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("Last-Event-ID must be the id of an event"))
+			return
+		}
+		after = id
+	}
+
+	logger := log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+		"last_event_id", lastID,
+	)
+
+	ctx := r.Context()
+
+	backlog, complete, events, cancel, err := s.events.subscribe(ctx, after)
+	if err != nil {
+		logger.With("err", err).Error("")
+		s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+		return
+	}
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", cacheControlNoStore)
+	w.WriteHeader(http.StatusOK)
+
+	if !complete {
+		if _, err := fmt.Fprint(w, "event: reset\ndata: {}\n\n"); err != nil {
+			return
+		}
+	}
+	for _, e := range backlog {
+		if err := writeEvent(w, e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(s.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+func writeEvent(w http.ResponseWriter, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
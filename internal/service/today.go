@@ -0,0 +1,242 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	log "golang.org/x/exp/slog"
+)
+
+const dayLayout = "2006-01-02"
+
+// DailyFact records which fact was chosen for a day.
+type DailyFact struct {
+	Day    string
+	FactID int64
+	Pinned bool
+}
+
+// WithDailyRepeatWindow sets how many days must pass before the fact of
+// the day may repeat.
+func WithDailyRepeatWindow(days int) optionFunc {
+	return func(s *Service) { s.dailyRepeatWindow = days }
+}
+
+// TodayHandler serves the fact of the day. Every caller asking about the
+// same date gets the same fact.
+func (s *Service) TodayHandler(w http.ResponseWriter, r *http.Request) {
+	loc := time.UTC
+	if tz := r.URL.Query().Get("tz"); tz != "" {
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("tz must be an IANA time zone name"))
+			return
+		}
+	}
+
+	// The time zone only decides which date it is. Everything else about
+	// a date, like which facts existed by its end, is worked out in UTC,
+	// so that a date has the same fact wherever it's asked about.
+	now := s.now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	day := today
+
+	if date := r.URL.Query().Get("date"); date != "" {
+		var err error
+		day, err = time.Parse(dayLayout, date)
+		if err != nil {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("date must be formatted as YYYY-MM-DD"))
+			return
+		}
+	}
+
+	// Only settle on a choice once the day has arrived, so that peeking
+	// ahead doesn't lock in a fact before newer ones get a chance. Days
+	// gone by are only settled by admins, so that anybody walking back
+	// through the calendar doesn't fill it in.
+	settle := day.Equal(today) || (day.Before(today) && s.isPrivileged(r))
+
+	f, err := s.factOfTheDay(r.Context(), day, settle)
+	if err != nil {
+		status := http.StatusNotFound
+		if !errors.Is(err, ErrNotFound) {
+			log.With("date", day.Format(dayLayout), "err", err).Error("")
+
+			status = http.StatusInternalServerError
+			err = errors.New("internal error")
+		}
+		s.RespondErrorJSON(w, status, err)
+		return
+	}
+
+	s.RespondFacts(w, r, http.StatusOK, Response{
+		Envelope:     map[string]any{"date": day.Format(dayLayout), "fact": f},
+		Facts:        []Fact{f},
+		Single:       true,
+		Version:      factVersion(f),
+		LastModified: f.UpdatedAt,
+		CacheControl: cacheControlRevalidate,
+	})
+}
+
+// PinHandler lets an admin choose (PUT) or release (DELETE) the fact of
+// the day for a date.
+func (s *Service) PinHandler(w http.ResponseWriter, r *http.Request) {
+	date := httprouter.ParamsFromContext(r.Context()).ByName("date")
+	if _, err := time.Parse(dayLayout, date); err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("date must be formatted as YYYY-MM-DD"))
+		return
+	}
+
+	logger := log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+		"date", date,
+	)
+
+	ctx := r.Context()
+
+	switch r.Method {
+	case http.MethodPut:
+		var body struct {
+			ID int64 `json:"id"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("bad request"))
+			return
+		}
+
+		f, err := s.facts.Fact(ctx, body.ID)
+		if err != nil {
+			status := http.StatusNotFound
+			if !errors.Is(err, ErrNotFound) {
+				logger.With("err", err).Error("")
+
+				status = http.StatusInternalServerError
+				err = errors.New("internal error")
+			}
+			s.RespondErrorJSON(w, status, err)
+			return
+		}
+
+		if err := s.facts.PinDailyFact(ctx, date, f.ID); err != nil {
+			logger.With("err", err).Error("")
+			s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+			return
+		}
+
+		s.RespondJSON(w, http.StatusOK, map[string]any{"date": date, "fact": f})
+
+	case http.MethodDelete:
+		if err := s.facts.DeleteDailyFact(ctx, date); err != nil {
+			logger.With("err", err).Error("")
+			s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// factOfTheDay returns the fact chosen for day, choosing (and, if settle
+// is set, remembering) one if that hasn't happened yet.
+//
+// Facts are ranked by a hash of the date and their ID, and only facts
+// that existed by the end of the day in UTC are considered, so facts
+// added later never change the answer for a date. Facts chosen for the
+// preceding days in the repeat window are skipped.
+func (s *Service) factOfTheDay(ctx context.Context, day time.Time, settle bool) (Fact, error) {
+	key := day.Format(dayLayout)
+
+	chosen, err := s.facts.DailyFact(ctx, key)
+	switch {
+	case err == nil:
+		f, err := s.facts.Fact(ctx, chosen.FactID)
+		if !errors.Is(err, ErrNotFound) {
+			return f, err
+		}
+
+		// The chosen fact has since been deleted, so start over.
+		if settle {
+			if err := s.facts.DeleteDailyFact(ctx, key); err != nil {
+				return Fact{}, err
+			}
+		}
+	case !errors.Is(err, ErrNotFound):
+		return Fact{}, err
+	}
+
+	candidates, err := s.facts.FactIDsCreatedBefore(ctx, day.AddDate(0, 0, 1))
+	if err != nil {
+		return Fact{}, err
+	}
+
+	recent, err := s.facts.DailyFactIDs(ctx, day.AddDate(0, 0, -s.dailyRepeatWindow).Format(dayLayout), key)
+	if err != nil {
+		return Fact{}, err
+	}
+
+	id, ok := pickDailyFact(key, candidates, recent)
+	if !ok {
+		return Fact{}, ErrNotFound
+	}
+
+	if !settle {
+		return s.facts.Fact(ctx, id)
+	}
+
+	if err := s.facts.SaveDailyFact(ctx, key, id); err != nil {
+		return Fact{}, err
+	}
+
+	// Somebody else may have settled on a fact first, so return
+	// whichever one won.
+	chosen, err = s.facts.DailyFact(ctx, key)
+	if err != nil {
+		return Fact{}, err
+	}
+
+	return s.facts.Fact(ctx, chosen.FactID)
+}
+
+// pickDailyFact returns the highest ranked candidate for day that isn't
+// in recent, or the highest ranked candidate if they all are. It reports
+// false if there are no candidates.
+func pickDailyFact(day string, candidates, recent []int64) (int64, bool) {
+	rank := func(id int64) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(day + ":" + strconv.FormatInt(id, 10)))
+		return h.Sum64()
+	}
+
+	seen := make(map[int64]bool, len(recent))
+	for _, id := range recent {
+		seen[id] = true
+	}
+
+	var best, fallback int64
+	var bestRank, fallbackRank uint64
+	var found, foundFallback bool
+	for _, id := range candidates {
+		r := rank(id)
+		if !foundFallback || r > fallbackRank {
+			fallback, fallbackRank, foundFallback = id, r, true
+		}
+		if !seen[id] && (!found || r > bestRank) {
+			best, bestRank, found = id, r, true
+		}
+	}
+
+	if !found {
+		return fallback, foundFallback
+	}
+	return best, true
+}
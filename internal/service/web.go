@@ -0,0 +1,322 @@
+package service
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	log "golang.org/x/exp/slog"
+)
+
+// webPageSize is how many facts the browse and search pages show at once.
+const webPageSize = 20
+
+// maxDescription is the longest a page's description gets, which is
+// about as much as link previews show.
+const maxDescription = 200
+
+//go:embed web
+var webAssets embed.FS
+
+var (
+	webPages = func() map[string]*template.Template {
+		pages := make(map[string]*template.Template)
+		for _, name := range []string{"home", "fact", "browse", "search", "message"} {
+			pages[name] = template.Must(template.ParseFS(webAssets,
+				"web/templates/layout.html",
+				"web/templates/"+name+".html",
+			))
+		}
+		return pages
+	}()
+
+	webStatic = func() http.Handler {
+		sub, err := fs.Sub(webAssets, "web/static")
+		if err != nil {
+			panic(err)
+		}
+		return http.StripPrefix("/static", http.FileServer(http.FS(sub)))
+	}()
+)
+
+// pageData is what the templates in web/templates show.
+type pageData struct {
+	// Title, Description and Canonical describe the page, including to
+	// sites showing a preview of a link to it, and Type is its OpenGraph
+	// type, "website" unless set.
+	Title       string
+	Description string
+	Canonical   string
+	Type        string
+
+	// Query is what was searched for.
+	Query string
+
+	// Fact is the fact shown on its own, if HasFact is set.
+	Fact    Fact
+	HasFact bool
+
+	// Facts are the facts listed on the page.
+	Facts []Fact
+
+	// First and Next link to the first and following pages of a list.
+	First string
+	Next  string
+
+	// Another links to a different random fact.
+	Another string
+
+	// Message is shown when there's nothing else to show.
+	Message string
+}
+
+// HomeHandler shows a random fact, with a link to another one.
+func (s *Service) HomeHandler(w http.ResponseWriter, r *http.Request) {
+	var opts RandomOptions
+
+	// Don't show the fact the visitor asked for another one instead of.
+	if id, err := strconv.ParseInt(r.URL.Query().Get("not"), 10, 64); err == nil {
+		opts.Exclude = []int64{id}
+	}
+
+	facts, err := s.randomFacts(r.Context(), 1, opts)
+	if errors.Is(err, ErrNotFound) && len(opts.Exclude) > 0 {
+		// It was the only fact.
+		facts, err = s.randomFacts(r.Context(), 1, RandomOptions{})
+	}
+
+	data := pageData{
+		Description: "A fun fact, and another, and another.",
+		Canonical:   s.base(r) + "/",
+	}
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		data.Message = "There are no facts yet."
+	case err != nil:
+		s.respondPageError(w, r, err)
+		return
+	default:
+		if err := s.facts.MarkServed(r.Context(), facts[0].ID); err != nil {
+			s.respondPageError(w, r, err)
+			return
+		}
+
+		data.Fact, data.HasFact = facts[0], true
+		data.Another = fmt.Sprintf("/?not=%d", facts[0].ID)
+	}
+
+	w.Header().Set("Cache-Control", cacheControlNoStore)
+	s.respondPage(w, r, http.StatusOK, "home", data)
+}
+
+// FactPageHandler shows a single fact at a permanent address, with the
+// tags sites need to show a preview of links to it.
+func (s *Service) FactPageHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	if err != nil {
+		s.respondPageNotFound(w, r)
+		return
+	}
+
+	f, err := s.facts.Fact(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		s.respondPageNotFound(w, r)
+		return
+	}
+	if err != nil {
+		s.respondPageError(w, r, err)
+		return
+	}
+
+	tag := etag(factVersion(f), "page")
+	h := w.Header()
+	h.Set("Cache-Control", cacheControlRevalidate)
+	h.Set("ETag", tag)
+	h.Set("Last-Modified", f.UpdatedAt.UTC().Format(http.TimeFormat))
+	if notModified(r, tag, f.UpdatedAt) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	s.respondPage(w, r, http.StatusOK, "fact", pageData{
+		Title:       fmt.Sprintf("Fact #%d", f.ID),
+		Description: describe(f.Content),
+		Canonical:   fmt.Sprintf("%s/fact/%d", s.base(r), f.ID),
+		Type:        "article",
+		Fact:        f,
+		HasFact:     true,
+	})
+}
+
+// BrowsePageHandler lists every fact, a page at a time.
+func (s *Service) BrowsePageHandler(w http.ResponseWriter, r *http.Request) {
+	s.respondFactList(w, r, "browse", pageData{
+		Title:     "All facts",
+		Canonical: s.base(r) + "/facts",
+		Message:   "There are no facts yet.",
+	})
+}
+
+// SearchPageHandler lists the facts that mention the q query parameter,
+// a page at a time.
+func (s *Service) SearchPageHandler(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	data := pageData{Title: "Search", Query: q}
+	if q == "" {
+		s.respondPage(w, r, http.StatusOK, "search", data)
+		return
+	}
+
+	data.Title = fmt.Sprintf("Facts that mention “%s”", q)
+	s.respondFactList(w, r, "search", data)
+}
+
+// StaticHandler serves the files the pages need, such as stylesheets.
+func (s *Service) StaticHandler(w http.ResponseWriter, r *http.Request) {
+	webStatic.ServeHTTP(w, r)
+}
+
+// errBadCursor means the after query parameter isn't a fact ID.
+var errBadCursor = errors.New("after must be a fact ID")
+
+// respondFactList shows a page of the facts matching data.Query.
+func (s *Service) respondFactList(w http.ResponseWriter, r *http.Request, name string, data pageData) {
+	var err error
+	data.Facts, data.First, data.Next, err = s.factPage(r, data.Query, webPageSize)
+	if errors.Is(err, errBadCursor) {
+		s.respondPageNotFound(w, r)
+		return
+	}
+	if err != nil {
+		s.respondPageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", cacheControlRevalidate)
+	s.respondPage(w, r, http.StatusOK, name, data)
+}
+
+// factPage returns up to size facts that mention search, after the one
+// named by the after query parameter, with links to the first and next
+// pages if there are any.
+func (s *Service) factPage(r *http.Request, search string, size int) (facts []Fact, first, next string, err error) {
+	query := FactsQuery{
+		Search: search,
+
+		// One more than fits on the page says whether there's a next one.
+		Limit: size + 1,
+	}
+
+	if after := r.URL.Query().Get("after"); after != "" {
+		id, err := strconv.ParseInt(after, 10, 64)
+		if err != nil || id < 0 {
+			return nil, "", "", errBadCursor
+		}
+		query.After = id
+	}
+
+	facts, err = s.facts.Facts(r.Context(), query)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	link := func(after int64) string {
+		v := url.Values{}
+		if search != "" {
+			v.Set("q", search)
+		}
+		if after > 0 {
+			v.Set("after", strconv.FormatInt(after, 10))
+		}
+		if len(v) == 0 {
+			return r.URL.Path
+		}
+		return r.URL.Path + "?" + v.Encode()
+	}
+
+	if len(facts) > size {
+		facts = facts[:size]
+		next = link(facts[len(facts)-1].ID)
+	}
+	if query.After > 0 {
+		first = link(0)
+	}
+	return facts, first, next, nil
+}
+
+// respondPage shows the page called name.
+func (s *Service) respondPage(w http.ResponseWriter, r *http.Request, code int, name string, data pageData) {
+	s.render(w, r, code, webPages[name], name, data)
+}
+
+// render executes the "layout" template of t, which is the page called
+// name.
+func (s *Service) render(w http.ResponseWriter, r *http.Request, code int, t *template.Template, name string, data any) {
+	// Render the whole page first, so a broken template can still get
+	// an error page.
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "layout", data); err != nil {
+		log.With(
+			"request_uri", r.RequestURI,
+			"http_method", r.Method,
+			"page", name,
+			"err", err,
+		).Error("")
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	_, _ = buf.WriteTo(w)
+}
+
+func (s *Service) respondPageNotFound(w http.ResponseWriter, r *http.Request) {
+	s.respondPage(w, r, http.StatusNotFound, "message", pageData{
+		Title:   "Not found",
+		Message: "There's no such fact. It may have been deleted.",
+	})
+}
+
+func (s *Service) respondPageError(w http.ResponseWriter, r *http.Request, err error) {
+	log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+		"err", err,
+	).Error("")
+
+	s.respondPage(w, r, http.StatusInternalServerError, "message", pageData{
+		Title:   "Something went wrong",
+		Message: "Sorry, something went wrong. Please try again later.",
+	})
+}
+
+// describe shortens content to fit a page's description, without
+// splitting a word.
+func describe(content string) string {
+	content = strings.Join(strings.Fields(content), " ")
+	if len(content) <= maxDescription {
+		return content
+	}
+
+	cut := strings.LastIndexByte(content[:maxDescription], ' ')
+	if cut <= 0 {
+		cut = maxDescription
+		for cut > 0 && content[cut]&0xC0 == 0x80 {
+			cut--
+		}
+	}
+	return content[:cut] + "…"
+}
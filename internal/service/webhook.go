@@ -0,0 +1,433 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	log "golang.org/x/exp/slog"
+)
+
+// Statuses of a webhook delivery.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// defaultDeliveries is how many of a webhook's deliveries are listed
+// when no limit is asked for.
+const defaultDeliveries = 100
+
+// Webhook is a URL that's sent the events it subscribes to.
+type Webhook struct {
+	ID     int64    `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+
+	// Secret signs what's sent to the webhook. It's only shown when it's
+	// set, so it should be kept by whoever sets it.
+	Secret string `json:"secret,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookUpdate holds the fields of a webhook to change. Nil fields are
+// left alone.
+type WebhookUpdate struct {
+	URL    *string
+	Events *[]string
+	Secret *string
+}
+
+// WebhookDelivery is an event queued to be sent to a webhook.
+type WebhookDelivery struct {
+	ID        int64  `json:"id"`
+	WebhookID int64  `json:"webhook_id"`
+	EventType string `json:"event_type"`
+
+	// Payload is the Event sent as the body of the request.
+	Payload json.RawMessage `json:"payload"`
+	Status  string          `json:"status"`
+
+	// NextAttemptAt is when a pending delivery is tried next.
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+
+	CreatedAt time.Time        `json:"created_at"`
+	Attempts  []WebhookAttempt `json:"attempts"`
+}
+
+// WebhookAttempt is one try at sending a delivery. A StatusCode of zero
+// means no response was received, and Error says why.
+type WebhookAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, w Webhook) (Webhook, error)
+	Webhooks(ctx context.Context) ([]Webhook, error)
+	Webhook(ctx context.Context, id int64) (Webhook, error)
+	UpdateWebhook(ctx context.Context, id int64, u WebhookUpdate) (Webhook, error)
+
+	// DeleteWebhook deletes webhook id along with its deliveries.
+	DeleteWebhook(ctx context.Context, id int64) error
+
+	// EnqueueWebhookDeliveries queues payload to be sent at the given
+	// time to every webhook subscribed to eventType.
+	EnqueueWebhookDeliveries(ctx context.Context, eventType string, payload []byte, at time.Time) error
+
+	// WebhookDeliveries returns the most recent deliveries to webhook
+	// id, newest first.
+	WebhookDeliveries(ctx context.Context, webhookID int64, limit int) ([]WebhookDelivery, error)
+	WebhookDelivery(ctx context.Context, id int64) (WebhookDelivery, error)
+
+	// RedeliverWebhookDelivery queues delivery id to be sent again at
+	// the given time.
+	RedeliverWebhookDelivery(ctx context.Context, id int64, at time.Time) (WebhookDelivery, error)
+}
+
+// WithWebhookStore keeps webhooks and their deliveries in store, and
+// queues a delivery for every event a webhook subscribes to. Something
+// else, like the webhook package's Dispatcher, has to send them. Without
+// a store the webhook endpoints respond with HTTP 501.
+func WithWebhookStore(store WebhookStore) optionFunc {
+	return func(s *Service) { s.webhooks = store }
+}
+
+type webhookInput struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+}
+
+// WebhooksHandler lists webhooks and creates new ones.
+func (s *Service) WebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		s.unimplemented(w, r)
+		return
+	}
+
+	logger := log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+	)
+
+	switch r.Method {
+	case http.MethodGet:
+		hooks, err := s.webhooks.Webhooks(r.Context())
+		if err != nil {
+			logger.With("err", err).Error("")
+			s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+			return
+		}
+
+		for i := range hooks {
+			hooks[i].Secret = ""
+		}
+		s.RespondJSON(w, http.StatusOK, map[string]any{"webhooks": hooks})
+
+	case http.MethodPost:
+		var body webhookInput
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("bad request"))
+			return
+		}
+
+		if err := validateWebhookURL(body.URL); err != nil {
+			s.RespondErrorJSON(w, http.StatusBadRequest, err)
+			return
+		}
+
+		events, err := webhookEvents(body.Events)
+		if err != nil {
+			s.RespondErrorJSON(w, http.StatusBadRequest, err)
+			return
+		}
+
+		secret := body.Secret
+		if secret == "" {
+			secret, err = newWebhookSecret()
+			if err != nil {
+				logger.With("err", err).Error("")
+				s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+				return
+			}
+		}
+
+		hook, err := s.webhooks.CreateWebhook(r.Context(), Webhook{
+			URL:    body.URL,
+			Events: events,
+			Secret: secret,
+		})
+		if err != nil {
+			logger.With("err", err).Error("")
+			s.RespondErrorJSON(w, http.StatusInternalServerError, errors.New("internal error"))
+			return
+		}
+
+		s.RespondJSON(w, http.StatusCreated, map[string]any{"webhook": hook})
+	}
+}
+
+// WebhookHandler shows, changes and deletes a webhook.
+func (s *Service) WebhookHandler(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		s.unimplemented(w, r)
+		return
+	}
+
+	idParam := httprouter.ParamsFromContext(r.Context()).ByName("id")
+
+	logger := log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+		"webhook_param_id", idParam,
+	)
+
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("id must be an integer"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		hook, err := s.webhooks.Webhook(r.Context(), id)
+		if err != nil {
+			s.respondWebhookError(w, logger, err)
+			return
+		}
+
+		hook.Secret = ""
+		s.RespondJSON(w, http.StatusOK, map[string]any{"webhook": hook})
+
+	case http.MethodPatch:
+		var body struct {
+			URL    *string   `json:"url"`
+			Events *[]string `json:"events"`
+			Secret *string   `json:"secret"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("bad request"))
+			return
+		}
+
+		if body.URL != nil {
+			if err := validateWebhookURL(*body.URL); err != nil {
+				s.RespondErrorJSON(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		if body.Events != nil {
+			events, err := webhookEvents(*body.Events)
+			if err != nil {
+				s.RespondErrorJSON(w, http.StatusBadRequest, err)
+				return
+			}
+			body.Events = &events
+		}
+
+		if body.Secret != nil && *body.Secret == "" {
+			s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("secret field must not be blank"))
+			return
+		}
+
+		hook, err := s.webhooks.UpdateWebhook(r.Context(), id, WebhookUpdate{
+			URL:    body.URL,
+			Events: body.Events,
+			Secret: body.Secret,
+		})
+		if err != nil {
+			s.respondWebhookError(w, logger, err)
+			return
+		}
+
+		// Only show the secret to whoever just set it.
+		if body.Secret == nil {
+			hook.Secret = ""
+		}
+		s.RespondJSON(w, http.StatusOK, map[string]any{"webhook": hook})
+
+	case http.MethodDelete:
+		if err := s.webhooks.DeleteWebhook(r.Context(), id); err != nil {
+			s.respondWebhookError(w, logger, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// WebhookDeliveriesHandler lists a webhook's most recent deliveries and
+// the attempts made at each.
+func (s *Service) WebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		s.unimplemented(w, r)
+		return
+	}
+
+	idParam := httprouter.ParamsFromContext(r.Context()).ByName("id")
+
+	logger := log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+		"webhook_param_id", idParam,
+	)
+
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("id must be an integer"))
+		return
+	}
+
+	limit := defaultDeliveries
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxPageSize {
+			s.RespondErrorJSON(w, http.StatusBadRequest, fmt.Errorf("limit must be an integer between 1 and %d", maxPageSize))
+			return
+		}
+		limit = n
+	}
+
+	if _, err := s.webhooks.Webhook(r.Context(), id); err != nil {
+		s.respondWebhookError(w, logger, err)
+		return
+	}
+
+	deliveries, err := s.webhooks.WebhookDeliveries(r.Context(), id, limit)
+	if err != nil {
+		s.respondWebhookError(w, logger, err)
+		return
+	}
+
+	s.RespondJSON(w, http.StatusOK, map[string]any{"deliveries": deliveries})
+}
+
+// RedeliverHandler queues a delivery to be sent again right away, whether
+// or not it was delivered before. Attempts count toward the same limit as
+// retries, so a delivery that had failed is only tried once more.
+func (s *Service) RedeliverHandler(w http.ResponseWriter, r *http.Request) {
+	if s.webhooks == nil {
+		s.unimplemented(w, r)
+		return
+	}
+
+	params := httprouter.ParamsFromContext(r.Context())
+	idParam := params.ByName("id")
+	deliveryParam := params.ByName("delivery")
+
+	logger := log.With(
+		"request_uri", r.RequestURI,
+		"http_method", r.Method,
+		"webhook_param_id", idParam,
+		"delivery_param_id", deliveryParam,
+	)
+
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("id must be an integer"))
+		return
+	}
+
+	deliveryID, err := strconv.ParseInt(deliveryParam, 10, 64)
+	if err != nil {
+		s.RespondErrorJSON(w, http.StatusBadRequest, errors.New("delivery must be an integer"))
+		return
+	}
+
+	d, err := s.webhooks.WebhookDelivery(r.Context(), deliveryID)
+	if err == nil && d.WebhookID != id {
+		err = ErrNotFound
+	}
+	if err != nil {
+		s.respondWebhookError(w, logger, err)
+		return
+	}
+
+	d, err = s.webhooks.RedeliverWebhookDelivery(r.Context(), deliveryID, s.now())
+	if err != nil {
+		s.respondWebhookError(w, logger, err)
+		return
+	}
+
+	s.RespondJSON(w, http.StatusAccepted, map[string]any{"delivery": d})
+}
+
+func (s *Service) respondWebhookError(w http.ResponseWriter, logger *log.Logger, err error) {
+	status := http.StatusNotFound
+	if !errors.Is(err, ErrNotFound) {
+		logger.With("err", err).Error("")
+
+		status = http.StatusInternalServerError
+		err = errors.New("internal error")
+	}
+	s.RespondErrorJSON(w, status, err)
+}
+
+// enqueueWebhooks queues e for the webhooks subscribed to it. The change
+// has been made even if the client that made it has gone away, so the
+// request's context isn't used.
+func (s *Service) enqueueWebhooks(e Event) {
+	payload, err := json.Marshal(e)
+	if err == nil {
+		err = s.webhooks.EnqueueWebhookDeliveries(context.Background(), e.Type, payload, e.Time)
+	}
+	if err != nil {
+		log.With("event_type", e.Type, "fact_id", e.FactID, "err", err).Error("")
+	}
+}
+
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	return nil
+}
+
+// webhookEvents checks that events only names kinds of events, and drops
+// any repeats.
+func webhookEvents(events []string) ([]string, error) {
+	errEvents := fmt.Errorf("events must list one or more of '%s', '%s' and '%s'", EventFactCreated, EventFactUpdated, EventFactDeleted)
+	if len(events) == 0 {
+		return nil, errEvents
+	}
+
+	seen := make(map[string]bool)
+	unique := make([]string, 0, len(events))
+	for _, e := range events {
+		switch e {
+		case EventFactCreated, EventFactUpdated, EventFactDeleted:
+		default:
+			return nil, errEvents
+		}
+		if !seen[e] {
+			seen[e] = true
+			unique = append(unique, e)
+		}
+	}
+	return unique, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
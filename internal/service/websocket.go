@@ -0,0 +1,385 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "golang.org/x/exp/slog"
+)
+
+// Topics a WebSocket client can subscribe to.
+const (
+	// TopicChanges sends every event about facts.
+	TopicChanges = "changes"
+
+	// TopicRandom sends a random fact every so often.
+	TopicRandom = "random"
+)
+
+const (
+	// wsSendBuffer is how many messages may wait to be written to a
+	// WebSocket client.
+	wsSendBuffer = 64
+
+	wsMaxMessageSize = 4096
+	wsWriteTimeout   = 10 * time.Second
+
+	minRandomInterval = 1
+	maxRandomInterval = 24 * 60 * 60
+)
+
+// WithMaxWebSockets caps how many WebSocket connections are served at
+// once. More are turned away with HTTP 503.
+func WithMaxWebSockets(n int) optionFunc {
+	return func(s *Service) { s.maxWebSockets = n }
+}
+
+// WSMessage is what WebSocket clients and the server send each other,
+// as JSON text messages. Clients send subscribe and unsubscribe
+// messages; the server answers with subscribed, unsubscribed or error,
+// and then sends event, reset and fact messages for the topics
+// subscribed to.
+type WSMessage struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic,omitempty"`
+
+	// Interval is the number of seconds between random facts.
+	Interval float64 `json:"interval,omitempty"`
+
+	// Strategy decides how random facts are chosen, see RandomOptions.
+	Strategy string `json:"strategy,omitempty"`
+
+	// After asks for the events after the one with this ID first, like
+	// the Last-Event-ID header of a stream.
+	After *int64 `json:"after,omitempty"`
+
+	Event *Event `json:"event,omitempty"`
+	Fact  *Fact  `json:"fact,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// WebSocketHandler pushes events and random facts to WebSocket clients
+// according to the topics they subscribe to.
+func (s *Service) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	if n := s.webSockets.Add(1); s.maxWebSockets > 0 && n > int64(s.maxWebSockets) {
+		s.webSockets.Add(-1)
+		w.Header().Set("Retry-After", "30")
+		s.RespondErrorJSON(w, http.StatusServiceUnavailable, errors.New("too many connections"))
+		return
+	}
+	defer s.webSockets.Add(-1)
+
+	upgrader := websocket.Upgrader{
+		Error: func(w http.ResponseWriter, _ *http.Request, status int, reason error) {
+			s.RespondErrorJSON(w, status, reason)
+		},
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has responded already.
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &wsConn{
+		s:      s,
+		conn:   conn,
+		send:   make(chan WSMessage, wsSendBuffer),
+		ctx:    ctx,
+		cancel: cancel,
+		topics: make(map[string]context.CancelFunc),
+		logger: log.With(
+			"request_uri", r.RequestURI,
+			"remote_addr", r.RemoteAddr,
+		),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.writeLoop()
+	}()
+
+	c.readLoop()
+	c.close(websocket.CloseNormalClosure, "")
+	<-done
+	c.wg.Wait()
+}
+
+// wsConn is a WebSocket client. Only writeLoop writes to conn, and only
+// readLoop touches topics.
+type wsConn struct {
+	s      *Service
+	conn   *websocket.Conn
+	send   chan WSMessage
+	logger *log.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	closeOnce sync.Once
+	closeCode int
+	closeText string
+
+	topics map[string]context.CancelFunc
+
+	// wg tracks the goroutines feeding topics.
+	wg sync.WaitGroup
+}
+
+// close ends the connection with the given close code, unless it's
+// ending already.
+func (c *wsConn) close(code int, text string) {
+	c.closeOnce.Do(func() {
+		c.closeCode = code
+		c.closeText = text
+		c.cancel()
+	})
+}
+
+// push queues msg to be sent, waiting for room if the client is behind.
+func (c *wsConn) push(ctx context.Context, msg WSMessage) bool {
+	select {
+	case c.send <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (c *wsConn) readLoop() {
+	pongWait := 2 * c.s.heartbeatInterval
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	defer func() {
+		for _, cancel := range c.topics {
+			cancel()
+		}
+	}()
+
+	for {
+		var msg WSMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if isJSONError(err) {
+				if !c.push(c.ctx, WSMessage{Type: "error", Error: "messages must be JSON objects"}) {
+					return
+				}
+				continue
+			}
+			return
+		}
+
+		if err := c.handle(msg); err != nil {
+			if !c.push(c.ctx, WSMessage{Type: "error", Topic: msg.Topic, Error: err.Error()}) {
+				return
+			}
+		}
+	}
+}
+
+// isJSONError reports whether a message was read but isn't the JSON
+// expected, as opposed to the connection failing.
+func isJSONError(err error) bool {
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	return errors.As(err, &syntax) || errors.As(err, &typ)
+}
+
+func (c *wsConn) handle(msg WSMessage) error {
+	switch msg.Type {
+	case "subscribe":
+		var feed func(ctx context.Context)
+
+		switch msg.Topic {
+		case TopicChanges:
+			after := int64(-1)
+			if msg.After != nil {
+				if *msg.After < 0 {
+					return errors.New("after must be the id of an event")
+				}
+				after = *msg.After
+			}
+
+			// Subscribe right away so that no event published after
+			// the client hears it's subscribed is missed.
+			backlog, complete, events, unsubscribe, err := c.s.events.subscribe(c.ctx, after)
+			if err != nil {
+				c.logger.With("err", err).Error("")
+				return errors.New("internal error")
+			}
+			feed = func(ctx context.Context) {
+				defer unsubscribe()
+				c.feedChanges(ctx, backlog, complete, events)
+			}
+
+		case TopicRandom:
+			if msg.Interval < minRandomInterval || msg.Interval > maxRandomInterval {
+				return fmt.Errorf("interval must be a number of seconds between %d and %d", minRandomInterval, maxRandomInterval)
+			}
+			switch msg.Strategy {
+			case "", StrategyUniform, StrategyWeighted, StrategyFavorRecent, StrategyFavorUnseen:
+			default:
+				return errors.New("strategy must be one of 'uniform', 'weighted', 'favor-recent' or 'favor-unseen'")
+			}
+			interval := time.Duration(msg.Interval * float64(time.Second))
+			feed = func(ctx context.Context) { c.feedRandom(ctx, interval, msg.Strategy) }
+
+		default:
+			return fmt.Errorf("topic must be '%s' or '%s'", TopicChanges, TopicRandom)
+		}
+
+		// Subscribing again replaces the subscription.
+		if cancel, ok := c.topics[msg.Topic]; ok {
+			cancel()
+		}
+
+		ctx, cancel := context.WithCancel(c.ctx)
+		c.topics[msg.Topic] = cancel
+
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			if c.push(ctx, WSMessage{Type: "subscribed", Topic: msg.Topic}) {
+				feed(ctx)
+			}
+		}()
+		return nil
+
+	case "unsubscribe":
+		cancel, ok := c.topics[msg.Topic]
+		if !ok {
+			return errors.New("not subscribed to this topic")
+		}
+		cancel()
+		delete(c.topics, msg.Topic)
+
+		c.push(c.ctx, WSMessage{Type: "unsubscribed", Topic: msg.Topic})
+		return nil
+
+	default:
+		return errors.New("type must be 'subscribe' or 'unsubscribe'")
+	}
+}
+
+// feedChanges sends events until ctx is done. A client too slow to keep
+// up is disconnected, and can subscribe again with the ID of the last
+// event it got.
+func (c *wsConn) feedChanges(ctx context.Context, backlog []Event, complete bool, events <-chan Event) {
+	if !complete && !c.push(ctx, WSMessage{Type: "reset", Topic: TopicChanges}) {
+		return
+	}
+	for i := range backlog {
+		if !c.push(ctx, WSMessage{Type: "event", Topic: TopicChanges, Event: &backlog[i]}) {
+			return
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-events:
+			if !ok {
+				select {
+				case <-c.s.done:
+					c.close(websocket.CloseGoingAway, "server is shutting down")
+				default:
+					c.close(websocket.CloseTryAgainLater, "falling behind")
+				}
+				return
+			}
+			if !c.push(ctx, WSMessage{Type: "event", Topic: TopicChanges, Event: &e}) {
+				return
+			}
+		}
+	}
+}
+
+// feedRandom sends a random fact right away and then every interval
+// until ctx is done. Facts don't repeat until every fact has been sent.
+// Facts the client isn't ready for are skipped.
+func (c *wsConn) feedRandom(ctx context.Context, interval time.Duration, strategy string) {
+	token, err := newSessionToken()
+	if err != nil {
+		c.logger.With("err", err).Error("")
+		c.push(ctx, WSMessage{Type: "error", Topic: TopicRandom, Error: "internal error"})
+		return
+	}
+	opts := RandomOptions{Session: token, Strategy: strategy}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		facts, err := c.s.randomFacts(ctx, 1, opts)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			// There may be facts by the next tick.
+		case err != nil:
+			if ctx.Err() != nil {
+				return
+			}
+			c.logger.With("err", err).Error("")
+		default:
+			select {
+			case c.send <- WSMessage{Type: "fact", Topic: TopicRandom, Fact: &facts[0]}:
+				if err := c.s.facts.MarkServed(ctx, facts[0].ID); err != nil && ctx.Err() == nil {
+					c.logger.With("err", err).Error("")
+				}
+			default:
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *wsConn) writeLoop() {
+	defer c.conn.Close()
+
+	ping := time.NewTicker(c.s.heartbeatInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case msg := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+
+		case <-c.s.done:
+			c.close(websocket.CloseGoingAway, "server is shutting down")
+
+		case <-c.ctx.Done():
+			if c.closeCode != websocket.CloseAbnormalClosure {
+				msg := websocket.FormatCloseMessage(c.closeCode, c.closeText)
+				_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+			}
+			return
+		}
+	}
+}
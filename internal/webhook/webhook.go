@@ -0,0 +1,257 @@
+// Package webhook sends the events queued for webhooks, retrying failed
+// deliveries with exponential backoff.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	log "golang.org/x/exp/slog"
+
+	"github.com/connorkuehl/factoid/internal/service"
+)
+
+// Headers sent with every delivery.
+const (
+	HeaderEvent    = "X-Factoid-Event"
+	HeaderDelivery = "X-Factoid-Delivery"
+
+	// HeaderSignature holds "sha256=" followed by the hex HMAC-SHA256 of
+	// the body, keyed with the webhook's secret. See Sign.
+	HeaderSignature = "X-Factoid-Signature"
+)
+
+// batchSize is the most deliveries sent each time the dispatcher wakes up.
+const batchSize = 100
+
+type Repo interface {
+	DueWebhookDeliveries(ctx context.Context, now time.Time, limit int) ([]service.WebhookDelivery, error)
+	Webhook(ctx context.Context, id int64) (service.Webhook, error)
+	RecordWebhookAttempt(ctx context.Context, id int64, a service.WebhookAttempt, status string, next time.Time) error
+}
+
+type Option interface {
+	Apply(d *Dispatcher)
+}
+
+type optionFunc func(d *Dispatcher)
+
+func (opt optionFunc) Apply(d *Dispatcher) {
+	opt(d)
+}
+
+// WithHTTPClient sets the client used to send deliveries.
+func WithHTTPClient(client *http.Client) optionFunc {
+	return func(d *Dispatcher) { d.client = client }
+}
+
+// WithInterval sets how often the dispatcher looks for deliveries that
+// are due.
+func WithInterval(interval time.Duration) optionFunc {
+	return func(d *Dispatcher) { d.interval = interval }
+}
+
+// WithBackoff sets how long to wait before retrying a failed delivery.
+// The wait starts at base and doubles with every failure, up to max.
+func WithBackoff(base, max time.Duration) optionFunc {
+	return func(d *Dispatcher) {
+		d.backoffBase = base
+		d.backoffMax = max
+	}
+}
+
+// WithMaxAttempts sets how many times a delivery is tried before it's
+// given up on.
+func WithMaxAttempts(n int) optionFunc {
+	return func(d *Dispatcher) { d.maxAttempts = n }
+}
+
+// WithConcurrency caps the number of deliveries in flight.
+func WithConcurrency(n int) optionFunc {
+	return func(d *Dispatcher) { d.concurrency = n }
+}
+
+type Dispatcher struct {
+	repo        Repo
+	client      *http.Client
+	interval    time.Duration
+	backoffBase time.Duration
+	backoffMax  time.Duration
+	maxAttempts int
+	concurrency int
+	now         func() time.Time
+}
+
+func New(repo Repo, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		repo:        repo,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		interval:    5 * time.Second,
+		backoffBase: 10 * time.Second,
+		backoffMax:  time.Hour,
+		maxAttempts: 10,
+		concurrency: 4,
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt.Apply(d)
+	}
+	if d.maxAttempts < 1 {
+		d.maxAttempts = 1
+	}
+	if d.concurrency < 1 {
+		d.concurrency = 1
+	}
+	return d
+}
+
+// Run sends due deliveries every interval until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.DeliverOnce(ctx); err != nil && ctx.Err() == nil {
+			log.With("component", "webhook", "err", err).Error("")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverOnce sends the deliveries that are due and records how each
+// attempt went. Deliveries that fail are scheduled to be tried again,
+// until they've been tried too many times.
+func (d *Dispatcher) DeliverOnce(ctx context.Context) error {
+	due, err := d.repo.DueWebhookDeliveries(ctx, d.now(), batchSize)
+	if err != nil {
+		return err
+	}
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, d.concurrency)
+	)
+
+	for _, delivery := range due {
+		wg.Add(1)
+		go func(delivery service.WebhookDelivery) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			logger := log.With(
+				"component", "webhook",
+				"webhook_id", delivery.WebhookID,
+				"delivery_id", delivery.ID,
+			)
+
+			hook, err := d.repo.Webhook(ctx, delivery.WebhookID)
+			if err != nil {
+				// A deleted webhook takes its deliveries with it.
+				if !errors.Is(err, service.ErrNotFound) && ctx.Err() == nil {
+					logger.With("err", err).Error("")
+				}
+				return
+			}
+
+			attempt := d.Send(ctx, hook, delivery)
+			if ctx.Err() != nil {
+				return
+			}
+
+			status, next := d.schedule(len(delivery.Attempts)+1, attempt)
+			if err := d.repo.RecordWebhookAttempt(ctx, delivery.ID, attempt, status, next); err != nil && !errors.Is(err, service.ErrNotFound) {
+				logger.With("err", err).Error("")
+			}
+		}(delivery)
+	}
+
+	wg.Wait()
+	return ctx.Err()
+}
+
+// schedule decides what becomes of a delivery after its nth attempt.
+func (d *Dispatcher) schedule(n int, a service.WebhookAttempt) (status string, next time.Time) {
+	switch {
+	case succeeded(a):
+		return service.DeliveryDelivered, time.Time{}
+	case n >= d.maxAttempts:
+		return service.DeliveryFailed, time.Time{}
+	}
+
+	backoff := d.backoffBase
+	for i := 1; i < n && backoff < d.backoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > d.backoffMax {
+		backoff = d.backoffMax
+	}
+
+	return service.DeliveryPending, a.At.Add(backoff)
+}
+
+func succeeded(a service.WebhookAttempt) bool {
+	return a.StatusCode >= 200 && a.StatusCode < 300
+}
+
+// Send posts delivery's payload to hook once.
+func (d *Dispatcher) Send(ctx context.Context, hook service.Webhook, delivery service.WebhookDelivery) service.WebhookAttempt {
+	start := d.now()
+	attempt := service.WebhookAttempt{At: start}
+
+	rsp, err := d.post(ctx, hook, delivery)
+	attempt.DurationMS = d.now().Sub(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+
+	attempt.StatusCode = rsp.StatusCode
+	return attempt
+}
+
+func (d *Dispatcher) post(ctx context.Context, hook service.Webhook, delivery service.WebhookDelivery) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "factoid-webhook")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, delivery.Payload))
+
+	rsp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+
+	// Drain a little of the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(rsp.Body, 64<<10))
+
+	return rsp, nil
+}
+
+// Sign returns the value of the signature header for body. Receivers
+// should compute it themselves and compare it with hmac.Equal.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
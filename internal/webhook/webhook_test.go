@@ -0,0 +1,285 @@
+package webhook_test
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	sqliterepo "github.com/connorkuehl/factoid/internal/repo/sqlite"
+	"github.com/connorkuehl/factoid/internal/service"
+	"github.com/connorkuehl/factoid/internal/webhook"
+)
+
+func newTestDB(t *testing.T) (*sqliterepo.Repo, func()) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+
+	_, err = db.Exec(sqliterepo.Schema())
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+
+	return sqliterepo.NewRepo(db), func() { db.Close() }
+}
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver records what it's sent and responds with the next of
+// statuses, or 200 once they run out.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	got      []received
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.got = append(rc.got, received{header: r.Header, body: body})
+
+	status := http.StatusOK
+	if len(rc.statuses) > 0 {
+		status, rc.statuses = rc.statuses[0], rc.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) received() []received {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	return append([]received(nil), rc.got...)
+}
+
+func TestDeliverOnce(t *testing.T) {
+	rc := &receiver{}
+	ts := httptest.NewServer(rc)
+	defer ts.Close()
+
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	ctx := context.TODO()
+
+	hook, err := r.CreateWebhook(ctx, service.Webhook{
+		URL:    ts.URL,
+		Events: []string{service.EventFactCreated},
+		Secret: "shh",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := []byte(`{"id":1,"type":"fact.created"}`)
+	if err := r.EnqueueWebhookDeliveries(ctx, service.EventFactCreated, payload, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// Nobody subscribes to this one.
+	if err := r.EnqueueWebhookDeliveries(ctx, service.EventFactDeleted, payload, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	d := webhook.New(r, webhook.WithHTTPClient(ts.Client()))
+	if err := d.DeliverOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	got := rc.received()
+	if len(got) != 1 {
+		t.Fatalf("want 1 request, got %d", len(got))
+	}
+
+	if string(got[0].body) != string(payload) {
+		t.Errorf("want body %s, got %s", payload, got[0].body)
+	}
+	if want := webhook.Sign("shh", payload); got[0].header.Get(webhook.HeaderSignature) != want {
+		t.Errorf("want signature %q, got %q", want, got[0].header.Get(webhook.HeaderSignature))
+	}
+	if got := got[0].header.Get(webhook.HeaderEvent); got != service.EventFactCreated {
+		t.Errorf("want event %q, got %q", service.EventFactCreated, got)
+	}
+
+	deliveries, err := r.WebhookDeliveries(ctx, hook.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("want 1 delivery, got %d", len(deliveries))
+	}
+	if want := strconv.FormatInt(deliveries[0].ID, 10); got[0].header.Get(webhook.HeaderDelivery) != want {
+		t.Errorf("want delivery %q, got %q", want, got[0].header.Get(webhook.HeaderDelivery))
+	}
+	if deliveries[0].Status != service.DeliveryDelivered {
+		t.Errorf("want status %q, got %q", service.DeliveryDelivered, deliveries[0].Status)
+	}
+	if len(deliveries[0].Attempts) != 1 || deliveries[0].Attempts[0].StatusCode != http.StatusOK {
+		t.Errorf("want one successful attempt, got %+v", deliveries[0].Attempts)
+	}
+
+	// Nothing is left to deliver.
+	if err := d.DeliverOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(rc.received()); got != 1 {
+		t.Errorf("want 1 request, got %d", got)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		maxAttempts  int
+		backoff      time.Duration
+		rounds       int
+		wantStatus   string
+		wantAttempts int
+	}{
+		{
+			name:         "retried until delivered",
+			statuses:     []int{http.StatusInternalServerError, http.StatusBadGateway},
+			maxAttempts:  5,
+			rounds:       5,
+			wantStatus:   service.DeliveryDelivered,
+			wantAttempts: 3,
+		},
+		{
+			name:         "given up on",
+			statuses:     []int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError},
+			maxAttempts:  2,
+			rounds:       5,
+			wantStatus:   service.DeliveryFailed,
+			wantAttempts: 2,
+		},
+		{
+			name:         "waits for backoff",
+			statuses:     []int{http.StatusInternalServerError},
+			maxAttempts:  5,
+			backoff:      time.Hour,
+			rounds:       3,
+			wantStatus:   service.DeliveryPending,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := &receiver{statuses: tt.statuses}
+			ts := httptest.NewServer(rc)
+			defer ts.Close()
+
+			r, cleanup := newTestDB(t)
+			defer cleanup()
+
+			ctx := context.TODO()
+
+			hook, err := r.CreateWebhook(ctx, service.Webhook{
+				URL:    ts.URL,
+				Events: []string{service.EventFactUpdated},
+				Secret: "shh",
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := r.EnqueueWebhookDeliveries(ctx, service.EventFactUpdated, []byte(`{}`), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+
+			d := webhook.New(r,
+				webhook.WithHTTPClient(ts.Client()),
+				webhook.WithMaxAttempts(tt.maxAttempts),
+				webhook.WithBackoff(tt.backoff, tt.backoff),
+			)
+			for i := 0; i < tt.rounds; i++ {
+				if err := d.DeliverOnce(ctx); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			deliveries, err := r.WebhookDeliveries(ctx, hook.ID, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deliveries) != 1 {
+				t.Fatalf("want 1 delivery, got %d", len(deliveries))
+			}
+
+			got := deliveries[0]
+			if got.Status != tt.wantStatus {
+				t.Errorf("want status %q, got %q", tt.wantStatus, got.Status)
+			}
+			if len(got.Attempts) != tt.wantAttempts {
+				t.Errorf("want %d attempts, got %d", tt.wantAttempts, len(got.Attempts))
+			}
+			if n := len(rc.received()); n != tt.wantAttempts {
+				t.Errorf("want %d requests, got %d", tt.wantAttempts, n)
+			}
+			if (got.Status == service.DeliveryPending) != (got.NextAttemptAt != nil) {
+				t.Errorf("want a next attempt only while pending, got %v", got.NextAttemptAt)
+			}
+		})
+	}
+}
+
+func TestUnreachable(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	url := ts.URL
+	ts.Close()
+
+	r, cleanup := newTestDB(t)
+	defer cleanup()
+
+	ctx := context.TODO()
+
+	hook, err := r.CreateWebhook(ctx, service.Webhook{
+		URL:    url,
+		Events: []string{service.EventFactCreated},
+		Secret: "shh",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.EnqueueWebhookDeliveries(ctx, service.EventFactCreated, []byte(`{}`), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := webhook.New(r).DeliverOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := r.WebhookDeliveries(ctx, hook.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries) != 1 || len(deliveries[0].Attempts) != 1 {
+		t.Fatalf("want 1 delivery with 1 attempt, got %+v", deliveries)
+	}
+
+	attempt := deliveries[0].Attempts[0]
+	if attempt.StatusCode != 0 || attempt.Error == "" {
+		t.Errorf("want an error and no status, got %+v", attempt)
+	}
+	if deliveries[0].Status != service.DeliveryPending {
+		t.Errorf("want status %q, got %q", service.DeliveryPending, deliveries[0].Status)
+	}
+}
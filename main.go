@@ -2,19 +2,27 @@ package main
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/tls"
 	"database/sql"
+	"encoding/hex"
 	"flag"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	log "golang.org/x/exp/slog"
 	_ "modernc.org/sqlite"
 
+	"github.com/connorkuehl/factoid/internal/bot"
+	"github.com/connorkuehl/factoid/internal/linkcheck"
 	"github.com/connorkuehl/factoid/internal/repo/sqlite"
 	"github.com/connorkuehl/factoid/internal/service"
+	"github.com/connorkuehl/factoid/internal/webhook"
 )
 
 func main() {
@@ -22,28 +30,107 @@ func main() {
 		addr       string
 		sqlitePath string
 		auth       string
+
+		dailyRepeatWindow int
+
+		linkCheckInterval    time.Duration
+		linkCheckPerHost     int
+		linkCheckConcurrency int
+
+		idempotencyTTL time.Duration
+
+		eventLogSize  int
+		maxWebSockets int
+
+		webhookInterval    time.Duration
+		webhookMaxAttempts int
+
+		slackSigningSecret string
+		slackUsers         string
+
+		discordPublicKey string
+		discordAppID     string
+		discordBotToken  string
+
+		ircAddr     string
+		ircTLS      bool
+		ircNick     string
+		ircPassword string
+		ircChannels string
+		botPrefix   string
+		botCooldown time.Duration
+
+		baseURL string
 	}
 
 	flag.StringVar(&config.addr, "addr", ":8080", "address to listen on")
 	flag.StringVar(&config.sqlitePath, "db-sqlite", ":memory:", "path to SQLite DB")
 	flag.StringVar(&config.auth, "authorization", "", "secret for write-operations, disabled by default!")
+	flag.IntVar(&config.dailyRepeatWindow, "daily-repeat-window", 30, "days before the fact of the day may repeat")
+	flag.DurationVar(&config.linkCheckInterval, "link-check-interval", 0, "how often to check citation URLs for rot, disabled by default")
+	flag.IntVar(&config.linkCheckPerHost, "link-check-per-host", 2, "maximum concurrent link checks against a single host")
+	flag.IntVar(&config.linkCheckConcurrency, "link-check-concurrency", 16, "maximum concurrent link checks across all hosts")
+	flag.DurationVar(&config.idempotencyTTL, "idempotency-ttl", 24*time.Hour, "how long responses to requests with an Idempotency-Key are kept")
+	flag.IntVar(&config.eventLogSize, "event-log-size", 1000, "how many events are kept for streaming clients that reconnect")
+	flag.IntVar(&config.maxWebSockets, "max-websockets", 100, "most WebSocket connections served at once")
+	flag.DurationVar(&config.webhookInterval, "webhook-interval", 5*time.Second, "how often to send webhook deliveries that are due")
+	flag.IntVar(&config.webhookMaxAttempts, "webhook-max-attempts", 10, "how many times a webhook delivery is tried before giving up")
+	flag.StringVar(&config.slackSigningSecret, "slack-signing-secret", "", "signing secret of the Slack app sending slash commands, disabled by default")
+	flag.StringVar(&config.slackUsers, "slack-authorized-users", "", "comma-separated IDs of the Slack users who may add facts")
+	flag.StringVar(&config.discordPublicKey, "discord-public-key", "", "hex public key of the Discord application sending interactions, disabled by default")
+	flag.StringVar(&config.discordAppID, "discord-app-id", "", "ID of the Discord application to register the /fact command with")
+	flag.StringVar(&config.discordBotToken, "discord-bot-token", "", "bot token of the Discord application, to register the /fact command at startup")
+	flag.StringVar(&config.ircAddr, "irc-addr", "", "host:port of an IRC server to run a chat bot on, disabled by default")
+	flag.BoolVar(&config.ircTLS, "irc-tls", false, "connect to the IRC server with TLS")
+	flag.StringVar(&config.ircNick, "irc-nick", "factoid", "nickname of the IRC bot")
+	flag.StringVar(&config.ircPassword, "irc-password", "", "password of the IRC server")
+	flag.StringVar(&config.ircChannels, "irc-channels", "", "comma-separated IRC channels for the bot to join")
+	flag.StringVar(&config.botPrefix, "bot-prefix", "!", "what chat bot commands start with")
+	flag.DurationVar(&config.botCooldown, "bot-cooldown", 10*time.Second, "how long the chat bot stays quiet in a channel after answering")
+	flag.StringVar(&config.baseURL, "base-url", "", "URL the server is reachable at, for absolute links such as those in feeds; worked out from each request by default")
 	flag.Parse()
 
 	logger := log.With("component", "service")
+
+	var discordKey ed25519.PublicKey
+	if config.discordPublicKey != "" {
+		key, err := hex.DecodeString(config.discordPublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			logger.With("err", "-discord-public-key is not a hex Ed25519 public key").Error("")
+			os.Exit(2)
+		}
+		discordKey = key
+	}
+
 	logger.With("db-sqlite", config.sqlitePath).Info("")
 
 	db, _ := sql.Open("sqlite", config.sqlitePath)
 	defer db.Close()
 
 	if config.sqlitePath == ":memory:" {
-		if _, err := db.Exec(sqlite.Schema()); err != nil {
-			panic(err)
-		}
+		// Every connection to :memory: opens a brand new database.
+		db.SetMaxOpenConns(1)
 	}
 
+	// Databases made by older versions are brought up to date.
+	if err := sqlite.Migrate(context.Background(), db); err != nil {
+		logger.With("err", err).Error("")
+		os.Exit(1)
+	}
+
+	repo := sqlite.NewRepo(db)
+
 	service := service.New(
-		sqlite.NewRepo(db),
+		repo,
 		service.WithAuthorizer(config.auth),
+		service.WithDailyRepeatWindow(config.dailyRepeatWindow),
+		service.WithIdempotencyStore(repo, config.idempotencyTTL),
+		service.WithEventLog(service.NewMemoryEventLog(config.eventLogSize)),
+		service.WithMaxWebSockets(config.maxWebSockets),
+		service.WithWebhookStore(repo),
+		service.WithSlack(config.slackSigningSecret, splitList(config.slackUsers)...),
+		service.WithDiscord(discordKey),
+		service.WithBaseURL(config.baseURL),
 	)
 
 	mux := service.Routes()
@@ -52,6 +139,7 @@ func main() {
 		Addr:    config.addr,
 		Handler: mux,
 	}
+	server.RegisterOnShutdown(service.Close)
 
 	serve := func(s *http.Server) {
 		logger := log.With("http_addr", s.Addr)
@@ -66,6 +154,37 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, os.Interrupt)
 	defer cancel()
 
+	if config.linkCheckInterval > 0 {
+		checker := linkcheck.New(
+			repo,
+			linkcheck.WithInterval(config.linkCheckInterval),
+			linkcheck.WithPerHostLimit(config.linkCheckPerHost),
+			linkcheck.WithConcurrency(config.linkCheckConcurrency),
+		)
+		go checker.Run(ctx)
+	}
+
+	if config.discordAppID != "" && config.discordBotToken != "" {
+		go registerDiscordCommands(ctx, config.discordAppID, config.discordBotToken)
+	}
+
+	if config.ircAddr != "" {
+		b := bot.New(repo, bot.WithPrefix(config.botPrefix), bot.WithCooldown(config.botCooldown))
+		cfg := bot.IRCConfig{
+			Nick:     config.ircNick,
+			Password: config.ircPassword,
+			Channels: splitList(config.ircChannels),
+		}
+		go runIRCBot(ctx, b, config.ircAddr, config.ircTLS, cfg)
+	}
+
+	dispatcher := webhook.New(
+		repo,
+		webhook.WithInterval(config.webhookInterval),
+		webhook.WithMaxAttempts(config.webhookMaxAttempts),
+	)
+	go dispatcher.Run(ctx)
+
 	<-ctx.Done()
 
 	log.Info("attempting graceful shutdown, send SIGINT again to cancel")
@@ -80,3 +199,74 @@ func main() {
 	}
 	log.Info("reached shutdown")
 }
+
+// registerDiscordCommands tells Discord about the /fact command, so it
+// can be used without registering it by hand.
+func registerDiscordCommands(ctx context.Context, appID, token string) {
+	logger := log.With("component", "discord")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	if err := service.RegisterDiscordCommands(ctx, client, service.DiscordAPI, appID, token, service.DiscordFactCommand); err != nil {
+		logger.With("err", err).Error("")
+		return
+	}
+	logger.Info("registered commands")
+}
+
+// runIRCBot keeps b connected to the IRC server at addr until ctx is
+// cancelled, reconnecting when the connection is lost.
+func runIRCBot(ctx context.Context, b *bot.Bot, addr string, useTLS bool, cfg bot.IRCConfig) {
+	logger := log.With("component", "bot", "irc_addr", addr)
+
+	const retry = 30 * time.Second
+	for {
+		err := func() error {
+			dialCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+
+			var (
+				conn net.Conn
+				err  error
+			)
+			if useTLS {
+				conn, err = (&tls.Dialer{}).DialContext(dialCtx, "tcp", addr)
+			} else {
+				conn, err = (&net.Dialer{}).DialContext(dialCtx, "tcp", addr)
+			}
+			if err != nil {
+				return err
+			}
+
+			irc, err := bot.NewIRC(dialCtx, conn, cfg)
+			if err != nil {
+				conn.Close()
+				return err
+			}
+			defer irc.Close()
+
+			logger.With("nick", irc.Nick()).Info("connected")
+			return b.Run(ctx, irc)
+		}()
+		if ctx.Err() != nil {
+			return
+		}
+		logger.With("err", err).Error("")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// splitList splits a comma-separated flag, ignoring blanks.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}